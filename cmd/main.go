@@ -39,6 +39,9 @@ func main() {
 			Usage: "Output NF log to `FILE`",
 		},
 	}
+	app.Commands = []cli.Command{
+		suciCommand,
+	}
 
 	if err := app.Run(os.Args); err != nil {
 		logger.MainLog.Errorf("UDM Run error: %v\n", err)
@@ -73,6 +76,17 @@ func action(cliCtx *cli.Context) error {
 		return err
 	}
 
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
+	go func() {
+		for range hupCh {
+			if err := udm.ReloadConfig(); err != nil {
+				logger.CfgLog.Errorf("Reload config Error[%+v], keep the running config", err)
+			}
+		}
+	}()
+
 	udm.Start()
 
 	return nil
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+// suciCommand manages the home network keys used in the SuciProfile of the UDM configuration
+var suciCommand = cli.Command{
+	Name:  "suci",
+	Usage: "Manage the home network keys of SUCI protection schemes",
+	Subcommands: []cli.Command{
+		{
+			Name:   "keygen",
+			Usage:  "Generate a home network key pair",
+			Action: suciKeygenAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "scheme, s",
+					Usage: "Protection scheme `A|B` (ECIES Profile A or B)",
+				},
+			},
+		},
+		{
+			Name:   "pubkey",
+			Usage:  "Derive the home network public key from a private key",
+			Action: suciPubkeyAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "scheme, s",
+					Usage: "Protection scheme `A|B` (ECIES Profile A or B)",
+					Value: "A",
+				},
+				cli.StringFlag{
+					Name:  "private, p",
+					Usage: "Home network private key in `HEX`",
+				},
+				cli.BoolFlag{
+					Name:  "uncompressed",
+					Usage: "Output the uncompressed public key (Profile B only)",
+				},
+			},
+		},
+		{
+			Name:   "validate",
+			Usage:  "Check that the public key of each SuciProfile matches its private key",
+			Action: suciValidateAction,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "config, c",
+					Usage: "Load configuration from `FILE`",
+				},
+			},
+		},
+	},
+}
+
+func parseProtectionScheme(scheme string) (string, error) {
+	switch strings.ToUpper(scheme) {
+	case "A", suci.ProfileAScheme:
+		return suci.ProfileAScheme, nil
+	case "B", suci.ProfileBScheme:
+		return suci.ProfileBScheme, nil
+	default:
+		return "", fmt.Errorf("Invalid scheme [%s], should be A or B", scheme)
+	}
+}
+
+func suciKeygenAction(cliCtx *cli.Context) error {
+	scheme, err := parseProtectionScheme(cliCtx.String("scheme"))
+	if err != nil {
+		return err
+	}
+
+	privateKey, publicKey, err := suci.GenerateKeyPair(scheme)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ProtectionScheme: %s\n", scheme)
+	fmt.Printf("PrivateKey: %s\n", privateKey)
+	fmt.Printf("PublicKey: %s\n", publicKey)
+	return nil
+}
+
+func suciPubkeyAction(cliCtx *cli.Context) error {
+	scheme, err := parseProtectionScheme(cliCtx.String("scheme"))
+	if err != nil {
+		return err
+	}
+
+	privateKey := cliCtx.String("private")
+	if privateKey == "" {
+		return fmt.Errorf("--private is required")
+	}
+
+	publicKey, err := suci.PublicKey(scheme, privateKey, !cliCtx.Bool("uncompressed"))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("PublicKey: %s\n", publicKey)
+	return nil
+}
+
+func suciValidateAction(cliCtx *cli.Context) error {
+	cfg, err := factory.ReadConfig(cliCtx.String("config"))
+	if err != nil {
+		return err
+	}
+
+	var invalid int
+	for i, profile := range cfg.Configuration.SuciProfiles {
+		keyID := profile.HNPublicKeyID
+		if keyID == 0 {
+			keyID = i + 1
+		}
+		if profile.Backend == suci.BackendPkcs11 {
+			fmt.Printf("SuciProfile HNPublicKeyID[%d]: skipped, the private key is kept in the PKCS#11 token\n", keyID)
+			continue
+		}
+		if err = suci.ValidateKeyPair(profile); err != nil {
+			invalid++
+			fmt.Printf("SuciProfile HNPublicKeyID[%d]: %v\n", keyID, err)
+			continue
+		}
+		fmt.Printf("SuciProfile HNPublicKeyID[%d]: OK\n", keyID)
+	}
+
+	if invalid > 0 {
+		return cli.NewExitError(
+			fmt.Sprintf("%d of %d SuciProfile invalid", invalid, len(cfg.Configuration.SuciProfiles)), 1)
+	}
+	return nil
+}
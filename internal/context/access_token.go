@@ -0,0 +1,194 @@
+package context
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
+)
+
+// ErrInsufficientScope is returned by AuthorizationCheck for a valid access token which does not grant
+// the service or the operation of the request
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// accessTokenClaims are the claims of the access tokens issued by the NRF (TS 29.510 6.3.5.2.4)
+type accessTokenClaims struct {
+	Iss   string      `json:"iss"`
+	Sub   string      `json:"sub"`
+	Aud   interface{} `json:"aud"`
+	Scope string      `json:"scope"`
+	Exp   int64       `json:"exp"`
+}
+
+// Valid checks the expiration of the token, which is mandatory
+func (c *accessTokenClaims) Valid() error {
+	if c.Exp == 0 {
+		return errors.New("missing exp claim")
+	}
+	if time.Now().Unix() >= c.Exp {
+		return errors.New("token is expired")
+	}
+	return nil
+}
+
+// hasAudience checks that the token is issued for one of the audiences: the aud claim is the NF type
+// or a list of NF instance IDs
+func (c *accessTokenClaims) hasAudience(audiences ...string) bool {
+	switch aud := c.Aud.(type) {
+	case string:
+		return slices.Contains(audiences, aud)
+	case []interface{}:
+		for _, item := range aud {
+			if s, ok := item.(string); ok && slices.Contains(audiences, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nrfKeyCache keeps the public keys of the NRF parsed from NrfCertPem, so the access tokens are
+// validated without reading the file for every request. The file is read again when it changes.
+type nrfKeyCache struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	keys    []*rsa.PublicKey
+}
+
+// nrfPublicKeys returns the public keys of the NRF. The file may hold several public keys or
+// certificates, e.g. while the NRF rolls over its signing key. A change which cannot be loaded is
+// logged, and the previous keys are kept.
+func (context *UDMContext) nrfPublicKeys() ([]*rsa.PublicKey, error) {
+	cache := &context.nrfKeys
+	cache.Lock()
+	defer cache.Unlock()
+
+	path := context.NrfCertPem
+	cached := cache.keys != nil && cache.path == path
+	info, err := os.Stat(path)
+	if err != nil {
+		return cache.keepPrevious(cached, fmt.Errorf("read nrfCertPem: %w", err))
+	}
+	if cached && info.ModTime().Equal(cache.modTime) {
+		return cache.keys, nil
+	}
+
+	keys, err := readNrfPublicKeys(path)
+	if err != nil {
+		return cache.keepPrevious(cached, err)
+	}
+	if cached {
+		logger.UtilLog.Infof("NRF public keys [%s] reloaded", path)
+	}
+	cache.path, cache.modTime, cache.keys = path, info.ModTime(), keys
+	return keys, nil
+}
+
+func (cache *nrfKeyCache) keepPrevious(cached bool, err error) ([]*rsa.PublicKey, error) {
+	if !cached {
+		return nil, err
+	}
+	logger.UtilLog.Warnf("Reload NRF public keys [%s] error, keep the previous ones: %+v", cache.path, err)
+	return cache.keys, nil
+}
+
+func readNrfPublicKeys(path string) ([]*rsa.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read nrfCertPem: %w", err)
+	}
+	var keys []*rsa.PublicKey
+	for block, rest := pem.Decode(b); block != nil; block, rest = pem.Decode(rest) {
+		var key interface{}
+		switch block.Type {
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse nrfCertPem %s: %w", block.Type, err)
+		}
+		if rsaKey, ok := key.(*rsa.PublicKey); ok {
+			keys = append(keys, rsaKey)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RSA public key in nrfCertPem [%s]", path)
+	}
+	return keys, nil
+}
+
+// verifyAccessToken validates the access token of the Authorization header locally: its signature by
+// the NRF, its expiration, its audience and its scope. The resource and operation level scopes, e.g.
+// "nudm-sdm:am-data:read" (TS 29.510 6.3.5.2.3), are enforced if the NRF grants any of the service,
+// otherwise the scope of the service grants all its operations. It returns the subject of the token,
+// i.e. the NF instance ID of the consumer.
+func (context *UDMContext) verifyAccessToken(authorization string, serviceName models.ServiceName,
+	operationScope string,
+) (string, error) {
+	tokenString, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || tokenString == "" {
+		return "", errors.New("missing bearer access token")
+	}
+	keys, err := context.nrfPublicKeys()
+	if err != nil {
+		return "", err
+	}
+
+	var claims *accessTokenClaims
+	for _, key := range keys {
+		claims = &accessTokenClaims{}
+		_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+			}
+			return key, nil
+		})
+		// the other keys are only tried if the signature does not match
+		var validationErr *jwt.ValidationError
+		if err == nil || !errors.As(err, &validationErr) ||
+			validationErr.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			break
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid access token: %w", err)
+	}
+
+	if !claims.hasAudience(string(models.NfType_UDM), context.NfId) {
+		return "", fmt.Errorf("access token is not issued for this UDM: aud %v", claims.Aud)
+	}
+	scopes := strings.Fields(claims.Scope)
+	if !slices.Contains(scopes, string(serviceName)) {
+		return "", fmt.Errorf("%w: %s", ErrInsufficientScope, serviceName)
+	}
+	if operationScope == "" || slices.Contains(scopes, operationScope) {
+		return claims.Sub, nil
+	}
+	for _, scope := range scopes {
+		if strings.HasPrefix(scope, string(serviceName)+":") {
+			return "", fmt.Errorf("%w: %s", ErrInsufficientScope, operationScope)
+		}
+	}
+	return claims.Sub, nil
+}
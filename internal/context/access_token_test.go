@@ -0,0 +1,144 @@
+package context
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestAuthorizationCheck(t *testing.T) {
+	nrfKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&nrfKey.PublicKey)
+	require.NoError(t, err)
+	nrfCertPem := filepath.Join(t.TempDir(), "nrf.pem")
+	require.NoError(t, os.WriteFile(nrfCertPem, pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: pubKeyBytes,
+	}), 0o600))
+
+	udmContext := &UDMContext{
+		NfId:           "54804518-4191-46b3-955c-ac631f953ed8",
+		NrfCertPem:     nrfCertPem,
+		OAuth2Required: true,
+	}
+	accessToken := func(key *rsa.PrivateKey, claims jwt.MapClaims) string {
+		token, signErr := jwt.NewWithClaims(jwt.SigningMethodRS512, claims).SignedString(key)
+		require.NoError(t, signErr)
+		return "Bearer " + token
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	testCases := []struct {
+		name           string
+		token          string
+		operationScope string
+		expectErr      bool
+		insufficient   bool
+	}{
+		{
+			name:  "Service Scope",
+			token: accessToken(nrfKey, jwt.MapClaims{"aud": "UDM", "scope": "nudm-sdm nudm-uecm", "exp": exp}),
+			// the token grants all the operations of the service
+			operationScope: "nudm-sdm:am-data:read",
+		},
+		{
+			name: "Operation Scope",
+			token: accessToken(nrfKey, jwt.MapClaims{
+				"sub":   "9e0c1ee4-2b5e-4c2d-9b31-7b36e0d0c6a1",
+				"aud":   []string{"54804518-4191-46b3-955c-ac631f953ed8"},
+				"scope": "nudm-sdm nudm-sdm:am-data:read",
+				"exp":   exp,
+			}),
+			operationScope: "nudm-sdm:am-data:read",
+		},
+		{
+			name: "Other Operation Scope",
+			token: accessToken(nrfKey, jwt.MapClaims{
+				"aud": "UDM", "scope": "nudm-sdm nudm-sdm:am-data:read", "exp": exp,
+			}),
+			operationScope: "nudm-sdm:sdm-subscriptions:write",
+			expectErr:      true,
+			insufficient:   true,
+		},
+		{
+			name:         "Other Service",
+			token:        accessToken(nrfKey, jwt.MapClaims{"aud": "UDM", "scope": "nudm-uecm", "exp": exp}),
+			expectErr:    true,
+			insufficient: true,
+		},
+		{
+			name:      "Expired",
+			token:     accessToken(nrfKey, jwt.MapClaims{"aud": "UDM", "scope": "nudm-sdm", "exp": 1700000000}),
+			expectErr: true,
+		},
+		{
+			name:      "Missing Expiration",
+			token:     accessToken(nrfKey, jwt.MapClaims{"aud": "UDM", "scope": "nudm-sdm"}),
+			expectErr: true,
+		},
+		{
+			name:      "Other Audience",
+			token:     accessToken(nrfKey, jwt.MapClaims{"aud": "AUSF", "scope": "nudm-sdm", "exp": exp}),
+			expectErr: true,
+		},
+		{
+			name:      "Not Signed By NRF",
+			token:     accessToken(otherKey, jwt.MapClaims{"aud": "UDM", "scope": "nudm-sdm", "exp": exp}),
+			expectErr: true,
+		},
+		{
+			name:      "Missing Token",
+			token:     "",
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			consumer, err := udmContext.AuthorizationCheck(tc.token, models.ServiceName_NUDM_SDM, tc.operationScope)
+			if !tc.expectErr {
+				require.NoError(t, err)
+				if tc.name == "Operation Scope" {
+					// the consumer is the subject of the token
+					require.Equal(t, "9e0c1ee4-2b5e-4c2d-9b31-7b36e0d0c6a1", consumer)
+				}
+				return
+			}
+			require.Error(t, err)
+			require.Equal(t, tc.insufficient, errors.Is(err, ErrInsufficientScope))
+		})
+	}
+
+	// the keys are cached, and kept if the file cannot be read
+	require.NoError(t, os.Remove(nrfCertPem))
+	_, err = udmContext.AuthorizationCheck(testCases[0].token, models.ServiceName_NUDM_SDM, "")
+	require.NoError(t, err)
+
+	// the keys are reloaded when the NRF rolls over its signing key
+	otherPubKeyBytes, err := x509.MarshalPKIXPublicKey(&otherKey.PublicKey)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(nrfCertPem, pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: otherPubKeyBytes,
+	}), 0o600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(nrfCertPem, later, later))
+	otherToken := accessToken(otherKey, jwt.MapClaims{"aud": "UDM", "scope": "nudm-sdm", "exp": exp})
+	_, err = udmContext.AuthorizationCheck(otherToken, models.ServiceName_NUDM_SDM, "")
+	require.NoError(t, err)
+	_, err = udmContext.AuthorizationCheck(testCases[0].token, models.ServiceName_NUDM_SDM, "")
+	require.Error(t, err)
+}
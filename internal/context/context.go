@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"net"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -17,6 +19,7 @@ import (
 	"github.com/free5gc/openapi/oauth"
 	"github.com/free5gc/udm/internal/logger"
 	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/keywrap"
 	"github.com/free5gc/udm/pkg/suci"
 	"github.com/free5gc/util/idgenerator"
 )
@@ -38,7 +41,7 @@ func Init() {
 }
 
 type NFContext interface {
-	AuthorizationCheck(token string, serviceName models.ServiceName) error
+	AuthorizationCheck(token string, serviceName models.ServiceName, operationScope string) (string, error)
 }
 
 var _ NFContext = &UDMContext{}
@@ -48,19 +51,36 @@ type UDMContext struct {
 	GroupId                        string
 	SBIPort                        int
 	RegisterIPv4                   string // IP register to NRF
+	RegisterIPv6                   string // IPv6 register to NRF
+	Fqdn                           string // FQDN register to NRF, used in the URIs instead of the IP
 	BindingIPv4                    string
 	UriScheme                      models.UriScheme
 	NfService                      map[models.ServiceName]models.NfService
 	NFDiscoveryClient              *Nnrf_NFDiscovery.APIClient
 	UdmUePool                      sync.Map // map[supi]*UdmUeContext
 	NrfUri                         string
+	NrfDisabled                    bool
+	StaticUdrs                     []factory.StaticUdr // used instead of the NRF discovery
+	ScpUri                         string              // apiRoot of the SCP the requests are sent through
+	ScpDelegatedDiscovery          bool                // the UDRs are discovered by the SCP
+	NfSetId                        string              // NF set of the UDM, registered to NRF
 	NrfCertPem                     string
 	GpsiSupiList                   models.IdentityData
 	SharedSubsDataMap              map[string]models.SharedData // sharedDataIds as key
 	SubscriptionOfSharedDataChange sync.Map                     // subscriptionID as key
-	SuciProfiles                   []suci.SuciProfile
+	SuciProfiles                   map[int]suci.SuciProfile     // HNPublicKeyID as key
+	SubscriberKeyUnwrapper         keywrap.Unwrapper
+	UdmInfo                        *UdmInfo // registered to NRF
 	EeSubscriptionIDGenerator      *idgenerator.IDGenerator
 	OAuth2Required                 bool
+
+	// guards the settings swapped by a config reload: NrfUri, SuciProfiles and NfService
+	reloadLock sync.RWMutex
+	// the requests using the SuciProfiles, whose deconcealers are closed after the last of them once
+	// they are replaced
+	suciProfileUsers *suciProfileUsers
+	// the public keys of the NRF validating the access tokens
+	nrfKeys nrfKeyCache
 }
 
 type UdmUeContext struct {
@@ -70,6 +90,8 @@ type UdmUeContext struct {
 	Nssai                             *models.Nssai
 	Amf3GppAccessRegistration         *models.Amf3GppAccessRegistration
 	AmfNon3GppAccessRegistration      *models.AmfNon3GppAccessRegistration
+	Amf3GppAccessRoutingBinding       string // 3gpp-Sbi-Routing-Binding of the notifications to the AMF
+	AmfNon3GppAccessRoutingBinding    string // 3gpp-Sbi-Routing-Binding of the notifications to the AMF
 	AccessAndMobilitySubscriptionData *models.AccessAndMobilitySubscriptionData
 	SmfSelSubsData                    *models.SmfSelectionSubscriptionData
 	UeCtxtInSmfData                   *models.UeContextInSmfData
@@ -78,20 +100,146 @@ type UdmUeContext struct {
 	SessionManagementSubsData         map[string]models.SessionManagementSubscriptionData
 	SubsDataSets                      *models.SubscriptionDataSets
 	SubscribeToNotifChange            map[string]*models.SdmSubscription
+	SdmSubscriptionRoutingBindings    map[string]string // subscriptionID as key
 	SubscribeToNotifSharedDataChange  *models.SdmSubscription
 	PduSessionID                      string
-	UdrUri                            string
+	udrUri                            string // the UDR selected for the UE
 	UdmSubsToNotify                   map[string]*models.SubscriptionDataSubscriptions
 	EeSubscriptions                   map[string]*models.EeSubscription // subscriptionID as key
+	AuthEvents                        map[string]*models.AuthEvent      // authEventID as key, one per serving network
 	amSubsDataLock                    sync.Mutex
+	authEventsLock                    sync.RWMutex
 	smfSelSubsDataLock                sync.Mutex
 	SmSubsDataLock                    sync.RWMutex
+	udrUriLock                        sync.RWMutex
 }
 
 func (ue *UdmUeContext) Init() {
 	ue.UdmSubsToNotify = make(map[string]*models.SubscriptionDataSubscriptions)
 	ue.EeSubscriptions = make(map[string]*models.EeSubscription)
 	ue.SubscribeToNotifChange = make(map[string]*models.SdmSubscription)
+	ue.SdmSubscriptionRoutingBindings = make(map[string]string)
+	ue.AuthEvents = make(map[string]*models.AuthEvent)
+}
+
+// AuthEvent is the AuthEvent of TS 29.503 6.3.6.2.3 including the Rel-16 attributes
+// which are not provided by models.AuthEvent yet.
+type AuthEvent struct {
+	models.AuthEvent
+	AuthRemovalInd bool `json:"authRemovalInd,omitempty" yaml:"authRemovalInd" bson:"authRemovalInd"`
+}
+
+// UeContextInAmfData is the UeContextInAmfData of TS 29.503 6.1.6.2.x, which is not provided by
+// models yet. The EPS interworking information is not supported.
+type UeContextInAmfData struct {
+	AmfInfo []AmfInfo `json:"amfInfo,omitempty" yaml:"amfInfo" bson:"amfInfo"`
+}
+
+// AmfInfo is the AMF serving the UE in an access type (TS 29.503 6.1.6.2.x)
+type AmfInfo struct {
+	AmfInstanceId string            `json:"amfInstanceId" yaml:"amfInstanceId" bson:"amfInstanceId"`
+	Guami         *models.Guami     `json:"guami" yaml:"guami" bson:"guami"`
+	AccessType    models.AccessType `json:"accessType,omitempty" yaml:"accessType" bson:"accessType"`
+}
+
+// AuthenticationSubscription is the AuthenticationSubscription of TS 29.505 6.1.6.2.x including the
+// Rel-17 AKMA attribute which is not provided by models.AuthenticationSubscription yet.
+type AuthenticationSubscription struct {
+	models.AuthenticationSubscription
+	AkmaAllowed bool `json:"akmaAllowed,omitempty" yaml:"akmaAllowed" bson:"akmaAllowed"`
+}
+
+// AuthenticationInfoResult is the AuthenticationInfoResult of TS 29.503 6.3.6.2.3 including the
+// Rel-17 AKMA indication which is not provided by models.AuthenticationInfoResult yet.
+type AuthenticationInfoResult struct {
+	models.AuthenticationInfoResult
+	AkmaInd bool `json:"akmaInd,omitempty" yaml:"akmaInd" bson:"akmaInd"`
+}
+
+const GbaAuthType_DIGEST_AKAV1_MD5 string = "DIGEST_AKAV1_MD5"
+
+// GbaAuthenticationInfoRequest is the request body of the GBA AV generation (TS 29.503 6.3.6.2.x)
+type GbaAuthenticationInfoRequest struct {
+	AuthType              string                        `json:"authType"`
+	ResynchronizationInfo *models.ResynchronizationInfo `json:"resynchronizationInfo,omitempty"`
+	SupportedFeatures     string                        `json:"supportedFeatures,omitempty"`
+}
+
+// GbaAuthenticationInfoResult is the response body of the GBA AV generation (TS 29.503 6.3.6.2.x)
+type GbaAuthenticationInfoResult struct {
+	ThreeGAkaAv       *ThreeGAkaAv `json:"3gAkaAv,omitempty"`
+	SupportedFeatures string       `json:"supportedFeatures,omitempty"`
+}
+
+// ThreeGAkaAv is the UMTS authentication vector used for GBA (TS 33.220)
+type ThreeGAkaAv struct {
+	Rand string `json:"rand" yaml:"rand" bson:"rand"`
+	Xres string `json:"xres" yaml:"xres" bson:"xres"`
+	Autn string `json:"autn" yaml:"autn" bson:"autn"`
+	Ck   string `json:"ck" yaml:"ck" bson:"ck"`
+	Ik   string `json:"ik" yaml:"ik" bson:"ik"`
+}
+
+// UdmInfo is the UdmInfo of TS 29.510 6.1.6.2.15 including the Rel-16 attributes which are not
+// provided by models.UdmInfo yet.
+type UdmInfo struct {
+	models.UdmInfo
+	InternalGroupIdentifiersRanges []InternalGroupIdRange `json:"internalGroupIdentifiersRanges,omitempty"`
+}
+
+// InternalGroupIdRange is the InternalGroupIdRange of TS 29.510 6.1.6.2.63
+type InternalGroupIdRange struct {
+	Start   string `json:"start,omitempty"`
+	End     string `json:"end,omitempty"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// NfProfile is the models.NfProfile registered to the NRF with the UdmInfo above
+type NfProfile struct {
+	models.NfProfile
+	NfSetIdList []string `json:"nfSetIdList,omitempty"`
+	UdmInfo     *UdmInfo `json:"udmInfo,omitempty"`
+}
+
+// NewUdmInfo converts the configured UdmInfo into the one registered to the NRF
+func NewUdmInfo(cfg *factory.UdmInfo) *UdmInfo {
+	udmInfo := &UdmInfo{}
+	if cfg == nil {
+		return udmInfo
+	}
+
+	udmInfo.GroupId = cfg.GroupId
+	udmInfo.RoutingIndicators = cfg.RoutingIndicators
+	for _, r := range cfg.SupiRanges {
+		udmInfo.SupiRanges = append(udmInfo.SupiRanges, models.SupiRange{
+			Start:   r.Start,
+			End:     r.End,
+			Pattern: r.Pattern,
+		})
+	}
+	udmInfo.GpsiRanges = newIdentityRanges(cfg.GpsiRanges)
+	udmInfo.ExternalGroupIdentifiersRanges = newIdentityRanges(cfg.ExternalGroupIdentifiersRanges)
+	for _, r := range cfg.InternalGroupIdentifiersRanges {
+		udmInfo.InternalGroupIdentifiersRanges = append(udmInfo.InternalGroupIdentifiersRanges,
+			InternalGroupIdRange{
+				Start:   r.Start,
+				End:     r.End,
+				Pattern: r.Pattern,
+			})
+	}
+	return udmInfo
+}
+
+func newIdentityRanges(cfg []factory.IdentityRange) []models.IdentityRange {
+	var ranges []models.IdentityRange
+	for _, r := range cfg {
+		ranges = append(ranges, models.IdentityRange{
+			Start:   r.Start,
+			End:     r.End,
+			Pattern: r.Pattern,
+		})
+	}
+	return ranges
 }
 
 type UdmNFContext struct {
@@ -113,9 +261,12 @@ func InitUdmContext(context *UDMContext) {
 		if sbi.Scheme != "" {
 			udmContext.UriScheme = models.UriScheme(sbi.Scheme)
 		}
-		if sbi.RegisterIPv4 != "" {
+		// the default IPv4 is registered only if no address is configured
+		if sbi.RegisterIPv4 != "" || sbi.RegisterIPv6 != "" || sbi.Fqdn != "" {
 			udmContext.RegisterIPv4 = sbi.RegisterIPv4
 		}
+		udmContext.RegisterIPv6 = sbi.RegisterIPv6
+		udmContext.Fqdn = sbi.Fqdn
 		if sbi.Port != 0 {
 			udmContext.SBIPort = sbi.Port
 		}
@@ -132,10 +283,46 @@ func InitUdmContext(context *UDMContext) {
 		}
 	}
 	udmContext.NrfUri = configuration.NrfUri
+	udmContext.NrfDisabled = configuration.DisableNrf
+	udmContext.StaticUdrs = configuration.Udrs
+	if scp := configuration.Scp; scp != nil {
+		udmContext.ScpUri = strings.TrimSuffix(scp.Uri, "/")
+		udmContext.ScpDelegatedDiscovery = scp.DelegatedDiscovery
+	}
+	if binding := configuration.Binding; binding != nil {
+		udmContext.NfSetId = binding.NfSetId
+	}
 	context.NrfCertPem = configuration.NrfCertPem
 	servingNameList := configuration.ServiceNameList
 
-	udmContext.SuciProfiles = configuration.SuciProfiles
+	suciProfiles, err := suci.ProfileMap(configuration.SuciProfiles)
+	if err != nil {
+		logger.UtilLog.Errorf("SuciProfile error: %+v", err)
+	}
+	for keyID, profile := range suciProfiles {
+		if profile.Deconcealer, err = suci.NewDeconcealer(profile); err != nil {
+			logger.UtilLog.Errorf("SuciProfile HNPublicKeyID[%d] backend error: %+v", keyID, err)
+			continue
+		}
+		suciProfiles[keyID] = profile
+	}
+
+	var keks map[int32][]byte
+	var kmsPlugin keywrap.Unwrapper
+	if protection := configuration.SubscriberKeyProtection; protection != nil {
+		if keks, err = protection.GetKeks(); err != nil {
+			logger.UtilLog.Errorf("SubscriberKeyProtection error: %+v", err)
+		}
+		if plugin := protection.KmsPlugin; plugin != nil {
+			kmsPlugin = keywrap.NewPlugin(plugin.Command, plugin.Args, plugin.Timeout)
+		}
+	}
+	udmContext.SubscriberKeyUnwrapper = keywrap.New(keks, kmsPlugin)
+	udmContext.SuciProfiles = suciProfiles
+	udmContext.suciProfileUsers = &suciProfileUsers{}
+
+	udmContext.UdmInfo = NewUdmInfo(configuration.UdmInfo)
+	udmContext.GroupId = udmContext.UdmInfo.GroupId
 
 	udmContext.InitNFService(servingNameList, config.Info.Version)
 }
@@ -237,9 +424,17 @@ func (context *UDMContext) CreateTraceDataforUe(supi string, body models.TraceDa
 }
 
 // functions related to sdmSubscription (subscribe to notification of data change)
-func (udmUeContext *UdmUeContext) CreateSubscriptiontoNotifChange(subscriptionID string, body *models.SdmSubscription) {
+
+// CreateSubscriptiontoNotifChange stores the SDM subscription, with the 3gpp-Sbi-Routing-Binding of
+// the notifications sent to its callbackReference, or "" if the subscriber has provided no binding
+func (udmUeContext *UdmUeContext) CreateSubscriptiontoNotifChange(subscriptionID string, body *models.SdmSubscription,
+	routingBinding string,
+) {
 	if _, exist := udmUeContext.SubscribeToNotifChange[subscriptionID]; !exist {
 		udmUeContext.SubscribeToNotifChange[subscriptionID] = body
+		if routingBinding != "" {
+			udmUeContext.SdmSubscriptionRoutingBindings[subscriptionID] = routingBinding
+		}
 	}
 }
 
@@ -280,6 +475,90 @@ func (udmUeContext *UdmUeContext) SetSMSubsData(smSubsData map[string]models.Ses
 	udmUeContext.SessionManagementSubsData = smSubsData
 }
 
+// GetUdrUri returns the UDR selected for the UE, or "" if none is selected
+func (udmUeContext *UdmUeContext) GetUdrUri() string {
+	udmUeContext.udrUriLock.RLock()
+	defer udmUeContext.udrUriLock.RUnlock()
+	return udmUeContext.udrUri
+}
+
+func (udmUeContext *UdmUeContext) SetUdrUri(udrUri string) {
+	udmUeContext.udrUriLock.Lock()
+	defer udmUeContext.udrUriLock.Unlock()
+	udmUeContext.udrUri = udrUri
+}
+
+// functions related to the authentication status (auth-events)
+
+// StoreAuthEvent stores the authentication event of the UE and returns its authEventID. The UE keeps
+// one event per serving network, so an event replaces the previous one of its serving network and
+// keeps its authEventID.
+func (udmUeContext *UdmUeContext) StoreAuthEvent(authEvent *models.AuthEvent) string {
+	udmUeContext.authEventsLock.Lock()
+	defer udmUeContext.authEventsLock.Unlock()
+	for authEventID, stored := range udmUeContext.AuthEvents {
+		if stored.ServingNetworkName == authEvent.ServingNetworkName {
+			udmUeContext.AuthEvents[authEventID] = authEvent
+			return authEventID
+		}
+	}
+	authEventID := uuid.New().String()
+	udmUeContext.AuthEvents[authEventID] = authEvent
+	return authEventID
+}
+
+func (udmUeContext *UdmUeContext) GetAuthEvent(authEventID string) (*models.AuthEvent, bool) {
+	udmUeContext.authEventsLock.RLock()
+	defer udmUeContext.authEventsLock.RUnlock()
+	authEvent, ok := udmUeContext.AuthEvents[authEventID]
+	return authEvent, ok
+}
+
+func (udmUeContext *UdmUeContext) RemoveAuthEvent(authEventID string) {
+	udmUeContext.authEventsLock.Lock()
+	defer udmUeContext.authEventsLock.Unlock()
+	delete(udmUeContext.AuthEvents, authEventID)
+}
+
+// LatestAuthEvent returns the latest authentication event of the UE by timeStamp, except the event of
+// excludedID. It returns false if the UE has no other event.
+func (udmUeContext *UdmUeContext) LatestAuthEvent(excludedID string) (*models.AuthEvent, bool) {
+	if udmUeContext == nil {
+		return nil, false
+	}
+	udmUeContext.authEventsLock.RLock()
+	defer udmUeContext.authEventsLock.RUnlock()
+
+	var latest *models.AuthEvent
+	var latestTime time.Time
+	for authEventID, authEvent := range udmUeContext.AuthEvents {
+		if authEventID == excludedID {
+			continue
+		}
+		// an event without timeStamp is older than the others
+		var timeStamp time.Time
+		if authEvent.TimeStamp != nil {
+			timeStamp = *authEvent.TimeStamp
+		}
+		if latest == nil || timeStamp.After(latestTime) {
+			latest, latestTime = authEvent, timeStamp
+		}
+	}
+	return latest, latest != nil
+}
+
+// ServingNetworkAuthEvent returns the authentication event of the UE in the serving network
+func (udmUeContext *UdmUeContext) ServingNetworkAuthEvent(servingNetworkName string) (*models.AuthEvent, bool) {
+	udmUeContext.authEventsLock.RLock()
+	defer udmUeContext.authEventsLock.RUnlock()
+	for _, authEvent := range udmUeContext.AuthEvents {
+		if authEvent.ServingNetworkName == servingNetworkName {
+			return authEvent, true
+		}
+	}
+	return nil, false
+}
+
 func (context *UDMContext) NewUdmUe(supi string) *UdmUeContext {
 	ue := new(UdmUeContext)
 	ue.Init()
@@ -398,12 +677,12 @@ func (context *UDMContext) GetAmfNon3gppRegContext(supi string) *models.AmfNon3G
 func (ue *UdmUeContext) GetLocationURI(types int) string {
 	switch types {
 	case LocationUriAmf3GppAccessRegistration:
-		return GetSelf().GetIPv4Uri() + factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/amf-3gpp-access"
+		return GetSelf().GetIPUri() + factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/amf-3gpp-access"
 	case LocationUriAmfNon3GppAccessRegistration:
-		return GetSelf().GetIPv4Uri() + factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/amf-non-3gpp-access"
+		return GetSelf().GetIPUri() + factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/amf-non-3gpp-access"
 	case LocationUriSmfRegistration:
 
-		return GetSelf().GetIPv4Uri() +
+		return GetSelf().GetIPUri() +
 			factory.UdmUecmResUriPrefix + "/" + ue.Supi + "/registrations/smf-registrations/" + ue.PduSessionID
 	}
 	return ""
@@ -412,13 +691,17 @@ func (ue *UdmUeContext) GetLocationURI(types int) string {
 func (ue *UdmUeContext) GetLocationURI2(types int, supi string) string {
 	switch types {
 	case LocationUriSharedDataSubscription:
-		// return GetSelf().GetIPv4Uri() + UdmSdmResUriPrefix +"/shared-data-subscriptions/" + nf.SubscriptionID
+		// return GetSelf().GetIPUri() + UdmSdmResUriPrefix +"/shared-data-subscriptions/" + nf.SubscriptionID
 	case LocationUriSdmSubscription:
-		return GetSelf().GetIPv4Uri() + factory.UdmSdmResUriPrefix + "/" + supi + "/sdm-subscriptions/"
+		return GetSelf().GetIPUri() + factory.UdmSdmResUriPrefix + "/" + supi + "/sdm-subscriptions/"
 	}
 	return ""
 }
 
+func (ue *UdmUeContext) GetAuthEventLocationURI(authEventID string) string {
+	return GetSelf().GetIPUri() + factory.UdmUeauResUriPrefix + "/" + ue.Supi + "/auth-events/" + authEventID
+}
+
 func (ue *UdmUeContext) SameAsStoredGUAMI3gpp(inGuami models.Guami) bool {
 	if ue.Amf3GppAccessRegistration == nil {
 		return false
@@ -453,21 +736,68 @@ func (ue *UdmUeContext) SameAsStoredGUAMINon3gpp(inGuami models.Guami) bool {
 	return false
 }
 
-func (context *UDMContext) GetIPv4Uri() string {
-	return fmt.Sprintf("%s://%s:%d", context.UriScheme, context.RegisterIPv4, context.SBIPort)
+// GetIPUri returns the URI of the UDM with the FQDN if configured, or else with the IPv4 or the
+// bracketed IPv6 address
+func (context *UDMContext) GetIPUri() string {
+	host := context.Fqdn
+	if host == "" {
+		host = context.RegisterIPv4
+	}
+	if host == "" {
+		host = context.RegisterIPv6
+	}
+	return fmt.Sprintf("%s://%s", context.UriScheme, net.JoinHostPort(host, strconv.Itoa(context.SBIPort)))
+}
+
+// getIpEndPoints returns the IPv4 and the IPv6 endpoint registered to the NRF
+func (context *UDMContext) getIpEndPoints() *[]models.IpEndPoint {
+	var ipEndPoints []models.IpEndPoint
+	if context.RegisterIPv4 != "" {
+		ipEndPoints = append(ipEndPoints, models.IpEndPoint{
+			Ipv4Address: context.RegisterIPv4,
+			Transport:   models.TransportProtocol_TCP,
+			Port:        int32(context.SBIPort),
+		})
+	}
+	if context.RegisterIPv6 != "" {
+		ipEndPoints = append(ipEndPoints, models.IpEndPoint{
+			Ipv6Address: context.RegisterIPv6,
+			Transport:   models.TransportProtocol_TCP,
+			Port:        int32(context.SBIPort),
+		})
+	}
+	if len(ipEndPoints) == 0 {
+		return nil
+	}
+	return &ipEndPoints
 }
 
 // GetSDMUri ... get subscriber data management service uri
 func (context *UDMContext) GetSDMUri() string {
-	return context.GetIPv4Uri() + factory.UdmSdmResUriPrefix
+	return context.GetIPUri() + factory.UdmSdmResUriPrefix
+}
+
+// BindingIndication returns the 3gpp-Sbi-Binding header of the UE contexts created by the UDM, which
+// binds the requests of the UE to the UDM instance (TS 29.500 6.12.1). The UE contexts are not shared
+// with the other UDMs of the NF set, which is only given for the reselection after a failure.
+func (context *UDMContext) BindingIndication() string {
+	if context.NfSetId != "" {
+		return fmt.Sprintf("bl=nfinstance; nfinst=%s; nfset=%s", context.NfId, context.NfSetId)
+	}
+	return "bl=nfinstance; nfinst=" + context.NfId
 }
 
 func (context *UDMContext) InitNFService(serviceName []string, version string) {
+	context.NfService = context.newNfServices(serviceName, version)
+}
+
+func (context *UDMContext) newNfServices(serviceName []string, version string) map[models.ServiceName]models.NfService {
+	nfServices := make(map[models.ServiceName]models.NfService, len(serviceName))
 	tmpVersion := strings.Split(version, ".")
 	versionUri := "v" + tmpVersion[0]
 	for index, nameString := range serviceName {
 		name := models.ServiceName(nameString)
-		context.NfService[name] = models.NfService{
+		nfServices[name] = models.NfService{
 			ServiceInstanceId: strconv.Itoa(index),
 			ServiceName:       name,
 			Versions: &[]models.NfServiceVersion{
@@ -478,14 +808,148 @@ func (context *UDMContext) InitNFService(serviceName []string, version string) {
 			},
 			Scheme:          context.UriScheme,
 			NfServiceStatus: models.NfServiceStatus_REGISTERED,
-			ApiPrefix:       context.GetIPv4Uri(),
-			IpEndPoints: &[]models.IpEndPoint{
-				{
-					Ipv4Address: context.RegisterIPv4,
-					Transport:   models.TransportProtocol_TCP,
-					Port:        int32(context.SBIPort),
-				},
-			},
+			ApiPrefix:       context.GetIPUri(),
+			Fqdn:            context.Fqdn,
+			IpEndPoints:     context.getIpEndPoints(),
+		}
+	}
+	return nfServices
+}
+
+// GetNfServices returns the NF services registered to the NRF
+func (context *UDMContext) GetNfServices() []models.NfService {
+	context.reloadLock.RLock()
+	defer context.reloadLock.RUnlock()
+	services := make([]models.NfService, 0, len(context.NfService))
+	for _, nfService := range context.NfService {
+		services = append(services, nfService)
+	}
+	return services
+}
+
+// GetNrfUri returns the NRF URI, which may be changed by a config reload
+func (context *UDMContext) GetNrfUri() string {
+	context.reloadLock.RLock()
+	defer context.reloadLock.RUnlock()
+	return context.NrfUri
+}
+
+// GetSuciProfiles returns the SUCI profiles, which may be changed by a config reload. The returned
+// map must not be modified, and its deconcealers are only used through AcquireSuciProfiles, as they
+// may be closed by a reload.
+func (context *UDMContext) GetSuciProfiles() map[int]suci.SuciProfile {
+	context.reloadLock.RLock()
+	defer context.reloadLock.RUnlock()
+	return context.SuciProfiles
+}
+
+// AcquireSuciProfiles returns the SUCI profiles for a de-concealment, and the function releasing them
+// once the de-concealment is done. The returned map must not be modified.
+func (context *UDMContext) AcquireSuciProfiles() (map[int]suci.SuciProfile, func()) {
+	context.reloadLock.RLock()
+	defer context.reloadLock.RUnlock()
+
+	users, suciProfiles := context.suciProfileUsers, context.SuciProfiles
+	users.acquire()
+	var once sync.Once
+	return suciProfiles, func() {
+		once.Do(func() {
+			users.release(suciProfiles)
+		})
+	}
+}
+
+// Reload swaps the NRF URI, the SUCI profiles and the NF services with the ones of a reloaded config.
+// The deconcealers of the replaced SUCI profiles are closed once the requests using them are done.
+func (context *UDMContext) Reload(nrfUri string, suciProfiles map[int]suci.SuciProfile,
+	serviceName []string, version string,
+) {
+	nfServices := context.newNfServices(serviceName, version)
+
+	context.reloadLock.Lock()
+	replaced, users := context.SuciProfiles, context.suciProfileUsers
+	context.NrfUri = nrfUri
+	context.SuciProfiles = suciProfiles
+	context.suciProfileUsers = &suciProfileUsers{}
+	context.NfService = nfServices
+	context.reloadLock.Unlock()
+
+	users.retire(replaced)
+}
+
+// suciProfileUsers counts the requests using a set of SUCI profiles. The SUCI profiles without users,
+// e.g. the ones of a UDMContext which is not initialized by InitUdmContext, are closed when they are
+// retired.
+type suciProfileUsers struct {
+	mu      sync.Mutex
+	count   int
+	retired bool
+}
+
+func (u *suciProfileUsers) acquire() {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.count++
+}
+
+// release closes the SUCI profiles after their last user if they are retired
+func (u *suciProfileUsers) release(suciProfiles map[int]suci.SuciProfile) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	u.count--
+	closing := u.retired && u.count == 0
+	u.mu.Unlock()
+
+	if closing {
+		CloseSuciProfiles(suciProfiles)
+	}
+}
+
+// retire closes the replaced SUCI profiles, or leaves them to their last user
+func (u *suciProfileUsers) retire(suciProfiles map[int]suci.SuciProfile) {
+	if u != nil {
+		u.mu.Lock()
+		u.retired = true
+		inUse := u.count > 0
+		u.mu.Unlock()
+		if inUse {
+			return
+		}
+	}
+	CloseSuciProfiles(suciProfiles)
+}
+
+// NewSuciProfiles maps the configured SUCI profiles by HNPublicKeyID with their deconcealers. Unlike
+// the initialization, any invalid profile is an error, so a reloaded config is rejected as a whole.
+func NewSuciProfiles(cfg []suci.SuciProfile) (map[int]suci.SuciProfile, error) {
+	suciProfiles, err := suci.ProfileMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for keyID, profile := range suciProfiles {
+		if profile.Deconcealer, err = suci.NewDeconcealer(profile); err != nil {
+			CloseSuciProfiles(suciProfiles)
+			return nil, fmt.Errorf("SuciProfile HNPublicKeyID[%d] backend error: %+v", keyID, err)
+		}
+		suciProfiles[keyID] = profile
+	}
+	return suciProfiles, nil
+}
+
+// CloseSuciProfiles closes the deconcealers of the SUCI profiles, e.g. the PKCS#11 sessions. A
+// PKCS#11 session is closed once its in-flight derivation is done.
+func CloseSuciProfiles(suciProfiles map[int]suci.SuciProfile) {
+	for keyID, profile := range suciProfiles {
+		if profile.Deconcealer == nil {
+			continue
+		}
+		if err := profile.Deconcealer.Close(); err != nil {
+			logger.UtilLog.Warnf("SuciProfile HNPublicKeyID[%d] close error: %+v", keyID, err)
 		}
 	}
 }
@@ -497,22 +961,24 @@ func (c *UDMContext) GetTokenCtx(serviceName models.ServiceName, targetNF models
 		return context.TODO(), nil, nil
 	}
 	return oauth.GetTokenCtx(models.NfType_UDM, targetNF,
-		c.NfId, c.NrfUri, string(serviceName))
+		c.NfId, c.GetNrfUri(), string(serviceName))
 }
 
 func GetSelf() *UDMContext {
 	return &udmContext
 }
 
-func (context *UDMContext) AuthorizationCheck(token string, serviceName models.ServiceName) error {
+// AuthorizationCheck validates the access token of the request to the service. The operationScope is the
+// resource and operation level scope of the request, e.g. "nudm-sdm:am-data:read", or "" if none. It
+// returns the NF instance ID of the consumer the token is granted to, or "" if OAuth2 is not required.
+func (context *UDMContext) AuthorizationCheck(token string, serviceName models.ServiceName,
+	operationScope string,
+) (string, error) {
 	if !context.OAuth2Required {
 		logger.UtilLog.Debugf("UDMContext::AuthorizationCheck: OAuth2 not required\n")
-		return nil
-	}
-	logger.UtilLog.Debugf("UDMContext::AuthorizationCheck: token[%s] serviceName[%s]\n", token, serviceName)
-	err := oauth.VerifyOAuth(token, string(serviceName), context.NrfCertPem)
-	if err != nil {
-		return err
+		return "", nil
 	}
-	return nil
+	logger.UtilLog.Debugf("UDMContext::AuthorizationCheck: serviceName[%s] operationScope[%s]\n",
+		serviceName, operationScope)
+	return context.verifyAccessToken(token, serviceName, operationScope)
 }
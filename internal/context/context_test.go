@@ -0,0 +1,46 @@
+package context
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+// closeCounter is the deconcealer of a profile replaced by a reload
+type closeCounter struct {
+	closed atomic.Int32
+}
+
+func (d *closeCounter) SharedKey([]byte) ([]byte, error) { return nil, fmt.Errorf("not implemented") }
+func (d *closeCounter) Close() error                     { d.closed.Add(1); return nil }
+
+func TestReloadSuciProfiles(t *testing.T) {
+	replaced := &closeCounter{}
+	udmContext := &UDMContext{
+		SuciProfiles:     map[int]suci.SuciProfile{1: {HNPublicKeyID: 1, Deconcealer: replaced}},
+		suciProfileUsers: &suciProfileUsers{},
+	}
+
+	// the replaced deconcealer is closed after the requests using it
+	suciProfiles, release := udmContext.AcquireSuciProfiles()
+	_, releaseOther := udmContext.AcquireSuciProfiles()
+	udmContext.Reload("", map[int]suci.SuciProfile{1: {HNPublicKeyID: 1}}, nil, "")
+	require.Same(t, replaced, suciProfiles[1].Deconcealer)
+	require.Zero(t, replaced.closed.Load())
+
+	release()
+	release()
+	require.Zero(t, replaced.closed.Load())
+	releaseOther()
+	require.Equal(t, int32(1), replaced.closed.Load())
+
+	// the replaced deconcealer without request is closed by the reload
+	unused := &closeCounter{}
+	udmContext.Reload("", map[int]suci.SuciProfile{1: {HNPublicKeyID: 1, Deconcealer: unused}}, nil, "")
+	udmContext.Reload("", map[int]suci.SuciProfile{}, nil, "")
+	require.Equal(t, int32(1), unused.closed.Load())
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	udm_context "github.com/free5gc/udm/internal/context"
+)
+
+var (
+	ueContextsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "ue_contexts"),
+		"UE contexts in the UdmUePool", nil, nil)
+	sdmSubscriptionsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "sdm", "subscriptions"),
+		"SDM subscriptions of the UEs", nil, nil)
+	sharedDataSubscriptionsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "sdm", "shared_data_subscriptions"),
+		"SDM subscriptions to the shared data change", nil, nil)
+	eeSubscriptionsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "ee", "subscriptions"),
+		"EE subscriptions of the UEs", nil, nil)
+	udrSubscriptionsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "udr", "subscriptions"),
+		"Data change subscriptions of the UDM to the UDR", nil, nil)
+)
+
+// contextCollector reports the sizes of the UE pool and the subscription maps when scraped
+type contextCollector struct {
+	getContext func() *udm_context.UDMContext
+}
+
+func newContextCollector(getContext func() *udm_context.UDMContext) *contextCollector {
+	return &contextCollector{
+		getContext: getContext,
+	}
+}
+
+func (c *contextCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ueContextsDesc
+	ch <- sdmSubscriptionsDesc
+	ch <- sharedDataSubscriptionsDesc
+	ch <- eeSubscriptionsDesc
+	ch <- udrSubscriptionsDesc
+}
+
+func (c *contextCollector) Collect(ch chan<- prometheus.Metric) {
+	udmContext := c.getContext()
+
+	var ueContexts, sdmSubscriptions, eeSubscriptions, udrSubscriptions int
+	udmContext.UdmUePool.Range(func(key, value interface{}) bool {
+		ue := value.(*udm_context.UdmUeContext)
+		ueContexts++
+		sdmSubscriptions += len(ue.SubscribeToNotifChange)
+		eeSubscriptions += len(ue.EeSubscriptions)
+		udrSubscriptions += len(ue.UdmSubsToNotify)
+		return true
+	})
+	var sharedDataSubscriptions int
+	udmContext.SubscriptionOfSharedDataChange.Range(func(key, value interface{}) bool {
+		sharedDataSubscriptions++
+		return true
+	})
+
+	ch <- prometheus.MustNewConstMetric(ueContextsDesc, prometheus.GaugeValue, float64(ueContexts))
+	ch <- prometheus.MustNewConstMetric(sdmSubscriptionsDesc, prometheus.GaugeValue, float64(sdmSubscriptions))
+	ch <- prometheus.MustNewConstMetric(sharedDataSubscriptionsDesc, prometheus.GaugeValue,
+		float64(sharedDataSubscriptions))
+	ch <- prometheus.MustNewConstMetric(eeSubscriptionsDesc, prometheus.GaugeValue, float64(eeSubscriptions))
+	ch <- prometheus.MustNewConstMetric(udrSubscriptionsDesc, prometheus.GaugeValue, float64(udrSubscriptions))
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	udm_context "github.com/free5gc/udm/internal/context"
+)
+
+const (
+	namespace = "udm"
+	// protects the metrics listener from slow clients
+	readHeaderTimeout = 5 * time.Second
+)
+
+// Registry holds the UDM metrics, and is served by the metrics listener
+var Registry = prometheus.NewRegistry()
+
+var (
+	// SBI server
+	SbiRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sbi",
+		Name:      "requests_total",
+		Help:      "SBI requests handled by the UDM",
+	}, []string{"service", "operation", "method", "code"})
+	SbiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "sbi",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the SBI requests handled by the UDM",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+
+	// UDR client
+	UdrRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "udr_client",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests sent to the UDR",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "resource"})
+	UdrRequestErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "udr_client",
+		Name:      "request_errors_total",
+		Help:      "Requests to the UDR failed with a transport error or an error status",
+	}, []string{"method", "resource", "code"})
+
+	// UE authentication
+	AuthVectorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ueau",
+		Name:      "auth_vectors_total",
+		Help:      "Authentication vectors generated, by authentication type",
+	}, []string{"auth_type"})
+	AuthResyncTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ueau",
+		Name:      "resync_attempts_total",
+		Help:      "Authentication data requests with resynchronization info",
+	})
+	AuthMacSFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ueau",
+		Name:      "mac_s_failures_total",
+		Help:      "Resynchronizations rejected for an invalid MAC-S in AUTS",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		SbiRequestsTotal,
+		SbiRequestDuration,
+		UdrRequestDuration,
+		UdrRequestErrorsTotal,
+		AuthVectorsTotal,
+		AuthResyncTotal,
+		AuthMacSFailuresTotal,
+		newContextCollector(udm_context.GetSelf),
+	)
+}
+
+// NewServer returns the HTTP server of the metrics listener
+func NewServer(bindAddr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{}))
+	return &http.Server{
+		Addr:              bindAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+}
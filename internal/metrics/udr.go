@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+var (
+	instrumentOnce sync.Once
+	// the path segments replaced in the resource label: the UE identities, and the IDs and PLMN IDs
+	// made of hexadecimal digits
+	udrIdSegment = regexp.MustCompile(`^((imsi|nai|msisdn|extid|extgroupid|gci|gli)-.*|[0-9a-fA-F-]+)$`)
+	// the moving average of the UDR latency in nanoseconds
+	udrLatency atomic.Int64
+)
+
+// udrTransport observes the latency and the errors of the requests to the UDR, which are sent by
+// the Nudr_DataRepository clients through the shared HTTP clients of openapi
+type udrTransport struct {
+	next http.RoundTripper
+}
+
+// InstrumentUdrClients wraps the transports of the HTTP clients used by Nudr_DataRepository.APIClient,
+// e.g. openapi.GetHttpClient() and openapi.GetHttpsClient(). The requests to the other NFs pass through.
+func InstrumentUdrClients(clients ...*http.Client) {
+	instrumentOnce.Do(func() {
+		for _, client := range clients {
+			next := client.Transport
+			if next == nil {
+				next = http.DefaultTransport
+			}
+			client.Transport = &udrTransport{next: next}
+		}
+	})
+}
+
+func (t *udrTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path, ok := udrResourcePath(req.URL.Path)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	start := time.Now()
+	rsp, err := t.next.RoundTrip(req)
+	duration := time.Since(start)
+	UdrRequestDuration.WithLabelValues(req.Method, path).Observe(duration.Seconds())
+	observeUdrLatency(duration)
+	if err != nil {
+		UdrRequestErrorsTotal.WithLabelValues(req.Method, path, "transport").Inc()
+	} else if rsp.StatusCode >= http.StatusBadRequest {
+		UdrRequestErrorsTotal.WithLabelValues(req.Method, path, strconv.Itoa(rsp.StatusCode)).Inc()
+	}
+	return rsp, err
+}
+
+// observeUdrLatency updates the moving average of the UDR latency, which weighs the latest request by 1/8
+func observeUdrLatency(duration time.Duration) {
+	for {
+		average := udrLatency.Load()
+		next := int64(duration)
+		if average != 0 {
+			next = average + (int64(duration)-average)/8
+		}
+		if udrLatency.CompareAndSwap(average, next) {
+			return
+		}
+	}
+}
+
+// UdrLatency returns the moving average of the latency of the requests to the UDR
+func UdrLatency() time.Duration {
+	return time.Duration(udrLatency.Load())
+}
+
+// udrResourcePath returns the path of a UDR request with the IDs replaced, e.g.
+// "/subscription-data/{id}/authentication-data/authentication-subscription", to bound the label values
+func udrResourcePath(path string) (string, bool) {
+	i := strings.Index(path, factory.UdmDrResUriPrefix)
+	if i < 0 {
+		return "", false
+	}
+	segments := strings.Split(strings.Trim(path[i+len(factory.UdmDrResUriPrefix):], "/"), "/")
+	for j, segment := range segments {
+		if udrIdSegment.MatchString(segment) {
+			segments[j] = "{id}"
+		}
+	}
+	return "/" + strings.Join(segments, "/"), true
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUdrResourcePath(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{
+			"/nudr-dr/v1/subscription-data/imsi-208930000000001/authentication-data/authentication-subscription",
+			"/subscription-data/{id}/authentication-data/authentication-subscription",
+			true,
+		},
+		{
+			"/nudr-dr/v1/subscription-data/imsi-208930000000001/20893/provisioned-data/am-data",
+			"/subscription-data/{id}/{id}/provisioned-data/am-data",
+			true,
+		},
+		{
+			"/nudr-dr/v1/subscription-data/imsi-208930000000001/context-data/amf-3gpp-access",
+			"/subscription-data/{id}/context-data/amf-3gpp-access",
+			true,
+		},
+		{"/nnrf-disc/v1/nf-instances", "", false},
+	}
+	for _, tc := range testCases {
+		path, ok := udrResourcePath(tc.path)
+		require.Equal(t, tc.ok, ok, tc.path)
+		require.Equal(t, tc.expected, path, tc.path)
+	}
+}
@@ -0,0 +1,110 @@
+package sbi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/udm/internal/logger"
+)
+
+// timeout of the UDR probe of a readiness check
+const udrProbeTimeout time.Duration = 2 * time.Second
+
+const (
+	healthOk          = "ok"
+	healthNotReady    = "not ready"
+	healthDisabled    = "disabled"
+	healthUnreachable = "unreachable"
+)
+
+// HealthStatus is the response body of /healthz and /readyz
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// getHealthRoutes returns the probes of the orchestrator, which are served without OAuth
+func (s *Server) getHealthRoutes() []Route {
+	return []Route{
+		{
+			"Healthz",
+			"GET",
+			"/healthz",
+			s.HandleHealthz,
+		},
+
+		{
+			"Readyz",
+			"GET",
+			"/readyz",
+			s.HandleReadyz,
+		},
+	}
+}
+
+// HandleHealthz reports that the UDM is alive, i.e. the SBI server is serving
+func (s *Server) HandleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthStatus{Status: healthOk})
+}
+
+// HandleReadyz reports whether the UDM can serve requests: it is registered in the NRF, the UDR is
+// reachable and the SUCI profiles are loaded. The UDM is not ready once the shutdown has started.
+func (s *Server) HandleReadyz(c *gin.Context) {
+	checks := make(map[string]string)
+	ready := true
+	check := func(name string, ok bool, failure string) {
+		if ok {
+			checks[name] = healthOk
+			return
+		}
+		checks[name] = failure
+		ready = false
+	}
+
+	check("shutdown", !s.draining.Load(), "shutting down")
+
+	if s.Context().NrfDisabled {
+		checks["nrf"] = healthDisabled
+	} else {
+		check("nrf", s.Consumer().IsNFRegistered(), "not registered")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), udrProbeTimeout)
+	err := s.Consumer().ProbeUdr(ctx)
+	cancel()
+	if err != nil {
+		logger.SBILog.Warnf("Readiness UDR probe Error[%+v]", err)
+	}
+	check("udr", err == nil, healthUnreachable)
+
+	check("suciProfiles", s.suciProfilesLoaded(), "not loaded")
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, HealthStatus{Status: healthNotReady, Checks: checks})
+		return
+	}
+	c.JSON(http.StatusOK, HealthStatus{Status: healthOk, Checks: checks})
+}
+
+// suciProfilesLoaded checks that every configured SUCI profile has its deconcealer, which fails to
+// load e.g. when the PKCS#11 token is not available
+func (s *Server) suciProfilesLoaded() bool {
+	cfg := s.Config()
+	cfg.RLock()
+	configured := len(cfg.Configuration.SuciProfiles)
+	cfg.RUnlock()
+
+	suciProfiles := s.Context().GetSuciProfiles()
+	if len(suciProfiles) != configured {
+		return false
+	}
+	for _, profile := range suciProfiles {
+		if profile.Deconcealer == nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,243 @@
+package sbi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/free5gc/openapi"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/pkg/app"
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+// healthTestUdm serves the probes with a consumer, which only reaches the UDR
+type healthTestUdm struct {
+	*routerTestUdm
+	consumer *consumer.Consumer
+}
+
+func (u *healthTestUdm) Consumer() *consumer.Consumer { return u.consumer }
+
+func TestHandleReadyz(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	suciProfileCfg := []suci.SuciProfile{{
+		ProtectionScheme: "1", // Protect Scheme: Profile A
+		PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+		PublicKey:        "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650",
+	}}
+	suciProfiles, err := udm_context.NewSuciProfiles(suciProfileCfg)
+	require.NoError(t, err)
+	unloadedProfiles, err := suci.ProfileMap(suciProfileCfg)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		draining     bool
+		nrfDisabled  bool
+		udrReachable bool
+		suciProfiles map[int]suci.SuciProfile
+		statusCode   int
+		expected     HealthStatus
+	}{
+		{
+			name:         "Ready",
+			nrfDisabled:  true,
+			udrReachable: true,
+			suciProfiles: suciProfiles,
+			statusCode:   http.StatusOK,
+			expected: HealthStatus{Status: healthOk, Checks: map[string]string{
+				"shutdown": healthOk, "nrf": healthDisabled, "udr": healthOk, "suciProfiles": healthOk,
+			}},
+		},
+		{
+			name:         "Shutting Down",
+			draining:     true,
+			nrfDisabled:  true,
+			udrReachable: true,
+			suciProfiles: suciProfiles,
+			statusCode:   http.StatusServiceUnavailable,
+			expected: HealthStatus{Status: healthNotReady, Checks: map[string]string{
+				"shutdown": "shutting down", "nrf": healthDisabled, "udr": healthOk, "suciProfiles": healthOk,
+			}},
+		},
+		{
+			name:         "Not Registered",
+			udrReachable: true,
+			suciProfiles: suciProfiles,
+			statusCode:   http.StatusServiceUnavailable,
+			expected: HealthStatus{Status: healthNotReady, Checks: map[string]string{
+				"shutdown": healthOk, "nrf": "not registered", "udr": healthOk, "suciProfiles": healthOk,
+			}},
+		},
+		{
+			name:         "UDR Unreachable",
+			nrfDisabled:  true,
+			suciProfiles: suciProfiles,
+			statusCode:   http.StatusServiceUnavailable,
+			expected: HealthStatus{Status: healthNotReady, Checks: map[string]string{
+				"shutdown": healthOk, "nrf": healthDisabled, "udr": healthUnreachable, "suciProfiles": healthOk,
+			}},
+		},
+		{
+			name:         "SUCI Profiles Not Loaded",
+			nrfDisabled:  true,
+			udrReachable: true,
+			suciProfiles: unloadedProfiles,
+			statusCode:   http.StatusServiceUnavailable,
+			expected: HealthStatus{Status: healthNotReady, Checks: map[string]string{
+				"shutdown": healthOk, "nrf": healthDisabled, "udr": healthOk, "suciProfiles": "not loaded",
+			}},
+		},
+		{
+			name:         "SUCI Profile Missing",
+			nrfDisabled:  true,
+			udrReachable: true,
+			statusCode:   http.StatusServiceUnavailable,
+			expected: HealthStatus{Status: healthNotReady, Checks: map[string]string{
+				"shutdown": healthOk, "nrf": healthDisabled, "udr": healthOk, "suciProfiles": "not loaded",
+			}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			probe := gock.New("http://127.0.0.4:8000").
+				Get("/nudr-dr/v1/subscription-data/imsi-000000000000000/identity-data")
+			if tc.udrReachable {
+				probe.Reply(http.StatusNotFound).JSON(map[string]interface{}{"status": 404, "cause": "USER_NOT_FOUND"})
+			} else {
+				probe.ReplyError(http.ErrServerClosed)
+			}
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockApp := app.NewMockApp(ctrl)
+			mockApp.EXPECT().Context().AnyTimes().Return(&udm_context.UDMContext{
+				NrfDisabled:  tc.nrfDisabled,
+				StaticUdrs:   []factory.StaticUdr{{Uri: "http://127.0.0.4:8000"}},
+				SuciProfiles: tc.suciProfiles,
+			})
+			mockApp.EXPECT().Config().AnyTimes().Return(&factory.Config{
+				Configuration: &factory.Configuration{SuciProfiles: suciProfileCfg},
+			})
+			udrConsumer, err := consumer.NewConsumer(mockApp)
+			require.NoError(t, err)
+			s := &Server{
+				ServerUdm: &healthTestUdm{
+					routerTestUdm: &routerTestUdm{MockApp: mockApp},
+					consumer:      udrConsumer,
+				},
+			}
+			s.draining.Store(tc.draining)
+
+			router := gin.New()
+			router.GET("/readyz", s.HandleReadyz)
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			require.NoError(t, err)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.statusCode, w.Code)
+			var status HealthStatus
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+			require.Equal(t, tc.expected, status)
+			require.True(t, gock.IsDone())
+		})
+	}
+}
+
+// runTestUdm runs the server until its context is canceled
+type runTestUdm struct {
+	*healthTestUdm
+	ctx context.Context
+}
+
+func (u *runTestUdm) CancelContext() context.Context { return u.ctx }
+
+func TestRunServesProbesBeforeRegistration(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	// the NRF is unreachable: no request to it is mocked
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	mockApp.EXPECT().Context().AnyTimes().Return(&udm_context.UDMContext{
+		NfId:         "54804518-4191-46b3-955c-ac631f953ed8",
+		NrfUri:       "http://127.0.0.10:8000",
+		RegisterIPv4: "127.0.0.1",
+		StaticUdrs:   []factory.StaticUdr{{Uri: "http://127.0.0.4:8000"}},
+	})
+	mockApp.EXPECT().Config().AnyTimes().Return(&factory.Config{
+		Configuration: &factory.Configuration{Sbi: &factory.Sbi{
+			Scheme:      "http",
+			BindingIPv4: "127.0.0.1",
+			Port:        port,
+		}},
+	})
+	nrfConsumer, err := consumer.NewConsumer(mockApp)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	udm := &runTestUdm{
+		healthTestUdm: &healthTestUdm{routerTestUdm: &routerTestUdm{MockApp: mockApp}, consumer: nrfConsumer},
+		ctx:           ctx,
+	}
+	s, err := NewServer(udm, "")
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	require.NoError(t, s.Run(context.Background(), &wg))
+	defer func() {
+		cancel()
+		s.Stop()
+		wg.Wait()
+	}()
+
+	client := &http.Client{Timeout: time.Second}
+	get := func(path string) (*http.Response, error) {
+		return client.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+	}
+	var rsp *http.Response
+	require.Eventually(t, func() bool {
+		var getErr error
+		if rsp, getErr = get("/healthz"); getErr != nil {
+			return false
+		}
+		return rsp.Body.Close() == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+
+	rsp, err = get("/readyz")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, rsp.Body.Close())
+	}()
+	require.Equal(t, http.StatusServiceUnavailable, rsp.StatusCode)
+	var status HealthStatus
+	require.NoError(t, json.NewDecoder(rsp.Body).Decode(&status))
+	require.Equal(t, "not registered", status.Checks["nrf"])
+}
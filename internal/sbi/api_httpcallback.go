@@ -9,6 +9,7 @@ import (
 	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
 )
 
 func (s *Server) getHttpCallBackRoutes() []Route {
@@ -29,6 +30,18 @@ func (s *Server) getHttpCallBackRoutes() []Route {
 	}
 }
 
+// getNrfCallBackRoutes returns the routes of the notifications sent by the NRF
+func (s *Server) getNrfCallBackRoutes() []Route {
+	return []Route{
+		{
+			"NfStatusNotify",
+			strings.ToUpper("Post"),
+			factory.UdmNfStatusNotifyUri,
+			s.HandleNfStatusNotify,
+		},
+	}
+}
+
 func (s *Server) HandleDataChangeNotificationToNF(c *gin.Context) {
 	var dataChangeNotify models.DataChangeNotify
 	requestBody, err := c.GetRawData()
@@ -63,3 +76,36 @@ func (s *Server) HandleDataChangeNotificationToNF(c *gin.Context) {
 
 	s.Processor().DataChangeNotificationProcedure(c, dataChangeNotify.NotifyItems, supi)
 }
+
+func (s *Server) HandleNfStatusNotify(c *gin.Context) {
+	var notificationData models.NotificationData
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.CallbackLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&notificationData, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.CallbackLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	logger.CallbackLog.Infof("Handle NfStatusNotify")
+
+	s.Processor().NfStatusNotificationProcedure(c, notificationData)
+}
@@ -0,0 +1,40 @@
+package sbi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
+)
+
+func (s *Server) getManagementRoutes() []Route {
+	return []Route{
+		{
+			"ReloadConfig",
+			strings.ToUpper("Post"),
+			"/reload",
+			s.HandleReloadConfig,
+		},
+	}
+}
+
+// HandleReloadConfig reloads the config file, as SIGHUP does
+func (s *Server) HandleReloadConfig(c *gin.Context) {
+	logger.CfgLog.Infof("Handle ReloadConfig")
+
+	if err := s.ReloadConfig(); err != nil {
+		logger.CfgLog.Errorf("Reload config Error[%+v]", err)
+		problemDetails := models.ProblemDetails{
+			Title:  "Invalid config",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+			Cause:  "INVALID_CONFIG",
+		}
+		c.JSON(http.StatusBadRequest, problemDetails)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -312,6 +312,16 @@ func (s *Server) HandleGetTraceData(c *gin.Context) {
 	s.Processor().GetTraceDataProcedure(c, supi, plmnID)
 }
 
+// GetUeContextInAmfData - retrieve a UE's UE Context In AMF Data
+func (s *Server) HandleGetUeContextInAmfData(c *gin.Context) {
+	logger.SdmLog.Infof("Handle GetUeContextInAmfData")
+
+	supi := c.Params.ByName("supi")
+	supportedFeatures := c.Query("supported-features")
+
+	s.Processor().GetUeContextInAmfDataProcedure(c, supi, supportedFeatures)
+}
+
 // GetUeContextInSmfData - retrieve a UE's UE Context In SMF Data
 func (s *Server) HandleGetUeContextInSmfData(c *gin.Context) {
 	logger.SdmLog.Infof("Handle GetUeContextInSmfData")
@@ -550,6 +560,13 @@ func (s *Server) getTwoLayerRoutes() []Route {
 			s.HandleGetTraceData,
 		},
 
+		{
+			"GetUeContextInAmfData",
+			strings.ToUpper("Get"),
+			"/:supi/ue-context-in-amf-data",
+			s.HandleGetUeContextInAmfData,
+		},
+
 		{
 			"GetUeContextInSmfData",
 			strings.ToUpper("Get"),
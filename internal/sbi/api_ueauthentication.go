@@ -8,6 +8,7 @@ import (
 
 	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
 )
 
@@ -26,6 +27,27 @@ func (s *Server) getUEAuthenticationRoutes() []Route {
 			"/:supi/auth-events",
 			s.HandleConfirmAuth,
 		},
+
+		{
+			"DeleteAuth",
+			strings.ToUpper("Put"),
+			"/:supi/auth-events/:authEventId",
+			s.HandleDeleteAuth,
+		},
+
+		{
+			"GetAuthEvent",
+			strings.ToUpper("Get"),
+			"/:supi/auth-events/:authEventId",
+			s.HandleGetAuthEvent,
+		},
+
+		{
+			"GenerateGbaAv",
+			strings.ToUpper("Post"),
+			"/:supi/gba-security-information/generate-av",
+			s.HandleGenerateGbaAv,
+		},
 	}
 }
 
@@ -65,6 +87,53 @@ func (s *Server) HandleConfirmAuth(c *gin.Context) {
 	s.Processor().ConfirmAuthDataProcedure(c, authEvent, supi)
 }
 
+// DeleteAuth - Deletes the authentication result in the UDM
+func (s *Server) HandleDeleteAuth(c *gin.Context) {
+	var authEvent udm_context.AuthEvent
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.UeauLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&authEvent, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.UeauLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	supi := c.Params.ByName("supi")
+	authEventID := c.Params.ByName("authEventId")
+
+	logger.UeauLog.Infoln("Handle DeleteAuthDataRequest")
+
+	s.Processor().DeleteAuthDataProcedure(c, authEvent, supi, authEventID)
+}
+
+// GetAuthEvent - Retrieve a stored authentication event of the UE
+func (s *Server) HandleGetAuthEvent(c *gin.Context) {
+	logger.UeauLog.Infoln("Handle GetAuthEventRequest")
+
+	supi := c.Params.ByName("supi")
+	authEventID := c.Params.ByName("authEventId")
+
+	s.Processor().GetAuthEventProcedure(c, supi, authEventID)
+}
+
 // GenerateAuthData - Generate authentication data for the UE
 func (s *Server) HandleGenerateAuthData(c *gin.Context) {
 	var authInfoReq models.AuthenticationInfoRequest
@@ -102,6 +171,43 @@ func (s *Server) HandleGenerateAuthData(c *gin.Context) {
 	s.Processor().GenerateAuthDataProcedure(c, authInfoReq, supiOrSuci)
 }
 
+// GenerateGbaAv - Generate a GBA authentication vector for the UE
+func (s *Server) HandleGenerateGbaAv(c *gin.Context) {
+	var gbaAuthInfoReq udm_context.GbaAuthenticationInfoRequest
+
+	requestBody, err := c.GetRawData()
+	if err != nil {
+		problemDetail := models.ProblemDetails{
+			Title:  "System failure",
+			Status: http.StatusInternalServerError,
+			Detail: err.Error(),
+			Cause:  "SYSTEM_FAILURE",
+		}
+		logger.UeauLog.Errorf("Get Request Body error: %+v", err)
+		c.JSON(http.StatusInternalServerError, problemDetail)
+		return
+	}
+
+	err = openapi.Deserialize(&gbaAuthInfoReq, requestBody, "application/json")
+	if err != nil {
+		problemDetail := "[Request Body] " + err.Error()
+		rsp := models.ProblemDetails{
+			Title:  "Malformed request syntax",
+			Status: http.StatusBadRequest,
+			Detail: problemDetail,
+		}
+		logger.UeauLog.Errorln(problemDetail)
+		c.JSON(http.StatusBadRequest, rsp)
+		return
+	}
+
+	logger.UeauLog.Infoln("Handle GenerateGbaAvRequest")
+
+	supi := c.Params.ByName("supi")
+
+	s.Processor().GenerateGbaAvProcedure(c, gbaAuthInfoReq, supi)
+}
+
 func (s *Server) GenAuthDataHandlerFunc(c *gin.Context) {
 	c.Params = append(c.Params, gin.Param{Key: "supiOrSuci", Value: c.Param("supi")})
 	if strings.ToUpper("Post") == c.Request.Method {
@@ -6,6 +6,7 @@ import (
 	"github.com/free5gc/openapi/Nudm_SubscriberDataManagement"
 	"github.com/free5gc/openapi/Nudm_UEContextManagement"
 	"github.com/free5gc/openapi/Nudr_DataRepository"
+	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/udm/pkg/app"
 )
 
@@ -31,11 +32,13 @@ func NewConsumer(udm ConsumerUdm) (*Consumer, error) {
 		consumer:        c,
 		nfMngmntClients: make(map[string]*Nnrf_NFManagement.APIClient),
 		nfDiscClients:   make(map[string]*Nnrf_NFDiscovery.APIClient),
+		subscriptions:   make(map[models.NfType]*models.NrfSubscriptionData),
 	}
 
 	c.nudrService = &nudrService{
 		consumer:    c,
 		nfDRClients: make(map[string]*Nudr_DataRepository.APIClient),
+		udrCache:    newUdrCache(),
 	}
 
 	c.nudmService = &nudmService{
@@ -2,68 +2,243 @@ package consumer
 
 import (
 	"fmt"
-	"net/http"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/antihax/optional"
 
 	"github.com/free5gc/openapi/Nnrf_NFDiscovery"
 	"github.com/free5gc/openapi/models"
-	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
 	"github.com/free5gc/udm/internal/util"
 )
 
-func SendNFIntances(nrfUri string, targetNfType, requestNfType models.NfType,
-	param Nnrf_NFDiscovery.SearchNFInstancesParamOpts,
-) (result models.SearchResult, err error) {
-	configuration := Nnrf_NFDiscovery.NewConfiguration()
-	configuration.SetBasePath(nrfUri) // addr
-	clientNRF := Nnrf_NFDiscovery.NewAPIClient(configuration)
+const (
+	// used when the NRF does not provide the validityPeriod of the search result
+	defaultUdrValidityPeriod time.Duration = 60 * time.Second
+	// TS 29.510 6.1.6.2.2 default capacity
+	defaultUdrCapacity int32 = 100
+)
 
-	ctx, _, err := udm_context.GetSelf().GetTokenCtx(models.ServiceName_NNRF_DISC, models.NfType_NRF)
-	if err != nil {
-		return
+// udrCandidate is a UDR returned by the NF discovery
+type udrCandidate struct {
+	nfInstanceId string
+	uri          string
+	priority     int32
+	capacity     int32
+}
+
+type udrCacheEntry struct {
+	candidates []udrCandidate
+	expiry     time.Time
+}
+
+// udrCache keeps the UDR discovery results shared by all UEs until their validityPeriod expires.
+// The key is the discovery query, e.g. "1:imsi-208930000000001" for a SUPI. The UDRs are kept in
+// the order they are tried, so the identity stays on the selected UDR until it fails or the result
+// expires.
+type udrCache struct {
+	mu      sync.RWMutex
+	entries map[string]*udrCacheEntry
+}
+
+func newUdrCache() *udrCache {
+	return &udrCache{
+		entries: make(map[string]*udrCacheEntry),
 	}
+}
 
-	result, res, err1 := clientNRF.NFInstancesStoreApi.SearchNFInstances(ctx, targetNfType,
-		requestNfType, &param)
-	if err1 != nil {
-		err = err1
-		return
+func (c *udrCache) get(key string) ([]udrCandidate, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok || len(entry.candidates) == 0 || time.Now().After(entry.expiry) {
+		return nil, false
 	}
-	defer func() {
-		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
-			logger.ConsumerLog.Errorf("SearchNFInstances response body cannot close: %+v", rspCloseErr)
-		}
-	}()
+	return entry.candidates, true
+}
 
-	if res != nil && res.StatusCode == http.StatusTemporaryRedirect {
-		err = fmt.Errorf("Temporary Redirect For Non NRF Consumer")
+func (c *udrCache) set(key string, candidates []udrCandidate, validityPeriod time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = &udrCacheEntry{
+		candidates: candidates,
+		expiry:     time.Now().Add(validityPeriod),
+	}
+}
+
+// remove drops the UDRs matching the filter from every entry, and returns their URIs
+func (c *udrCache) remove(filter func(udrCandidate) bool) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var removed []string
+	for key, entry := range c.entries {
+		candidates := make([]udrCandidate, 0, len(entry.candidates))
+		for _, candidate := range entry.candidates {
+			if filter(candidate) {
+				removed = append(removed, candidate.uri)
+			} else {
+				candidates = append(candidates, candidate)
+			}
+		}
+		if len(candidates) == 0 {
+			delete(c.entries, key)
+		} else {
+			entry.candidates = candidates
+		}
 	}
-	return
+	return removed
 }
 
-func SendNFIntancesUDR(id string, types int) string {
-	self := udm_context.GetSelf()
-	targetNfType := models.NfType_UDR
-	requestNfType := models.NfType_UDM
-	localVarOptionals := Nnrf_NFDiscovery.SearchNFInstancesParamOpts{
-		// 	DataSet: optional.NewInterface(models.DataSetId_SUBSCRIPTION),
-	}
-	// switch types {
-	// case NFDiscoveryToUDRParamSupi:
-	// 	localVarOptionals.Supi = optional.NewString(id)
-	// case NFDiscoveryToUDRParamExtGroupId:
-	// 	localVarOptionals.ExternalGroupIdentity = optional.NewString(id)
-	// case NFDiscoveryToUDRParamGpsi:
-	// 	localVarOptionals.Gpsi = optional.NewString(id)
-	// }
-	fmt.Println(self.NrfUri)
-	result, err := SendNFIntances(self.NrfUri, targetNfType, requestNfType, localVarOptionals)
+// discoverUdrs searches the UDRs serving the identity with the NRF (TS 29.510 6.2.3.2.3.1). The
+// result is ordered by priority and capacity, and cached until its validityPeriod expires.
+func (s *nudrService) discoverUdrs(id string, types int) ([]udrCandidate, error) {
+	key := fmt.Sprintf("%d:%s", types, id)
+	if candidates, ok := s.udrCache.get(key); ok {
+		return candidates, nil
+	}
+
+	param := Nnrf_NFDiscovery.SearchNFInstancesParamOpts{
+		ServiceNames: optional.NewInterface([]models.ServiceName{models.ServiceName_NUDR_DR}),
+		DataSet:      optional.NewInterface(models.DataSetId_SUBSCRIPTION),
+	}
+	switch types {
+	case NFDiscoveryToUDRParamSupi:
+		param.Supi = optional.NewString(id)
+	case NFDiscoveryToUDRParamExtGroupId:
+		param.ExternalGroupIdentity = optional.NewString(id)
+	case NFDiscoveryToUDRParamGpsi:
+		param.Gpsi = optional.NewString(id)
+	}
+
+	udmContext := s.consumer.Context()
+	result, err := s.consumer.SendSearchNFInstances(udmContext.GetNrfUri(), models.NfType_UDR, models.NfType_UDM, param)
 	if err != nil {
-		logger.ConsumerLog.Error(err.Error())
-		return ""
+		return nil, err
 	}
+
+	var candidates []udrCandidate
 	for _, profile := range result.NfInstances {
-		return util.SearchNFServiceUri(profile, models.ServiceName_NUDR_DR, models.NfServiceStatus_REGISTERED)
+		uri := util.SearchNFServiceUri(profile, models.ServiceName_NUDR_DR, models.NfServiceStatus_REGISTERED)
+		if uri == "" {
+			continue
+		}
+		candidate := udrCandidate{
+			nfInstanceId: profile.NfInstanceId,
+			uri:          uri,
+			priority:     profile.Priority,
+			capacity:     profile.Capacity,
+		}
+		// the priority and capacity of the service take precedence over the ones of the NF
+		if profile.NfServices != nil {
+			for _, service := range *profile.NfServices {
+				if service.ServiceName != models.ServiceName_NUDR_DR {
+					continue
+				}
+				if service.Priority != 0 {
+					candidate.priority = service.Priority
+				}
+				if service.Capacity != 0 {
+					candidate.capacity = service.Capacity
+				}
+				break
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("No UDR found for [%s]", id)
+	}
+
+	validityPeriod := defaultUdrValidityPeriod
+	if result.ValidityPeriod > 0 {
+		validityPeriod = time.Duration(result.ValidityPeriod) * time.Second
+	}
+	candidates = orderUdrCandidates(candidates)
+	s.udrCache.set(key, candidates, validityPeriod)
+	logger.ConsumerLog.Debugf("Discovered %d UDR(s) for [%s], valid for %s", len(candidates), id, validityPeriod)
+
+	return candidates, nil
+}
+
+// orderUdrCandidates sorts the UDRs by priority (lower values first). The UDRs of the same priority
+// are shuffled with their capacity as weight, so the load is shared among them (TS 29.510 6.1.6.2.2).
+func orderUdrCandidates(candidates []udrCandidate) []udrCandidate {
+	ordered := make([]udrCandidate, 0, len(candidates))
+	remaining := append([]udrCandidate{}, candidates...)
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return remaining[i].priority < remaining[j].priority
+	})
+
+	for len(remaining) > 0 {
+		// the candidates of the lowest remaining priority
+		n := 1
+		for n < len(remaining) && remaining[n].priority == remaining[0].priority {
+			n++
+		}
+		group := remaining[:n]
+
+		var total int64
+		for _, candidate := range group {
+			total += int64(udrCapacity(candidate))
+		}
+		pick := rand.Int63n(total) // #nosec G404 -- load balancing only
+		i := 0
+		for ; i < len(group)-1; i++ {
+			pick -= int64(udrCapacity(group[i]))
+			if pick < 0 {
+				break
+			}
+		}
+
+		ordered = append(ordered, group[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return ordered
+}
+
+func udrCapacity(candidate udrCandidate) int32 {
+	if candidate.capacity <= 0 {
+		return defaultUdrCapacity
+	}
+	return candidate.capacity
+}
+
+// InvalidateUdr removes a UDR from the discovery cache and drops its clients, e.g. when the NRF
+// notifies that the UDR is deregistered or its profile has changed
+func (s *nudrService) InvalidateUdr(nfInstanceId string) {
+	uris := s.udrCache.remove(func(candidate udrCandidate) bool {
+		return candidate.nfInstanceId == nfInstanceId
+	})
+	if len(uris) == 0 {
+		return
+	}
+	s.removeUdrClients(uris)
+	logger.ConsumerLog.Infof("UDR [%s] is removed from the discovery cache", nfInstanceId)
+}
+
+// removeUnreachableUdr removes the UDR from the discovery cache after a request to it has failed,
+// so the other UDRs are used until the next discovery
+func (s *nudrService) removeUnreachableUdr(uri string) {
+	uris := s.udrCache.remove(func(candidate udrCandidate) bool {
+		return candidate.uri == uri
+	})
+	if len(uris) > 0 {
+		s.removeUdrClients([]string{uri})
+		logger.ConsumerLog.Warnf("UDR [%s] is unreachable, removed from the discovery cache", uri)
+	}
+}
+
+// removeUdrClients drops the clients of the UDRs
+func (s *nudrService) removeUdrClients(uris []string) {
+	s.nfDRMu.Lock()
+	defer s.nfDRMu.Unlock()
+	for _, uri := range uris {
+		delete(s.nfDRClients, uri)
 	}
-	return ""
 }
@@ -0,0 +1,287 @@
+package consumer
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/antihax/optional"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/Nudr_DataRepository"
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/pkg/app"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+func udrProfile(nfInstanceId, ipv4 string, priority int32) map[string]interface{} {
+	return map[string]interface{}{
+		"nfInstanceId": nfInstanceId,
+		"nfType":       "UDR",
+		"nfStatus":     "REGISTERED",
+		"priority":     priority,
+		"nfServices": []interface{}{
+			map[string]interface{}{
+				"serviceInstanceId": "datarepository",
+				"serviceName":       "nudr-dr",
+				"scheme":            "http",
+				"nfServiceStatus":   "REGISTERED",
+				"ipEndPoints": []interface{}{
+					map[string]interface{}{"ipv4Address": ipv4, "port": 8000},
+				},
+			},
+		},
+	}
+}
+
+func TestUdrDiscovery(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	supi := "imsi-208930000000001"
+	gock.New("http://127.0.0.10:8000").
+		Get("/nnrf-disc/v1/nf-instances").
+		MatchParam("target-nf-type", "UDR").
+		MatchParam("requester-nf-type", "UDM").
+		MatchParam("supi", supi).
+		MatchParam("data-set", "SUBSCRIPTION").
+		Times(1).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"validityPeriod": 100,
+			"nfInstances": []interface{}{
+				udrProfile("udr-2", "127.0.0.5", 2),
+				udrProfile("udr-1", "127.0.0.4", 1),
+			},
+		})
+	// the UDR with the highest priority is down
+	gock.New("http://127.0.0.4:8000").
+		Delete("/nudr-dr/v1/subscription-data/" + supi + "/authentication-data/authentication-status").
+		ReplyError(http.ErrServerClosed)
+	gock.New("http://127.0.0.5:8000").
+		Delete("/nudr-dr/v1/subscription-data/" + supi + "/authentication-data/authentication-status").
+		Times(2).
+		Reply(204)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfUri: "http://127.0.0.10:8000",
+		},
+	)
+
+	require.Equal(t, []string{"http://127.0.0.4:8000", "http://127.0.0.5:8000"}, consumer.getUdrURIs(supi))
+
+	// falls back to the other UDR, which is used until the next discovery
+	rsp, err := consumer.DeleteAuthenticationStatus(context.TODO(), supi)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, rsp.StatusCode)
+	rsp, err = consumer.DeleteAuthenticationStatus(context.TODO(), supi)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, rsp.StatusCode)
+	require.True(t, gock.IsDone())
+
+	consumer.InvalidateUdr("udr-2")
+	_, ok := consumer.udrCache.get("1:" + supi)
+	require.False(t, ok)
+}
+
+func TestOrderUdrCandidates(t *testing.T) {
+	candidates := []udrCandidate{
+		{uri: "c", priority: 3},
+		{uri: "a1", priority: 1, capacity: 100},
+		{uri: "b", priority: 2},
+		{uri: "a2", priority: 1, capacity: 100},
+	}
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		ordered := orderUdrCandidates(candidates)
+		require.Len(t, ordered, 4)
+		require.ElementsMatch(t, []string{"a1", "a2"}, []string{ordered[0].uri, ordered[1].uri})
+		require.Equal(t, "b", ordered[2].uri)
+		require.Equal(t, "c", ordered[3].uri)
+		counts[ordered[0].uri]++
+	}
+	// the UDRs of the same priority and capacity share the load
+	require.InDelta(t, 500, counts["a1"], 100)
+}
+
+func TestStaticUdrURIs(t *testing.T) {
+	udrs := []factory.StaticUdr{
+		{
+			Uri: "http://127.0.0.4:8000",
+			SupiRanges: []factory.IdentityRange{
+				{Start: "208930000000000", End: "208930000004999"},
+			},
+		},
+		{
+			Uri: "http://127.0.0.5:8000",
+			SupiRanges: []factory.IdentityRange{
+				{Start: "208930000005000", End: "208930000009999"},
+				{Pattern: "^nai-.+@example\\.com$"},
+			},
+		},
+		{Uri: "http://127.0.0.6:8000"},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	// no NRF discovery is sent
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfDisabled: true,
+			StaticUdrs:  udrs,
+		},
+	)
+
+	testCases := []struct {
+		id       string
+		expected []string
+	}{
+		{"imsi-208930000000001", []string{"http://127.0.0.4:8000", "http://127.0.0.6:8000"}},
+		{"imsi-208930000005000", []string{"http://127.0.0.5:8000", "http://127.0.0.6:8000"}},
+		{"imsi-208930000010000", []string{"http://127.0.0.6:8000"}},
+		{"nai-alice@example.com", []string{"http://127.0.0.5:8000", "http://127.0.0.6:8000"}},
+		{"msisdn-886912345678", []string{"http://127.0.0.6:8000"}},
+	}
+	for _, tc := range testCases {
+		require.Equal(t, tc.expected, consumer.getUdrURIs(tc.id), tc.id)
+	}
+}
+
+func TestUdrFallback(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	supi := "imsi-208930000000002"
+	gock.New("http://127.0.0.10:8000").
+		Get("/nnrf-disc/v1/nf-instances").
+		MatchParam("supi", supi).
+		Times(1).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"validityPeriod": 100,
+			"nfInstances": []interface{}{
+				udrProfile("udr-1", "127.0.0.4", 1),
+				udrProfile("udr-2", "127.0.0.5", 2),
+			},
+		})
+	// the UDR with the highest priority is down
+	gock.New("http://127.0.0.4:8000").
+		Put("/nudr-dr/v1/subscription-data/" + supi + "/context-data/amf-3gpp-access").
+		ReplyError(http.ErrServerClosed)
+	gock.New("http://127.0.0.5:8000").
+		Put("/nudr-dr/v1/subscription-data/" + supi + "/context-data/amf-3gpp-access").
+		BodyString(`"amfInstanceId":"amf-1"`).
+		Times(2).
+		Reply(204)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+	openapi.GetHttpClient().Transport = &udrFallbackTransport{
+		udr:  consumer.nudrService,
+		next: openapi.GetHttpClient().Transport,
+	}
+
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfUri: "http://127.0.0.10:8000",
+		},
+	)
+
+	registration := &Nudr_DataRepository.CreateAmfContext3gppParamOpts{
+		Amf3GppAccessRegistration: optional.NewInterface(models.Amf3GppAccessRegistration{AmfInstanceId: "amf-1"}),
+	}
+	for i := 0; i < 2; i++ {
+		client, clientErr := consumer.CreateUDMClientToUDR(supi)
+		require.NoError(t, clientErr)
+		rsp, rspErr := client.AMF3GPPAccessRegistrationDocumentApi.CreateAmfContext3gpp(
+			WithUdrIdentity(context.TODO(), supi), supi, registration)
+		require.NoError(t, rspErr)
+		require.Equal(t, http.StatusNoContent, rsp.StatusCode)
+	}
+	require.True(t, gock.IsDone())
+
+	// the unreachable UDR is not selected again until the next discovery
+	require.Equal(t, []string{"http://127.0.0.5:8000"}, consumer.getUdrURIs(supi))
+	ue, ok := udm_context.GetSelf().UdmUeFindBySupi(supi)
+	require.True(t, ok)
+	require.Equal(t, "http://127.0.0.5:8000", ue.GetUdrUri())
+
+	// the UE stays on the selected UDR after the next discovery
+	gock.New("http://127.0.0.10:8000").
+		Get("/nnrf-disc/v1/nf-instances").
+		MatchParam("supi", supi).
+		Times(1).
+		Reply(200).
+		JSON(map[string]interface{}{
+			"validityPeriod": 100,
+			"nfInstances": []interface{}{
+				udrProfile("udr-1", "127.0.0.4", 1),
+				udrProfile("udr-2", "127.0.0.5", 2),
+			},
+		})
+	consumer.udrCache.remove(func(udrCandidate) bool { return true })
+	require.Equal(t, []string{"http://127.0.0.5:8000", "http://127.0.0.4:8000"}, consumer.getUdrURIs(supi))
+	require.True(t, gock.IsDone())
+}
+
+func TestProbeUdr(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	gock.New("http://127.0.0.4:8000").
+		Get("/nudr-dr/v1/subscription-data/" + udrProbeUeId + "/identity-data").
+		Reply(404).
+		JSON(map[string]interface{}{"status": 404, "cause": "USER_NOT_FOUND"})
+	gock.New("http://127.0.0.4:8000").
+		Get("/nudr-dr/v1/subscription-data/" + udrProbeUeId + "/identity-data").
+		ReplyError(http.ErrServerClosed)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfDisabled: true,
+			StaticUdrs:  []factory.StaticUdr{{Uri: "http://127.0.0.4:8000"}},
+		},
+	)
+
+	// the UDR answers, even with an error status
+	require.NoError(t, consumer.ProbeUdr(context.TODO()))
+
+	// the result is reused until the probe interval expires
+	require.NoError(t, consumer.ProbeUdr(context.TODO()))
+	require.False(t, gock.IsDone())
+	consumer.probedAt = time.Now().Add(-udrProbeInterval)
+	require.Error(t, consumer.ProbeUdr(context.TODO()))
+	require.True(t, gock.IsDone())
+}
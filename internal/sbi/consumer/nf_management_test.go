@@ -2,15 +2,19 @@ package consumer
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/h2non/gock"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 
 	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/pkg/app"
+	"github.com/free5gc/udm/pkg/factory"
 )
 
 func TestSendRegisterNFInstance(t *testing.T) {
@@ -40,3 +44,205 @@ func TestSendRegisterNFInstance(t *testing.T) {
 	_, _, err = consumer.RegisterNFInstance(context.TODO())
 	require.NoError(t, err)
 }
+
+func TestSendHeartbeat(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	gock.New("http://127.0.0.10:8000").
+		Patch("/nnrf-nfm/v1/nf-instances/1").
+		Reply(200).
+		JSON(map[string]interface{}{"heartBeatTimer": 10})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfUri: "http://127.0.0.10:8000",
+			NfId:   "1",
+		},
+	)
+
+	require.Equal(t, 60*time.Second, consumer.heartBeatInterval())
+	err = consumer.sendHeartbeat(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Second, consumer.heartBeatInterval())
+	require.True(t, gock.IsDone())
+}
+
+func TestSendNFStatusUndiscoverable(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	gock.New("http://127.0.0.10:8000").
+		Patch("/nnrf-nfm/v1/nf-instances/1").
+		BodyString(`"/nfStatus".*"UNDISCOVERABLE"`).
+		Reply(204)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfUri: "http://127.0.0.10:8000",
+			NfId:   "1",
+		},
+	)
+
+	consumer.registered.Store(true)
+	err = consumer.SendNFStatusUndiscoverable(context.TODO())
+	require.NoError(t, err)
+	require.False(t, consumer.IsNFRegistered())
+	require.True(t, gock.IsDone())
+}
+
+func TestSendHeartbeatReRegister(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	gock.New("http://127.0.0.10:8000").
+		Patch("/nnrf-nfm/v1/nf-instances/1").
+		Reply(404).
+		JSON(map[string]interface{}{"status": 404, "cause": "RESOURCE_NOT_FOUND"})
+	gock.New("http://127.0.0.10:8000").
+		Put("/nnrf-nfm/v1/nf-instances/1").
+		Reply(201).
+		SetHeader("Location", "http://127.0.0.10:8000/nnrf-nfm/v1/nf-instances/1").
+		JSON(map[string]interface{}{"heartBeatTimer": 20})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfUri: "http://127.0.0.10:8000",
+			NfId:   "1",
+		},
+	)
+
+	err = consumer.sendHeartbeat(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, 20*time.Second, consumer.heartBeatInterval())
+	require.True(t, consumer.IsNFRegistered())
+	require.True(t, gock.IsDone())
+}
+
+func TestRegisterNFInstanceCanceled(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	gock.New("http://127.0.0.10:8000").
+		Put("/nnrf-nfm/v1/nf-instances/1").
+		Persist().
+		Reply(500)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	mockApp.EXPECT().Context().Times(1).Return(
+		&udm_context.UDMContext{
+			NrfUri: "http://127.0.0.10:8000",
+			NfId:   "1",
+		},
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, _, err = consumer.RegisterNFInstance(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestBuildNfProfileUdmInfo(t *testing.T) {
+	udmContext := &udm_context.UDMContext{
+		NfId:         "1",
+		RegisterIPv4: "127.0.0.3",
+		UdmInfo: udm_context.NewUdmInfo(&factory.UdmInfo{
+			GroupId: "udm-set-1",
+			SupiRanges: []factory.IdentityRange{
+				{Start: "208930000000000", End: "208930000009999"},
+			},
+			GpsiRanges: []factory.IdentityRange{
+				{Pattern: "^msisdn-88691[0-9]{7}$"},
+			},
+			RoutingIndicators: []string{"0", "12"},
+			InternalGroupIdentifiersRanges: []factory.InternalGroupIdRange{
+				{Start: "00000001-208-93-01", End: "00000001-208-93-ff"},
+			},
+		}),
+	}
+
+	consumer, err := NewConsumer(nil)
+	require.NoError(t, err)
+	profile, err := consumer.buildNfProfile(udmContext)
+	require.NoError(t, err)
+
+	body, err := json.Marshal(profile)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	require.Equal(t, map[string]interface{}{
+		"groupId": "udm-set-1",
+		"supiRanges": []interface{}{
+			map[string]interface{}{"start": "208930000000000", "end": "208930000009999"},
+		},
+		"gpsiRanges": []interface{}{
+			map[string]interface{}{"pattern": "^msisdn-88691[0-9]{7}$"},
+		},
+		"routingIndicators": []interface{}{"0", "12"},
+		"internalGroupIdentifiersRanges": []interface{}{
+			map[string]interface{}{"start": "00000001-208-93-01", "end": "00000001-208-93-ff"},
+		},
+	}, decoded["udmInfo"])
+}
+
+func TestBuildNfProfileDualStack(t *testing.T) {
+	udmContext := &udm_context.UDMContext{
+		NfId:         "1",
+		UriScheme:    models.UriScheme_HTTP,
+		SBIPort:      8000,
+		RegisterIPv4: "127.0.0.3",
+		RegisterIPv6: "2001:db8::3",
+	}
+	udmContext.InitNFService([]string{"nudm-sdm"}, "1.0.3")
+
+	consumer, err := NewConsumer(nil)
+	require.NoError(t, err)
+	profile, err := consumer.buildNfProfile(udmContext)
+	require.NoError(t, err)
+	require.Equal(t, []string{"127.0.0.3"}, profile.Ipv4Addresses)
+	require.Equal(t, []string{"2001:db8::3"}, profile.Ipv6Addresses)
+	require.Len(t, *profile.NfServices, 1)
+	service := (*profile.NfServices)[0]
+	require.Equal(t, "http://127.0.0.3:8000", service.ApiPrefix)
+	require.Equal(t, []models.IpEndPoint{
+		{Ipv4Address: "127.0.0.3", Transport: models.TransportProtocol_TCP, Port: 8000},
+		{Ipv6Address: "2001:db8::3", Transport: models.TransportProtocol_TCP, Port: 8000},
+	}, *service.IpEndPoints)
+
+	// an IPv6 only UDM
+	udmContext.RegisterIPv4 = ""
+	require.Equal(t, "http://[2001:db8::3]:8000", udmContext.GetIPUri())
+	udmContext.Fqdn = "udm.5gc.mnc093.mcc208.3gppnetwork.org"
+	require.Equal(t, "http://udm.5gc.mnc093.mcc208.3gppnetwork.org:8000", udmContext.GetIPUri())
+}
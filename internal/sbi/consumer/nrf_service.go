@@ -3,9 +3,12 @@ package consumer
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -16,11 +19,26 @@ import (
 	"github.com/free5gc/openapi/models"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/tracing"
+)
+
+const (
+	registerRetryInterval time.Duration = 2 * time.Second
+	// heartbeat period in seconds, used until the NRF provides the heartBeatTimer
+	defaultHeartBeatTimer int32 = 60
 )
 
 type nnrfService struct {
 	consumer *Consumer
 
+	// heartbeat period in seconds negotiated with the NRF
+	heartBeatTimer atomic.Int32
+	// whether the NF instance is registered in the NRF
+	registered atomic.Bool
+
+	subscriptionsMu sync.Mutex
+	subscriptions   map[models.NfType]*models.NrfSubscriptionData
+
 	nfMngmntMu sync.RWMutex
 	nfDiscMu   sync.RWMutex
 
@@ -79,7 +97,7 @@ func (s *nnrfService) SendSearchNFInstances(
 	// Set client and set url
 	udmContext := s.consumer.Context()
 
-	client := s.getNFDiscClient(udmContext.NrfUri)
+	client := s.getNFDiscClient(udmContext.GetNrfUri())
 
 	ctx, _, err := udm_context.GetSelf().GetTokenCtx(models.ServiceName_NNRF_DISC, models.NfType_NRF)
 	if err != nil {
@@ -87,14 +105,17 @@ func (s *nnrfService) SendSearchNFInstances(
 	}
 
 	result, res, err := client.NFInstancesStoreApi.SearchNFInstances(ctx, targetNfType, requestNfType, &param)
+	if res != nil {
+		defer func() {
+			if resCloseErr := res.Body.Close(); resCloseErr != nil {
+				logger.ConsumerLog.Errorf("NFInstancesStoreApi response body cannot close: %+v", resCloseErr)
+			}
+		}()
+	}
 	if err != nil {
 		logger.ConsumerLog.Errorf("SearchNFInstances failed: %+v", err)
+		return nil, err
 	}
-	defer func() {
-		if resCloseErr := res.Body.Close(); resCloseErr != nil {
-			logger.ConsumerLog.Errorf("NFInstancesStoreApi response body cannot close: %+v", resCloseErr)
-		}
-	}()
 	if res != nil && res.StatusCode == http.StatusTemporaryRedirect {
 		return nil, fmt.Errorf("Temporary Redirect For Non NRF Consumer")
 	}
@@ -102,21 +123,26 @@ func (s *nnrfService) SendSearchNFInstances(
 	return &result, nil
 }
 
-func (s *nnrfService) SendDeregisterNFInstance() (problemDetails *models.ProblemDetails, err error) {
+func (s *nnrfService) SendDeregisterNFInstance(ctx context.Context) (
+	problemDetails *models.ProblemDetails, err error,
+) {
 	logger.ConsumerLog.Infof("Send Deregister NFInstance")
 
-	ctx, pd, err := udm_context.GetSelf().GetTokenCtx(models.ServiceName_NNRF_NFM, models.NfType_NRF)
+	tokenCtx, pd, err := udm_context.GetSelf().GetTokenCtx(models.ServiceName_NNRF_NFM, models.NfType_NRF)
 	if err != nil {
 		return pd, err
 	}
+	ctx, cancel := mergeCancel(tokenCtx, ctx)
+	defer cancel()
 
 	udmContext := s.consumer.Context()
-	client := s.getNFManagementClient(udmContext.NrfUri)
+	client := s.getNFManagementClient(udmContext.GetNrfUri())
 
 	var res *http.Response
 
 	res, err = client.NFInstanceIDDocumentApi.DeregisterNFInstance(ctx, udmContext.NfId)
 	if err == nil {
+		s.registered.Store(false)
 		return problemDetails, err
 	} else if res != nil {
 		defer func() {
@@ -127,8 +153,11 @@ func (s *nnrfService) SendDeregisterNFInstance() (problemDetails *models.Problem
 		if res.Status != err.Error() {
 			return problemDetails, err
 		}
-		problem := err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails)
-		problemDetails = &problem
+		if apiErr, ok := err.(openapi.GenericOpenAPIError); ok {
+			if problem, ok := apiErr.Model().(models.ProblemDetails); ok {
+				problemDetails = &problem
+			}
+		}
 	} else {
 		err = openapi.ReportError("server no response")
 	}
@@ -138,8 +167,10 @@ func (s *nnrfService) SendDeregisterNFInstance() (problemDetails *models.Problem
 func (s *nnrfService) RegisterNFInstance(ctx context.Context) (
 	resouceNrfUri string, retrieveNfInstanceID string, err error,
 ) {
+	ctx, span := tracing.Start(ctx, "RegisterNFInstance")
+	defer span.End()
+
 	udmContext := s.consumer.Context()
-	client := s.getNFManagementClient(udmContext.NrfUri)
 	nfProfile, err := s.buildNfProfile(udmContext)
 	if err != nil {
 		return "", "", errors.Wrap(err, "RegisterNFInstance buildNfProfile()")
@@ -148,26 +179,30 @@ func (s *nnrfService) RegisterNFInstance(ctx context.Context) (
 	var nf models.NfProfile
 	var res *http.Response
 	for {
-		nf, res, err = client.NFInstanceIDDocumentApi.RegisterNFInstance(ctx, udmContext.NfId, nfProfile)
+		nf, res, err = s.putNFInstance(ctx, udmContext.GetNrfUri(), udmContext.NfId, &nfProfile)
 		if err != nil || res == nil {
 			logger.ConsumerLog.Errorf("UDM register to NRF Error[%v]", err)
-			time.Sleep(2 * time.Second)
+			if waitErr := waitRetry(ctx, registerRetryInterval); waitErr != nil {
+				return "", "", waitErr
+			}
 			continue
 		}
-		defer func() {
-			if resCloseErr := res.Body.Close(); resCloseErr != nil {
-				logger.ConsumerLog.Errorf("RegisterNFInstance response body cannot close: %+v", resCloseErr)
-			}
-		}()
+		if resCloseErr := res.Body.Close(); resCloseErr != nil {
+			logger.ConsumerLog.Errorf("RegisterNFInstance response body cannot close: %+v", resCloseErr)
+		}
 		status := res.StatusCode
 		if status == http.StatusOK {
 			// NFUpdate
+			s.setHeartBeatTimer(nf.HeartBeatTimer)
+			s.registered.Store(true)
 			break
 		} else if status == http.StatusCreated {
 			// NFRegister
 			resourceUri := res.Header.Get("Location")
 			resouceNrfUri = resourceUri[:strings.Index(resourceUri, "/nnrf-nfm/")]
 			retrieveNfInstanceID = resourceUri[strings.LastIndex(resourceUri, "/")+1:]
+			s.setHeartBeatTimer(nf.HeartBeatTimer)
+			s.registered.Store(true)
 
 			oauth2 := false
 			if nf.CustomInfo != nil {
@@ -184,36 +219,212 @@ func (s *nnrfService) RegisterNFInstance(ctx context.Context) (
 
 			break
 		} else {
-			fmt.Println(fmt.Errorf("handler returned wrong status code %d", status))
-			fmt.Println("NRF return wrong status code", status)
+			logger.ConsumerLog.Errorf("NRF return wrong status code %d", status)
+			if waitErr := waitRetry(ctx, registerRetryInterval); waitErr != nil {
+				return "", "", waitErr
+			}
 		}
 	}
 	return resouceNrfUri, retrieveNfInstanceID, err
 }
 
-func (s *nnrfService) buildNfProfile(udmContext *udm_context.UDMContext) (profile models.NfProfile, err error) {
+// HeartbeatNFInstance sends NFUpdate (PATCH /nfStatus) to the NRF every HeartBeatTimer negotiated in
+// RegisterNFInstance, so the NRF does not mark the UDM as SUSPENDED (TS 29.510 5.2.2.3.2). If the NRF
+// no longer knows the NF instance, the UDM registers again. It returns when ctx is done.
+func (s *nnrfService) HeartbeatNFInstance(ctx context.Context) {
+	timer := time.NewTimer(s.heartBeatInterval())
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.ConsumerLog.Infof("Stop NF heartbeat")
+			return
+		case <-timer.C:
+		}
+
+		if err := s.sendHeartbeat(ctx); err != nil {
+			logger.ConsumerLog.Errorf("NF heartbeat Error[%+v]", err)
+		}
+		timer.Reset(s.heartBeatInterval())
+	}
+}
+
+func (s *nnrfService) sendHeartbeat(ctx context.Context) error {
+	nf, res, err := s.updateNFStatus(ctx, models.NfStatus_REGISTERED)
+	if err == nil {
+		if res.StatusCode == http.StatusOK {
+			// the NRF returns the whole profile when it has changed anything, e.g. the heartBeatTimer
+			s.setHeartBeatTimer(nf.HeartBeatTimer)
+		}
+		logger.ConsumerLog.Tracef("NF heartbeat status code %d", res.StatusCode)
+		return nil
+	}
+	if res == nil || res.StatusCode != http.StatusNotFound {
+		return err
+	}
+
+	s.registered.Store(false)
+	logger.ConsumerLog.Warnf("NF instance [%s] not found in NRF, register again", s.consumer.Context().NfId)
+	if _, _, err = s.RegisterNFInstance(ctx); err != nil {
+		return errors.Wrap(err, "re-register to NRF")
+	}
+	logger.ConsumerLog.Infof("Re-register to NRF successfully")
+	return nil
+}
+
+// SendNFStatusUndiscoverable marks the NF instance UNDISCOVERABLE in the NRF, so the other NFs do
+// not select the UDM any more, e.g. before the shutdown
+func (s *nnrfService) SendNFStatusUndiscoverable(ctx context.Context) error {
+	_, _, err := s.updateNFStatus(ctx, models.NfStatus_UNDISCOVERABLE)
+	if err == nil {
+		s.registered.Store(false)
+	}
+	return err
+}
+
+// IsNFRegistered returns whether the NF instance is registered in the NRF and discoverable
+func (s *nnrfService) IsNFRegistered() bool {
+	return s.registered.Load()
+}
+
+// updateNFStatus sends NFUpdate (PATCH /nfStatus) to the NRF
+func (s *nnrfService) updateNFStatus(ctx context.Context, nfStatus models.NfStatus) (
+	models.NfProfile, *http.Response, error,
+) {
+	udmContext := s.consumer.Context()
+	client := s.getNFManagementClient(udmContext.GetNrfUri())
+
+	tokenCtx, _, err := udmContext.GetTokenCtx(models.ServiceName_NNRF_NFM, models.NfType_NRF)
+	if err != nil {
+		return models.NfProfile{}, nil, err
+	}
+	reqCtx, cancel := mergeCancel(tokenCtx, ctx)
+	defer cancel()
+
+	patchItems := []models.PatchItem{
+		{
+			Op:    models.PatchOperation_REPLACE,
+			Path:  "/nfStatus",
+			Value: nfStatus,
+		},
+	}
+	return client.NFInstanceIDDocumentApi.UpdateNFInstance(reqCtx, udmContext.NfId, patchItems)
+}
+
+// mergeCancel returns the token context of GetTokenCtx, which is also cancelled when ctx is done and
+// carries the span of ctx
+func mergeCancel(tokenCtx, ctx context.Context) (context.Context, context.CancelFunc) {
+	reqCtx, cancel := context.WithCancel(tracing.WithSpan(tokenCtx, ctx))
+	stop := context.AfterFunc(ctx, cancel)
+	return reqCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (s *nnrfService) setHeartBeatTimer(heartBeatTimer int32) {
+	if heartBeatTimer > 0 {
+		s.heartBeatTimer.Store(heartBeatTimer)
+	}
+}
+
+func (s *nnrfService) heartBeatInterval() time.Duration {
+	heartBeatTimer := s.heartBeatTimer.Load()
+	if heartBeatTimer <= 0 {
+		heartBeatTimer = defaultHeartBeatTimer
+	}
+	return time.Duration(heartBeatTimer) * time.Second
+}
+
+// waitRetry waits for the interval, or returns the error of ctx if it is done first
+func waitRetry(ctx context.Context, interval time.Duration) error {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// putNFInstance is NFInstanceIDDocumentApi.RegisterNFInstance sending the NfProfile with the
+// Rel-16 UdmInfo, which models.NfProfile cannot carry
+func (s *nnrfService) putNFInstance(ctx context.Context, nrfUri, nfInstanceID string,
+	nfProfile *udm_context.NfProfile,
+) (models.NfProfile, *http.Response, error) {
+	var nf models.NfProfile
+
+	cfg := Nnrf_NFManagement.NewConfiguration()
+	cfg.SetBasePath(nrfUri)
+	headerParams := map[string]string{
+		"Content-Type": "application/json",
+		"Accept":       "application/json, application/problem+json",
+	}
+
+	req, err := openapi.PrepareRequest(ctx, cfg, cfg.BasePath()+"/nf-instances/"+nfInstanceID, http.MethodPut,
+		nfProfile, headerParams, url.Values{}, url.Values{}, "", "", nil)
+	if err != nil {
+		return nf, nil, err
+	}
+
+	rsp, err := openapi.CallAPI(cfg, req)
+	if err != nil || rsp == nil {
+		return nf, rsp, err
+	}
+
+	body, err := io.ReadAll(rsp.Body)
+	if closeErr := rsp.Body.Close(); closeErr != nil {
+		logger.ConsumerLog.Errorf("RegisterNFInstance response body cannot close: %+v", closeErr)
+	}
+	if err != nil {
+		return nf, rsp, err
+	}
+
+	apiError := openapi.GenericOpenAPIError{
+		RawBody:     body,
+		ErrorStatus: rsp.Status,
+	}
+	switch rsp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		if err = openapi.Deserialize(&nf, body, rsp.Header.Get("Content-Type")); err != nil {
+			logger.ConsumerLog.Warnf("RegisterNFInstance response body cannot be decoded: %+v", err)
+		}
+		return nf, rsp, nil
+	}
+
+	var problem models.ProblemDetails
+	if err = openapi.Deserialize(&problem, body, rsp.Header.Get("Content-Type")); err != nil {
+		apiError.ErrorStatus = err.Error()
+		return nf, rsp, apiError
+	}
+	apiError.ErrorModel = problem
+	return nf, rsp, apiError
+}
+
+func (s *nnrfService) buildNfProfile(udmContext *udm_context.UDMContext) (profile udm_context.NfProfile, err error) {
 	profile.NfInstanceId = udmContext.NfId
 	profile.NfType = models.NfType_UDM
 	profile.NfStatus = models.NfStatus_REGISTERED
-	profile.Ipv4Addresses = append(profile.Ipv4Addresses, udmContext.RegisterIPv4)
-	services := []models.NfService{}
-	for _, nfService := range udmContext.NfService {
-		services = append(services, nfService)
+	if udmContext.RegisterIPv4 != "" {
+		profile.Ipv4Addresses = append(profile.Ipv4Addresses, udmContext.RegisterIPv4)
+	}
+	if udmContext.RegisterIPv6 != "" {
+		profile.Ipv6Addresses = append(profile.Ipv6Addresses, udmContext.RegisterIPv6)
+	}
+	profile.Fqdn = udmContext.Fqdn
+	if udmContext.NfSetId != "" {
+		profile.NfSetIdList = []string{udmContext.NfSetId}
 	}
+	services := udmContext.GetNfServices()
 	if len(services) > 0 {
 		profile.NfServices = &services
 	}
-	profile.UdmInfo = &models.UdmInfo{
-		// Todo
-		// SupiRanges: &[]models.SupiRange{
-		// 	{
-		// 		//from TS 29.510 6.1.6.2.9 example2
-		//		//no need to set supirange in this moment 2019/10/4
-		// 		Start:   "123456789040000",
-		// 		End:     "123456789059999",
-		// 		Pattern: "^imsi-12345678904[0-9]{4}$",
-		// 	},
-		// },
+	profile.UdmInfo = udmContext.UdmInfo
+	if profile.UdmInfo == nil {
+		profile.UdmInfo = &udm_context.UdmInfo{}
 	}
 	return
 }
@@ -0,0 +1,267 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+const (
+	// validityTime requested for the subscriptions, the NRF may grant a shorter one
+	nrfSubscriptionValidity time.Duration = 24 * time.Hour
+	// retry interval of a failed subscription or renewal
+	nrfSubscriptionRetryInterval time.Duration = 10 * time.Second
+)
+
+// NF types whose status changes are subscribed: the UDRs serving the UDM and the peer UDMs
+var subscribedNfTypes = []models.NfType{models.NfType_UDR, models.NfType_UDM}
+
+// RunNFStatusSubscriptions subscribes to the NF status notifications of the UDRs and peer UDMs
+// (TS 29.510 5.2.2.5), and renews the subscriptions before their validityTime. The subscriptions are
+// removed when ctx is done.
+func (s *nnrfService) RunNFStatusSubscriptions(ctx context.Context) {
+	defer s.removeNFStatusSubscriptions()
+
+	for {
+		wait := nrfSubscriptionValidity
+		for _, nfType := range subscribedNfTypes {
+			subscription, err := s.renewNFStatusSubscription(ctx, nfType)
+			if err != nil {
+				logger.ConsumerLog.Errorf("NF status subscription for %s Error[%+v]", nfType, err)
+				wait = minDuration(wait, nrfSubscriptionRetryInterval)
+				continue
+			}
+			if subscription.ValidityTime != nil {
+				wait = minDuration(wait, nfStatusSubscriptionRenewal(*subscription.ValidityTime))
+			}
+		}
+
+		if err := waitRetry(ctx, wait); err != nil {
+			return
+		}
+	}
+}
+
+// nfStatusSubscriptionRenewal returns when the subscription expiring at validityTime is renewed: when 80%
+// of its validity has elapsed, but not sooner than a retry if the NRF granted a validityTime in the
+// past or very near
+func nfStatusSubscriptionRenewal(validityTime time.Time) time.Duration {
+	renewal := time.Until(validityTime) * 4 / 5
+	if renewal < nrfSubscriptionRetryInterval {
+		return nrfSubscriptionRetryInterval
+	}
+	return renewal
+}
+
+// renewNFStatusSubscription creates the subscription for the NF type, or extends the validityTime of
+// the existing one. The subscription is created again if the NRF does not know it any more.
+func (s *nnrfService) renewNFStatusSubscription(ctx context.Context, nfType models.NfType) (
+	*models.NrfSubscriptionData, error,
+) {
+	s.subscriptionsMu.Lock()
+	subscription, ok := s.subscriptions[nfType]
+	s.subscriptionsMu.Unlock()
+
+	if ok && subscription.ValidityTime != nil {
+		updated, err := s.updateNFStatusSubscription(ctx, nfType, subscription)
+		if err == nil {
+			return updated, nil
+		}
+		logger.ConsumerLog.Warnf("Renew NF status subscription [%s] Error[%+v], subscribe again",
+			subscription.SubscriptionId, err)
+	} else if ok {
+		// the subscription does not expire
+		return subscription, nil
+	}
+
+	return s.createNFStatusSubscription(ctx, nfType)
+}
+
+func (s *nnrfService) createNFStatusSubscription(ctx context.Context, nfType models.NfType) (
+	*models.NrfSubscriptionData, error,
+) {
+	udmContext := s.consumer.Context()
+	client := s.getNFManagementClient(udmContext.GetNrfUri())
+
+	tokenCtx, _, err := udmContext.GetTokenCtx(models.ServiceName_NNRF_NFM, models.NfType_NRF)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	validityTime := time.Now().Add(nrfSubscriptionValidity)
+	subscriptionData := models.NrfSubscriptionData{
+		NfStatusNotificationUri: udmContext.GetIPUri() + factory.UdmNfStatusNotifyUri,
+		SubscrCond:              models.NfTypeCond{NfType: nfType},
+		ValidityTime:            &validityTime,
+		ReqNotifEvents: []models.NotificationEventType{
+			models.NotificationEventType_REGISTERED,
+			models.NotificationEventType_DEREGISTERED,
+			models.NotificationEventType_PROFILE_CHANGED,
+		},
+		ReqNfType: models.NfType_UDM,
+	}
+	subscription, res, err := client.SubscriptionsCollectionApi.CreateSubscription(tokenCtx, subscriptionData)
+	defer closeResponseBody(res, "CreateSubscription")
+	if err != nil {
+		return nil, err
+	}
+	if res == nil || res.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("NRF return wrong status code %d", statusCode(res))
+	}
+
+	s.subscriptionsMu.Lock()
+	s.subscriptions[nfType] = &subscription
+	s.subscriptionsMu.Unlock()
+	logger.ConsumerLog.Infof("Subscribed to the NF status of %s: subscriptionId[%s]", nfType,
+		subscription.SubscriptionId)
+	return &subscription, nil
+}
+
+func (s *nnrfService) updateNFStatusSubscription(ctx context.Context, nfType models.NfType,
+	subscription *models.NrfSubscriptionData,
+) (*models.NrfSubscriptionData, error) {
+	udmContext := s.consumer.Context()
+	client := s.getNFManagementClient(udmContext.GetNrfUri())
+
+	tokenCtx, _, err := udmContext.GetTokenCtx(models.ServiceName_NNRF_NFM, models.NfType_NRF)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	validityTime := time.Now().Add(nrfSubscriptionValidity)
+	patchItems := []models.PatchItem{
+		{
+			Op:    models.PatchOperation_REPLACE,
+			Path:  "/validityTime",
+			Value: validityTime.Format(time.RFC3339),
+		},
+	}
+	updated, res, err := client.SubscriptionIDDocumentApi.UpdateSubscription(
+		tokenCtx, subscription.SubscriptionId, patchItems)
+	defer closeResponseBody(res, "UpdateSubscription")
+	if err != nil {
+		return nil, err
+	}
+
+	renewed := *subscription
+	switch statusCode(res) {
+	case http.StatusOK:
+		renewed.ValidityTime = updated.ValidityTime
+	case http.StatusNoContent:
+		// the requested validityTime is accepted
+		renewed.ValidityTime = &validityTime
+	default:
+		return nil, fmt.Errorf("NRF return wrong status code %d", statusCode(res))
+	}
+
+	s.subscriptionsMu.Lock()
+	s.subscriptions[nfType] = &renewed
+	s.subscriptionsMu.Unlock()
+	return &renewed, nil
+}
+
+func (s *nnrfService) removeNFStatusSubscriptions() {
+	udmContext := s.consumer.Context()
+	client := s.getNFManagementClient(udmContext.GetNrfUri())
+
+	s.subscriptionsMu.Lock()
+	subscriptions := s.subscriptions
+	s.subscriptions = make(map[models.NfType]*models.NrfSubscriptionData)
+	s.subscriptionsMu.Unlock()
+
+	for nfType, subscription := range subscriptions {
+		tokenCtx, _, err := udmContext.GetTokenCtx(models.ServiceName_NNRF_NFM, models.NfType_NRF)
+		if err != nil {
+			logger.ConsumerLog.Errorf("Remove NF status subscription for %s Error[%+v]", nfType, err)
+			continue
+		}
+		res, err := client.SubscriptionIDDocumentApi.RemoveSubscription(tokenCtx, subscription.SubscriptionId)
+		closeResponseBody(res, "RemoveSubscription")
+		if err != nil {
+			logger.ConsumerLog.Errorf("Remove NF status subscription for %s Error[%+v]", nfType, err)
+			continue
+		}
+		logger.ConsumerLog.Infof("Removed NF status subscription [%s]", subscription.SubscriptionId)
+	}
+}
+
+// HandleNFStatusNotification drops the cached URIs and clients of the NF which is deregistered or
+// whose profile has changed, so they are discovered again on the next request. The notifications of
+// the NF types which are not subscribed are rejected.
+func (c *Consumer) HandleNFStatusNotification(notification *models.NotificationData) error {
+	nfInstanceId := notification.NfInstanceUri[strings.LastIndex(notification.NfInstanceUri, "/")+1:]
+
+	// NF_DEREGISTERED does not carry the profile, so the type of the NF may be unknown
+	var nfType models.NfType
+	if notification.NfProfile != nil {
+		nfType = notification.NfProfile.NfType
+	}
+	subscribed := c.subscribedNfTypes()
+	if len(subscribed) == 0 || (nfType != "" && !subscribed[nfType]) {
+		return fmt.Errorf("no NF status subscription matches the notification of NF [%s]", nfInstanceId)
+	}
+	logger.ConsumerLog.Infof("NF [%s] status notification: %s", nfInstanceId, notification.Event)
+
+	switch notification.Event {
+	case models.NotificationEventType_DEREGISTERED, models.NotificationEventType_PROFILE_CHANGED:
+	default:
+		// a new NF is found by the next discovery
+		return nil
+	}
+
+	if (nfType == "" || nfType == models.NfType_UDR) && subscribed[models.NfType_UDR] {
+		c.InvalidateUdr(nfInstanceId)
+	}
+	if (nfType == "" || nfType == models.NfType_UDM) && subscribed[models.NfType_UDM] {
+		c.resetUdmClients()
+	}
+	return nil
+}
+
+// subscribedNfTypes returns the NF types whose status changes are subscribed
+func (s *nnrfService) subscribedNfTypes() map[models.NfType]bool {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+
+	nfTypes := make(map[models.NfType]bool, len(s.subscriptions))
+	for nfType := range s.subscriptions {
+		nfTypes[nfType] = true
+	}
+	return nfTypes
+}
+
+func statusCode(res *http.Response) int {
+	if res == nil {
+		return 0
+	}
+	return res.StatusCode
+}
+
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// closeResponseBody closes the body of the response of the NRF, if any
+func closeResponseBody(res *http.Response, operation string) {
+	if res == nil {
+		return
+	}
+	if err := res.Body.Close(); err != nil {
+		logger.ConsumerLog.Errorf("%s response body cannot close: %+v", operation, err)
+	}
+}
@@ -0,0 +1,157 @@
+package consumer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/pkg/app"
+)
+
+func TestRenewNFStatusSubscription(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	validityTime := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	gock.New("http://127.0.0.10:8000").
+		Post("/nnrf-nfm/v1/subscriptions").
+		Reply(201).
+		JSON(map[string]interface{}{
+			"nfStatusNotificationUri": "http://127.0.0.3:8000/nf-status-notify",
+			"subscriptionId":          "sub-1",
+			"validityTime":            validityTime.Format(time.RFC3339),
+		})
+	gock.New("http://127.0.0.10:8000").
+		Patch("/nnrf-nfm/v1/subscriptions/sub-1").
+		Reply(204)
+	// the NRF has lost the subscription
+	gock.New("http://127.0.0.10:8000").
+		Patch("/nnrf-nfm/v1/subscriptions/sub-1").
+		Reply(404).
+		JSON(map[string]interface{}{"status": 404})
+	gock.New("http://127.0.0.10:8000").
+		Post("/nnrf-nfm/v1/subscriptions").
+		Reply(201).
+		JSON(map[string]interface{}{
+			"nfStatusNotificationUri": "http://127.0.0.3:8000/nf-status-notify",
+			"subscriptionId":          "sub-2",
+		})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfUri:       "http://127.0.0.10:8000",
+			UriScheme:    models.UriScheme_HTTP,
+			RegisterIPv4: "127.0.0.3",
+			SBIPort:      8000,
+		},
+	)
+
+	subscription, err := consumer.renewNFStatusSubscription(context.TODO(), models.NfType_UDR)
+	require.NoError(t, err)
+	require.Equal(t, "sub-1", subscription.SubscriptionId)
+	require.True(t, validityTime.Equal(*subscription.ValidityTime))
+
+	subscription, err = consumer.renewNFStatusSubscription(context.TODO(), models.NfType_UDR)
+	require.NoError(t, err)
+	require.Equal(t, "sub-1", subscription.SubscriptionId)
+	require.True(t, subscription.ValidityTime.After(validityTime))
+
+	subscription, err = consumer.renewNFStatusSubscription(context.TODO(), models.NfType_UDR)
+	require.NoError(t, err)
+	require.Equal(t, "sub-2", subscription.SubscriptionId)
+	require.True(t, gock.IsDone())
+}
+
+func TestHandleNFStatusNotification(t *testing.T) {
+	consumer, err := NewConsumer(nil)
+	require.NoError(t, err)
+
+	candidates := []udrCandidate{
+		{nfInstanceId: "udr-1", uri: "http://127.0.0.4:8000"},
+		{nfInstanceId: "udr-2", uri: "http://127.0.0.5:8000"},
+	}
+	consumer.udrCache.set("1:imsi-208930000000001", candidates, time.Minute)
+
+	// the notifications are rejected until the NF status is subscribed
+	require.Error(t, consumer.HandleNFStatusNotification(&models.NotificationData{
+		Event:         models.NotificationEventType_DEREGISTERED,
+		NfInstanceUri: "http://127.0.0.10:8000/nnrf-nfm/v1/nf-instances/udr-1",
+	}))
+	cached, ok := consumer.udrCache.get("1:imsi-208930000000001")
+	require.True(t, ok)
+	require.Len(t, cached, 2)
+
+	consumer.subscriptions[models.NfType_UDR] = &models.NrfSubscriptionData{SubscriptionId: "1"}
+
+	// the NF types which are not subscribed are rejected
+	require.Error(t, consumer.HandleNFStatusNotification(&models.NotificationData{
+		Event:         models.NotificationEventType_PROFILE_CHANGED,
+		NfInstanceUri: "http://127.0.0.10:8000/nnrf-nfm/v1/nf-instances/amf-1",
+		NfProfile: &models.NfProfileNotificationData{
+			NfInstanceId: "amf-1",
+			NfType:       models.NfType_AMF,
+		},
+	}))
+
+	// a new UDR does not change the cache
+	require.NoError(t, consumer.HandleNFStatusNotification(&models.NotificationData{
+		Event:         models.NotificationEventType_REGISTERED,
+		NfInstanceUri: "http://127.0.0.10:8000/nnrf-nfm/v1/nf-instances/udr-3",
+	}))
+	cached, ok = consumer.udrCache.get("1:imsi-208930000000001")
+	require.True(t, ok)
+	require.Len(t, cached, 2)
+
+	require.NoError(t, consumer.HandleNFStatusNotification(&models.NotificationData{
+		Event:         models.NotificationEventType_DEREGISTERED,
+		NfInstanceUri: "http://127.0.0.10:8000/nnrf-nfm/v1/nf-instances/udr-1",
+	}))
+	cached, ok = consumer.udrCache.get("1:imsi-208930000000001")
+	require.True(t, ok)
+	require.Equal(t, []udrCandidate{candidates[1]}, cached)
+
+	require.NoError(t, consumer.HandleNFStatusNotification(&models.NotificationData{
+		Event:         models.NotificationEventType_PROFILE_CHANGED,
+		NfInstanceUri: "http://127.0.0.10:8000/nnrf-nfm/v1/nf-instances/udr-2",
+		NfProfile: &models.NfProfileNotificationData{
+			NfInstanceId: "udr-2",
+			NfType:       models.NfType_UDR,
+		},
+	}))
+	_, ok = consumer.udrCache.get("1:imsi-208930000000001")
+	require.False(t, ok)
+}
+
+func TestNFStatusSubscriptionRenewal(t *testing.T) {
+	testCases := []struct {
+		name         string
+		validityTime time.Time
+		min, max     time.Duration
+	}{
+		{"Expired", time.Now().Add(-time.Hour), nrfSubscriptionRetryInterval, nrfSubscriptionRetryInterval},
+		{"Near", time.Now().Add(time.Second), nrfSubscriptionRetryInterval, nrfSubscriptionRetryInterval},
+		{"Far", time.Now().Add(time.Hour), 47 * time.Minute, 48 * time.Minute},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			renewal := nfStatusSubscriptionRenewal(tc.validityTime)
+			require.GreaterOrEqual(t, renewal, tc.min)
+			require.LessOrEqual(t, renewal, tc.max)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package consumer
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/free5gc/udm/internal/util"
+)
+
+var sbiHeadersOnce sync.Once
+
+type (
+	sbiCallbackCtxKey       struct{}
+	sbiBindingCtxKey        struct{}
+	sbiRoutingBindingCtxKey struct{}
+)
+
+// WithSbiCallback returns ctx marking the requests sent with it as notifications of the callback type,
+// e.g. "Nudm_UECM_DeregistrationNotification", which is sent in the 3gpp-Sbi-Callback header
+func WithSbiCallback(ctx context.Context, callbackType string) context.Context {
+	return context.WithValue(ctx, sbiCallbackCtxKey{}, callbackType)
+}
+
+// WithSbiBinding returns ctx sending the binding indication in the 3gpp-Sbi-Binding header of the
+// requests, e.g. the binding of the notifications of a subscription created in the UDR
+func WithSbiBinding(ctx context.Context, binding string) context.Context {
+	if binding == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, sbiBindingCtxKey{}, binding)
+}
+
+// WithSbiRoutingBinding returns ctx sending the binding indication in the 3gpp-Sbi-Routing-Binding
+// header of the requests, so the SCP can select an alternate NF if the target NF is not reachable
+func WithSbiRoutingBinding(ctx context.Context, binding string) context.Context {
+	if binding == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, sbiRoutingBindingCtxKey{}, binding)
+}
+
+// sbiHeaderTransport sets the 3gpp-Sbi-* headers given by the ctx of the requests
+type sbiHeaderTransport struct {
+	next http.RoundTripper
+}
+
+// InstrumentSbiHeaders wraps the transports of the HTTP clients shared by the openapi clients, i.e.
+// openapi.GetHttpClient() and openapi.GetHttpsClient(), so the requests carry the 3gpp-Sbi-Callback,
+// 3gpp-Sbi-Binding and 3gpp-Sbi-Routing-Binding headers given by their ctx
+func InstrumentSbiHeaders(clients ...*http.Client) {
+	sbiHeadersOnce.Do(func() {
+		for _, client := range clients {
+			next := client.Transport
+			if next == nil {
+				next = http.DefaultTransport
+			}
+			client.Transport = &sbiHeaderTransport{next: next}
+		}
+	})
+}
+
+func (t *sbiHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	headers := map[string]string{}
+	if callbackType, ok := ctx.Value(sbiCallbackCtxKey{}).(string); ok {
+		headers[util.HeaderSbiCallback] = callbackType
+	}
+	if binding, ok := ctx.Value(sbiBindingCtxKey{}).(string); ok {
+		headers[util.HeaderSbiBinding] = binding
+	}
+	if binding, ok := ctx.Value(sbiRoutingBindingCtxKey{}).(string); ok {
+		headers[util.HeaderSbiRoutingBinding] = binding
+	}
+	if len(headers) == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the request
+	req = req.Clone(ctx)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,44 @@
+package consumer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/udm/internal/util"
+)
+
+func TestSbiHeaderTransport(t *testing.T) {
+	var received http.Header
+	amf := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer amf.Close()
+	client := &http.Client{Transport: &sbiHeaderTransport{next: http.DefaultTransport}}
+
+	ctx := WithSbiCallback(context.Background(), "Nudm_UECM_DeregistrationNotification")
+	ctx = WithSbiRoutingBinding(ctx, "bl=nfset; nfset=set1.amfset.5gc.mnc093.mcc208")
+	ctx = WithSbiBinding(ctx, "")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, amf.URL+"/dereg-notify", nil)
+	require.NoError(t, err)
+	rsp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, rsp.Body.Close())
+
+	require.Equal(t, "Nudm_UECM_DeregistrationNotification", received.Get(util.HeaderSbiCallback))
+	require.Equal(t, "bl=nfset; nfset=set1.amfset.5gc.mnc093.mcc208", received.Get(util.HeaderSbiRoutingBinding))
+	require.Empty(t, received.Get(util.HeaderSbiBinding))
+	// the request of the caller is not modified
+	require.Empty(t, req.Header.Get(util.HeaderSbiCallback))
+
+	// the requests without the headers in their ctx are sent as is
+	rsp, err = client.Get(amf.URL + "/dereg-notify")
+	require.NoError(t, err)
+	require.NoError(t, rsp.Body.Close())
+	require.Empty(t, received.Get(util.HeaderSbiCallback))
+	require.Empty(t, received.Get(util.HeaderSbiRoutingBinding))
+}
@@ -0,0 +1,99 @@
+package consumer
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/util"
+)
+
+var (
+	scpOnce sync.Once
+	scpErr  error
+)
+
+// scpTransport sends the requests of the openapi clients through the SCP (TS 29.500 6.10.2): the
+// apiRoot of the target NF is moved to the 3gpp-Sbi-Target-apiRoot header, and the request is sent to
+// the apiRoot of the SCP. The requests addressed to the SCP, e.g. with delegated discovery, are sent
+// as is.
+type scpTransport struct {
+	scp *url.URL
+	// the transports of the HTTP clients by scheme, as the scheme of the SCP may differ from the one
+	// of the target NF
+	next map[string]http.RoundTripper
+}
+
+// RouteThroughScp wraps the transports of the HTTP clients shared by the openapi clients, i.e.
+// openapi.GetHttpClient() and openapi.GetHttpsClient(), so the UDR, NRF and callback requests are
+// sent through the SCP
+func RouteThroughScp(scpUri string, httpClient, httpsClient *http.Client) error {
+	scpOnce.Do(func() {
+		var t *scpTransport
+		if t, scpErr = newScpTransport(scpUri, httpClient.Transport, httpsClient.Transport); scpErr != nil {
+			return
+		}
+		httpClient.Transport = t
+		httpsClient.Transport = t
+	})
+	return scpErr
+}
+
+func newScpTransport(scpUri string, httpNext, httpsNext http.RoundTripper) (*scpTransport, error) {
+	scp, err := url.Parse(scpUri)
+	if err != nil {
+		return nil, err
+	}
+	if scp.Scheme != "http" && scp.Scheme != "https" || scp.Host == "" {
+		return nil, fmt.Errorf("invalid SCP apiRoot [%s]", scpUri)
+	}
+	if httpNext == nil {
+		httpNext = http.DefaultTransport
+	}
+	if httpsNext == nil {
+		httpsNext = http.DefaultTransport
+	}
+	return &scpTransport{
+		scp:  scp,
+		next: map[string]http.RoundTripper{"http": httpNext, "https": httpsNext},
+	}, nil
+}
+
+func (t *scpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if req.URL.Host != t.scp.Host {
+		req.Header.Set(util.HeaderSbiTargetApiRoot, req.URL.Scheme+"://"+req.URL.Host)
+		prefix := strings.TrimSuffix(t.scp.Path, "/")
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = strings.TrimSuffix(t.scp.EscapedPath(), "/") + req.URL.RawPath
+		}
+		req.URL.Path = prefix + req.URL.Path
+		req.URL.Scheme = t.scp.Scheme
+		req.URL.Host = t.scp.Host
+		req.Host = ""
+	}
+	return t.next[req.URL.Scheme].RoundTrip(req)
+}
+
+// udrDiscoveryHeaders returns the 3gpp-Sbi-Discovery-* headers for the SCP to select the UDR
+// serving the identity (TS 29.500 5.2.3.2.19), as the NF discovery of discoverUdrs would do
+func udrDiscoveryHeaders(id string, types int) map[string]string {
+	headers := map[string]string{
+		util.HeaderSbiDiscoveryPrefix + "target-nf-type":    string(models.NfType_UDR),
+		util.HeaderSbiDiscoveryPrefix + "requester-nf-type": string(models.NfType_UDM),
+		util.HeaderSbiDiscoveryPrefix + "service-names":     string(models.ServiceName_NUDR_DR),
+		util.HeaderSbiDiscoveryPrefix + "data-set":          string(models.DataSetId_SUBSCRIPTION),
+	}
+	switch types {
+	case NFDiscoveryToUDRParamSupi:
+		headers[util.HeaderSbiDiscoveryPrefix+"supi"] = id
+	case NFDiscoveryToUDRParamExtGroupId:
+		headers[util.HeaderSbiDiscoveryPrefix+"external-group-identity"] = id
+	case NFDiscoveryToUDRParamGpsi:
+		headers[util.HeaderSbiDiscoveryPrefix+"gpsi"] = id
+	}
+	return headers
+}
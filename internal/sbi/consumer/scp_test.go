@@ -0,0 +1,108 @@
+package consumer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/free5gc/openapi"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/util"
+	"github.com/free5gc/udm/pkg/app"
+)
+
+func TestScpTransport(t *testing.T) {
+	var received []*http.Request
+	scp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer scp.Close()
+
+	next := &sbiHeaderTransport{next: http.DefaultTransport}
+	transport, err := newScpTransport(scp.URL+"/scp-prefix", next, next)
+	require.NoError(t, err)
+	client := &http.Client{Transport: transport}
+
+	// model C: the request is sent to the SCP, with the apiRoot of the UDR in 3gpp-Sbi-Target-apiRoot
+	rsp, err := client.Get("https://127.0.0.4:8000/nudr-dr/v1/subscription-data/imsi-208930000000001")
+	require.NoError(t, err)
+	require.NoError(t, rsp.Body.Close())
+
+	// a notification is sent with its callback type
+	ctx := WithSbiCallback(context.Background(), "Nudm_UECM_DeregistrationNotification")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://127.0.0.18:8000/dereg-notify", nil)
+	require.NoError(t, err)
+	rsp, err = client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, rsp.Body.Close())
+
+	// the requests addressed to the SCP are sent as is
+	rsp, err = client.Get(scp.URL + "/nudr-dr/v1/subscription-data/imsi-208930000000001")
+	require.NoError(t, err)
+	require.NoError(t, rsp.Body.Close())
+
+	require.Len(t, received, 3)
+	require.Equal(t, "/scp-prefix/nudr-dr/v1/subscription-data/imsi-208930000000001", received[0].URL.Path)
+	require.Equal(t, "https://127.0.0.4:8000", received[0].Header.Get(util.HeaderSbiTargetApiRoot))
+	require.Empty(t, received[0].Header.Get(util.HeaderSbiCallback))
+	require.Equal(t, "/scp-prefix/dereg-notify", received[1].URL.Path)
+	require.Equal(t, "http://127.0.0.18:8000", received[1].Header.Get(util.HeaderSbiTargetApiRoot))
+	require.Equal(t, "Nudm_UECM_DeregistrationNotification", received[1].Header.Get(util.HeaderSbiCallback))
+	require.Equal(t, "/nudr-dr/v1/subscription-data/imsi-208930000000001", received[2].URL.Path)
+	require.Empty(t, received[2].Header.Get(util.HeaderSbiTargetApiRoot))
+}
+
+func TestScpDelegatedDiscovery(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	supi := "imsi-208930000000001"
+	gock.New("http://127.0.0.20:8000").
+		Delete("/nudr-dr/v1/subscription-data/"+supi+"/authentication-data/authentication-status").
+		MatchHeader("3gpp-Sbi-Discovery-target-nf-type", "UDR").
+		MatchHeader("3gpp-Sbi-Discovery-requester-nf-type", "UDM").
+		MatchHeader("3gpp-Sbi-Discovery-supi", supi).
+		Reply(204)
+	gock.New("http://127.0.0.20:8000").
+		Get("/nudr-dr/v1/subscription-data/msisdn-886912345678/identity-data").
+		MatchHeader("3gpp-Sbi-Discovery-service-names", "nudr-dr").
+		MatchHeader("3gpp-Sbi-Discovery-gpsi", "msisdn-886912345678").
+		Reply(200).
+		JSON(map[string]interface{}{"supiList": []string{supi}})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	consumer, err := NewConsumer(mockApp)
+	require.NoError(t, err)
+
+	// no NRF discovery is sent
+	mockApp.EXPECT().Context().AnyTimes().Return(
+		&udm_context.UDMContext{
+			NrfDisabled:           true,
+			ScpUri:                "http://127.0.0.20:8000",
+			ScpDelegatedDiscovery: true,
+		},
+	)
+
+	rsp, err := consumer.DeleteAuthenticationStatus(context.TODO(), supi)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, rsp.StatusCode)
+
+	client, err := consumer.CreateUDMClientToUDR("msisdn-886912345678")
+	require.NoError(t, err)
+	identityData, rsp, err := client.QueryIdentityDataBySUPIOrGPSIDocumentApi.GetIdentityData(
+		context.TODO(), "msisdn-886912345678", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Equal(t, []string{supi}, identityData.SupiList)
+	require.True(t, gock.IsDone())
+}
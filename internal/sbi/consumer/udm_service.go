@@ -60,3 +60,14 @@ func (s *nudmService) GetUECMClient(uri string) *Nudm_UEContextManagement.APICli
 	s.nfUECMClients[uri] = client
 	return client
 }
+
+// resetUdmClients drops the clients of the peer UDMs, e.g. when a peer UDM is deregistered
+func (s *nudmService) resetUdmClients() {
+	s.nfSDMMu.Lock()
+	s.nfSDMClients = make(map[string]*Nudm_SubscriberDataManagement.APIClient)
+	s.nfSDMMu.Unlock()
+
+	s.nfUECMMu.Lock()
+	s.nfUECMClients = make(map[string]*Nudm_UEContextManagement.APIClient)
+	s.nfUECMMu.Unlock()
+}
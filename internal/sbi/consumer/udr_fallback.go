@@ -0,0 +1,125 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+var udrFallbackOnce sync.Once
+
+type udrIdentityCtxKey struct{}
+
+// WithUdrIdentity returns ctx marking the requests sent with it to the UDR as requests for the identity,
+// so udrFallbackTransport sends them to the next UDR serving the identity if the selected one cannot be
+// reached
+func WithUdrIdentity(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, udrIdentityCtxKey{}, id)
+}
+
+// udrFallbackTransport sends the requests of the UDR clients to the next UDR serving their identity
+// when they cannot be sent to the selected one, as sendRequest does. The unreachable UDRs are removed
+// from the discovery cache.
+type udrFallbackTransport struct {
+	udr  *nudrService
+	next http.RoundTripper
+}
+
+// InstrumentUdrFallback wraps the transports of the HTTP clients shared by the openapi clients, i.e.
+// openapi.GetHttpClient() and openapi.GetHttpsClient(), so the requests sent with the identity given by
+// WithUdrIdentity fall back across the UDRs serving the identity
+func (s *nudrService) InstrumentUdrFallback(clients ...*http.Client) {
+	udrFallbackOnce.Do(func() {
+		for _, client := range clients {
+			next := client.Transport
+			if next == nil {
+				next = http.DefaultTransport
+			}
+			client.Transport = &udrFallbackTransport{udr: s, next: next}
+		}
+	})
+}
+
+func (t *udrFallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id, ok := req.Context().Value(udrIdentityCtxKey{}).(string)
+	if !ok || !strings.Contains(req.URL.Path, factory.UdmDrResUriPrefix+"/") {
+		// not a request to the UDR, e.g. a notification sent with the ctx of a UDR request
+		return t.next.RoundTrip(req)
+	}
+
+	rsp, err := t.next.RoundTrip(req)
+	if err == nil || req.Context().Err() != nil {
+		return rsp, err
+	}
+
+	uri := udrApiRoot(req.URL, t.udr.getUdrURIs(id))
+	tried := map[string]bool{uri: true}
+	for uri != "" {
+		logger.ConsumerLog.Warnf("%s %s to UDR [%s] error: %+v", req.Method, req.URL.Path, uri, err)
+		t.udr.removeUnreachableUdr(uri)
+
+		next := ""
+		for _, candidate := range t.udr.getUdrURIs(id) {
+			if !tried[candidate] {
+				next = candidate
+				break
+			}
+		}
+		if next == "" {
+			break
+		}
+		retry, retryErr := redirectUdrRequest(req, uri, next)
+		if retryErr != nil {
+			logger.ConsumerLog.Warnf("%s %s cannot be sent to UDR [%s]: %+v", req.Method, req.URL.Path, next, retryErr)
+			break
+		}
+
+		tried[next] = true
+		uri, req = next, retry
+		rsp, err = t.next.RoundTrip(req)
+		if err == nil {
+			t.udr.selectUdr(id, uri)
+			break
+		}
+		if req.Context().Err() != nil {
+			break
+		}
+	}
+	return rsp, err
+}
+
+// udrApiRoot returns the apiRoot among the uris the request is sent to, or ""
+func udrApiRoot(reqUrl *url.URL, uris []string) string {
+	for _, uri := range uris {
+		if strings.HasPrefix(reqUrl.String(), uri+"/") {
+			return uri
+		}
+	}
+	return ""
+}
+
+// redirectUdrRequest returns a copy of the request sent to the apiRoot to instead of from
+func redirectUdrRequest(req *http.Request, from, to string) (*http.Request, error) {
+	target, err := url.Parse(to + strings.TrimPrefix(req.URL.String(), from))
+	if err != nil {
+		return nil, err
+	}
+	redirected := req.Clone(req.Context())
+	redirected.URL = target
+	redirected.Host = ""
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("the request body cannot be sent again")
+		}
+		if redirected.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return redirected, nil
+}
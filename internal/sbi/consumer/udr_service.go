@@ -1,13 +1,22 @@
 package consumer
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/Nudr_DataRepository"
+	"github.com/free5gc/openapi/models"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
 )
 
 type nudrService struct {
@@ -16,8 +25,22 @@ type nudrService struct {
 	nfDRMu sync.RWMutex
 
 	nfDRClients map[string]*Nudr_DataRepository.APIClient
+	udrCache    *udrCache
+
+	// the last result of ProbeUdr
+	probeMu  sync.Mutex
+	probedAt time.Time
+	probeErr error
 }
 
+const (
+	// the SUPI of the UDR probe, which does not belong to any UE
+	udrProbeUeId = "imsi-000000000000000"
+	// the period the result of the UDR probe is reused for, so the probes of the orchestrator do not
+	// reach the NRF and the UDR on each readiness check
+	udrProbeInterval time.Duration = 5 * time.Second
+)
+
 const (
 	NFDiscoveryToUDRParamNone int = iota
 	NFDiscoveryToUDRParamSupi
@@ -25,12 +48,23 @@ const (
 	NFDiscoveryToUDRParamGpsi
 )
 
+// CreateUDMClientToUDR returns a client of the UDR serving the identity. The requests of the client
+// fall back across the UDRs serving the identity if they are sent with WithUdrIdentity.
 func (s *nudrService) CreateUDMClientToUDR(id string) (*Nudr_DataRepository.APIClient, error) {
 	uri := s.getUdrURI(id)
 	if uri == "" {
 		logger.ProcLog.Errorf("ID[%s] does not match any UDR", id)
 		return nil, fmt.Errorf("No UDR URI found")
 	}
+	if headers := s.delegatedDiscoveryHeaders(uri, id); headers != nil {
+		// the discovery headers differ per identity, so the client is not shared
+		cfg := Nudr_DataRepository.NewConfiguration()
+		cfg.SetBasePath(uri)
+		for name, value := range headers {
+			cfg.AddDefaultHeader(name, value)
+		}
+		return Nudr_DataRepository.NewAPIClient(cfg), nil
+	}
 	s.nfDRMu.RLock()
 	client, ok := s.nfDRClients[uri]
 	if ok {
@@ -49,45 +83,289 @@ func (s *nudrService) CreateUDMClientToUDR(id string) (*Nudr_DataRepository.APIC
 	return client, nil
 }
 
+// ProbeUdr checks that the UDR selected for the requests is reachable. The probe reads the identity
+// data of a SUPI no UE has, so any HTTP response, e.g. 404, means the UDR is reachable. The result
+// is reused for udrProbeInterval, and the concurrent checks wait for the same probe.
+func (s *nudrService) ProbeUdr(ctx context.Context) error {
+	s.probeMu.Lock()
+	defer s.probeMu.Unlock()
+
+	if !s.probedAt.IsZero() && time.Since(s.probedAt) < udrProbeInterval {
+		return s.probeErr
+	}
+	s.probeErr = s.probeUdr(ctx)
+	s.probedAt = time.Now()
+	return s.probeErr
+}
+
+func (s *nudrService) probeUdr(ctx context.Context) error {
+	client, err := s.CreateUDMClientToUDR("")
+	if err != nil {
+		return err
+	}
+	_, rsp, err := client.QueryIdentityDataBySUPIOrGPSIDocumentApi.GetIdentityData(ctx, udrProbeUeId, nil)
+	if rsp == nil {
+		if err == nil {
+			err = fmt.Errorf("UDR no response")
+		}
+		return err
+	}
+	if closeErr := rsp.Body.Close(); closeErr != nil {
+		logger.ConsumerLog.Warnf("UDR probe response body cannot close: %+v", closeErr)
+	}
+	return nil
+}
+
 func (s *nudrService) getUdrURI(id string) string {
+	uris := s.getUdrURIs(id)
+	if len(uris) == 0 {
+		return ""
+	}
+	return uris[0]
+}
+
+// getUdrURIs returns the URIs of the UDRs serving the identity in the order they should be tried. The
+// UDR selected for the UE is tried first as long as it serves the UE.
+func (s *nudrService) getUdrURIs(id string) []string {
+	queryId, types, ue, ok := s.udrQuery(id)
+	if !ok {
+		return nil
+	}
+
+	var uris []string
+	udmContext := s.consumer.Context()
+	if len(udmContext.StaticUdrs) > 0 {
+		uris = staticUdrURIs(udmContext.StaticUdrs, queryId, types)
+	} else if udmContext.ScpDelegatedDiscovery {
+		// the SCP selects the UDR from the discovery headers
+		uris = []string{udmContext.ScpUri}
+	} else if udmContext.NrfDisabled {
+		logger.ConsumerLog.Errorf("NRF is disabled and no UDR is configured")
+		return nil
+	} else {
+		candidates, err := s.discoverUdrs(queryId, types)
+		if err != nil {
+			logger.ConsumerLog.Errorf("UDR discovery for [%s] error: %+v", id, err)
+			return nil
+		}
+		for _, candidate := range candidates {
+			uris = append(uris, candidate.uri)
+		}
+	}
+	if len(uris) == 0 {
+		return nil
+	}
+	if ue != nil {
+		uris = preferUdrURI(uris, ue.GetUdrUri())
+		ue.SetUdrUri(uris[0])
+	}
+	return uris
+}
+
+// preferUdrURI moves the preferred UDR to the front of the uris if it is among them
+func preferUdrURI(uris []string, preferred string) []string {
+	for i, uri := range uris {
+		if uri == preferred {
+			ordered := append([]string{uri}, uris[:i]...)
+			return append(ordered, uris[i+1:]...)
+		}
+	}
+	return uris
+}
+
+// selectUdr selects the UDR for the UE of the identity, e.g. after the selected one could not be reached
+func (s *nudrService) selectUdr(id, uri string) {
+	if _, _, ue, ok := s.udrQuery(id); ok && ue != nil {
+		ue.SetUdrUri(uri)
+	}
+}
+
+// udrQuery returns the identity the UDR is selected with, and its type. A PEI is replaced with the
+// SUPI of the UE registered with it.
+func (s *nudrService) udrQuery(id string) (string, int, *udm_context.UdmUeContext, bool) {
+	var ue *udm_context.UdmUeContext
 	if strings.Contains(id, "imsi") || strings.Contains(id, "nai") { // supi
-		ue, ok := udm_context.GetSelf().UdmUeFindBySupi(id)
-		if ok {
-			if ue.UdrUri == "" {
-				ue.UdrUri = SendNFIntancesUDR(id, NFDiscoveryToUDRParamSupi)
-			}
-			return ue.UdrUri
-		} else {
+		var ok bool
+		ue, ok = udm_context.GetSelf().UdmUeFindBySupi(id)
+		if !ok {
 			ue = udm_context.GetSelf().NewUdmUe(id)
-			ue.UdrUri = SendNFIntancesUDR(id, NFDiscoveryToUDRParamSupi)
-			return ue.UdrUri
 		}
+		return id, NFDiscoveryToUDRParamSupi, ue, true
 	} else if strings.Contains(id, "pei") {
-		var udrURI string
 		udm_context.GetSelf().UdmUePool.Range(func(key, value interface{}) bool {
-			ue := value.(*udm_context.UdmUeContext)
-			if ue.Amf3GppAccessRegistration != nil && ue.Amf3GppAccessRegistration.Pei == id {
-				if ue.UdrUri == "" {
-					ue.UdrUri = SendNFIntancesUDR(ue.Supi, NFDiscoveryToUDRParamSupi)
-				}
-				udrURI = ue.UdrUri
-				return false
-			} else if ue.AmfNon3GppAccessRegistration != nil && ue.AmfNon3GppAccessRegistration.Pei == id {
-				if ue.UdrUri == "" {
-					ue.UdrUri = SendNFIntancesUDR(ue.Supi, NFDiscoveryToUDRParamSupi)
-				}
-				udrURI = ue.UdrUri
+			udmUe := value.(*udm_context.UdmUeContext)
+			if (udmUe.Amf3GppAccessRegistration != nil && udmUe.Amf3GppAccessRegistration.Pei == id) ||
+				(udmUe.AmfNon3GppAccessRegistration != nil && udmUe.AmfNon3GppAccessRegistration.Pei == id) {
+				ue = udmUe
 				return false
 			}
 			return true
 		})
-		return udrURI
+		if ue == nil {
+			return "", NFDiscoveryToUDRParamNone, nil, false
+		}
+		return ue.Supi, NFDiscoveryToUDRParamSupi, ue, true
 	} else if strings.Contains(id, "extgroupid") {
 		// extra group id
-		return SendNFIntancesUDR(id, NFDiscoveryToUDRParamExtGroupId)
+		return id, NFDiscoveryToUDRParamExtGroupId, nil, true
 	} else if strings.Contains(id, "msisdn") || strings.Contains(id, "extid") {
 		// gpsi
-		return SendNFIntancesUDR(id, NFDiscoveryToUDRParamGpsi)
+		return id, NFDiscoveryToUDRParamGpsi, nil, true
+	}
+	return "", NFDiscoveryToUDRParamNone, nil, true
+}
+
+// delegatedDiscoveryHeaders returns the 3gpp-Sbi-Discovery-* headers of the requests for the identity
+// if the UDR at uri is the SCP discovering the UDRs, or nil
+func (s *nudrService) delegatedDiscoveryHeaders(uri, id string) map[string]string {
+	udmContext := s.consumer.Context()
+	if !udmContext.ScpDelegatedDiscovery || uri != udmContext.ScpUri {
+		return nil
+	}
+	queryId, types, _, _ := s.udrQuery(id)
+	return udrDiscoveryHeaders(queryId, types)
+}
+
+// staticUdrURIs returns the configured UDRs whose supiRanges contain the SUPI, followed by the UDRs
+// without supiRanges. The other identities are served by the UDRs without supiRanges, or by all the
+// UDRs if every UDR has supiRanges.
+func staticUdrURIs(udrs []factory.StaticUdr, id string, types int) []string {
+	var matched, defaults []string
+	for _, udr := range udrs {
+		if len(udr.SupiRanges) == 0 {
+			defaults = append(defaults, udr.Uri)
+			continue
+		}
+		if types == NFDiscoveryToUDRParamSupi && supiInRanges(id, udr.SupiRanges) {
+			matched = append(matched, udr.Uri)
+		}
+	}
+	if types != NFDiscoveryToUDRParamSupi && len(defaults) == 0 {
+		for _, udr := range udrs {
+			defaults = append(defaults, udr.Uri)
+		}
+	}
+	return append(matched, defaults...)
+}
+
+// supiInRanges checks the SUPI against the ranges of TS 29.510 6.1.6.2.9: the start and end are
+// compared with the digits of an IMSI, and the pattern is matched with the whole SUPI
+func supiInRanges(supi string, ranges []factory.IdentityRange) bool {
+	digits := strings.TrimPrefix(supi, "imsi-")
+	for _, r := range ranges {
+		if r.Pattern != "" {
+			if matched, err := regexp.MatchString(r.Pattern, supi); err == nil && matched {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(supi, "imsi-") && len(digits) == len(r.Start) &&
+			digits >= r.Start && digits <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryAuthSubsData retrieves the authentication subscription of the UE (TS 29.505 5.2.2.2.x). Unlike
+// the openapi client, the Rel-17 attributes (e.g. akmaAllowed) of the subscription are kept.
+func (s *nudrService) QueryAuthSubsData(ctx context.Context, ueId string) (
+	*udm_context.AuthenticationSubscription, *http.Response, error,
+) {
+	var authSubs udm_context.AuthenticationSubscription
+	rsp, err := s.sendRequest(ctx, ueId, http.MethodGet,
+		"/subscription-data/"+ueId+"/authentication-data/authentication-subscription", &authSubs)
+	if err != nil {
+		return nil, rsp, err
+	}
+	return &authSubs, rsp, nil
+}
+
+// DeleteAuthenticationStatus removes the authentication status of the UE in the UDR (TS 29.505 5.2.2.2.x).
+// Nudr_DataRepository does not provide this operation yet, so the request is built here.
+func (s *nudrService) DeleteAuthenticationStatus(ctx context.Context, ueId string) (*http.Response, error) {
+	return s.sendRequest(ctx, ueId, http.MethodDelete,
+		"/subscription-data/"+ueId+"/authentication-data/authentication-status", nil)
+}
+
+// sendRequest sends a request without body to the UDR serving ueId. The body of a 200 response
+// is deserialized into rspBody when it is not nil.
+func (s *nudrService) sendRequest(ctx context.Context, ueId, method, path string,
+	rspBody interface{},
+) (*http.Response, error) {
+	uris := s.getUdrURIs(ueId)
+	if len(uris) == 0 {
+		logger.ProcLog.Errorf("ID[%s] does not match any UDR", ueId)
+		return nil, fmt.Errorf("No UDR URI found")
+	}
+
+	// try the next UDR if the request cannot be sent to one
+	var rsp *http.Response
+	var err error
+	for _, uri := range uris {
+		cfg := Nudr_DataRepository.NewConfiguration()
+		cfg.SetBasePath(uri)
+		headerParams := map[string]string{
+			"Accept": "application/json",
+		}
+		for name, value := range s.delegatedDiscoveryHeaders(uri, ueId) {
+			headerParams[name] = value
+		}
+
+		var req *http.Request
+		req, err = openapi.PrepareRequest(ctx, cfg, cfg.BasePath()+path, method, nil, headerParams,
+			url.Values{}, url.Values{}, "", "", nil)
+		if err != nil {
+			return nil, err
+		}
+
+		rsp, err = openapi.CallAPI(cfg, req)
+		if err == nil && rsp != nil {
+			if uri != uris[0] {
+				s.selectUdr(ueId, uri)
+			}
+			break
+		}
+		if ctx.Err() != nil {
+			return rsp, err
+		}
+		logger.ConsumerLog.Warnf("%s %s to UDR [%s] error: %+v", method, path, uri, err)
+		s.removeUnreachableUdr(uri)
+	}
+	if err != nil || rsp == nil {
+		return rsp, err
+	}
+
+	body, err := io.ReadAll(rsp.Body)
+	if closeErr := rsp.Body.Close(); closeErr != nil {
+		logger.ConsumerLog.Errorf("%s %s response body cannot close: %+v", method, path, closeErr)
+	}
+	if err != nil {
+		return rsp, err
+	}
+
+	apiError := openapi.GenericOpenAPIError{
+		RawBody:     body,
+		ErrorStatus: rsp.Status,
+	}
+	switch rsp.StatusCode {
+	case http.StatusOK:
+		if rspBody != nil {
+			if err = openapi.Deserialize(rspBody, body, rsp.Header.Get("Content-Type")); err != nil {
+				apiError.ErrorStatus = err.Error()
+				return rsp, apiError
+			}
+		}
+		return rsp, nil
+	case http.StatusCreated, http.StatusNoContent:
+		return rsp, nil
+	}
+
+	var problem models.ProblemDetails
+	if err = openapi.Deserialize(&problem, body, rsp.Header.Get("Content-Type")); err != nil {
+		apiError.ErrorStatus = err.Error()
+		return rsp, apiError
 	}
-	return SendNFIntancesUDR("", NFDiscoveryToUDRParamNone)
+	apiError.ErrorModel = problem
+	return rsp, apiError
 }
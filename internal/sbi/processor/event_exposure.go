@@ -10,12 +10,15 @@ import (
 	"github.com/free5gc/openapi/models"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/tracing"
 )
 
 // EE service
 func (p *Processor) CreateEeSubscriptionProcedure(c *gin.Context, ueIdentity string,
 	eesubscription models.EeSubscription,
 ) {
+	defer tracing.StartProcedure(c, "CreateEeSubscriptionProcedure").End()
+
 	udmSelf := p.Context()
 	logger.EeLog.Debugf("udIdentity: %s", ueIdentity)
 	switch {
@@ -110,6 +113,8 @@ func (p *Processor) CreateEeSubscriptionProcedure(c *gin.Context, ueIdentity str
 
 // TODO: complete this procedure based on TS 29503 5.5
 func (p *Processor) DeleteEeSubscriptionProcedure(c *gin.Context, ueIdentity string, subscriptionID string) {
+	defer tracing.StartProcedure(c, "DeleteEeSubscriptionProcedure").End()
+
 	udmSelf := p.Context()
 
 	switch {
@@ -148,6 +153,8 @@ func (p *Processor) DeleteEeSubscriptionProcedure(c *gin.Context, ueIdentity str
 func (p *Processor) UpdateEeSubscriptionProcedure(c *gin.Context, ueIdentity string, subscriptionID string,
 	patchList []models.PatchItem,
 ) {
+	defer tracing.StartProcedure(c, "UpdateEeSubscriptionProcedure").End()
+
 	udmSelf := p.Context()
 
 	switch {
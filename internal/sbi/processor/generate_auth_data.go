@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"context"
 	cryptoRand "crypto/rand"
 	"encoding/hex"
 	"fmt"
@@ -18,7 +19,13 @@ import (
 	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/Nudr_DataRepository"
 	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/metrics"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/tracing"
+	"github.com/free5gc/udm/internal/util"
+	"github.com/free5gc/udm/pkg/keywrap"
 	"github.com/free5gc/udm/pkg/suci"
 	"github.com/free5gc/util/milenage"
 	"github.com/free5gc/util/ueauth"
@@ -57,7 +64,7 @@ func (p *Processor) aucSQN(opc, k, auts, rand []byte) ([]byte, []byte) {
 		SQNms[i] = AK[i] ^ ConcSQNms[i]
 	}
 
-	logger.UeauLog.Tracef("aucSQN: opc=[%x], k=[%x], rand=[%x], AMF=[%x], SQNms=[%x]\n", opc, k, rand, AMF, SQNms)
+	logger.UeauLog.Tracef("aucSQN: rand=[%x], AMF=[%x], SQNms=[%x]\n", rand, AMF, SQNms)
 	// The AMF used to calculate MAC-S assumes a dummy value of all zeros
 	err = milenage.F1(opc, k, rand, SQNms, AMF, nil, macS)
 	if err != nil {
@@ -67,6 +74,28 @@ func (p *Processor) aucSQN(opc, k, auts, rand []byte) ([]byte, []byte) {
 	return SQNms, macS
 }
 
+// decodeSubscriberKey decodes the hex encoded K, OP or OPc of hexLen digits. A key stored encrypted
+// in the UDR (encryptionAlgorithm != 0) is unwrapped first. The key value is never logged.
+func (p *Processor) decodeSubscriberKey(value string, encryptionKey, encryptionAlgorithm int32,
+	hexLen int,
+) ([]byte, error) {
+	key, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("hex decode error")
+	}
+	if encryptionAlgorithm != keywrap.AlgorithmNone {
+		key, err = p.Context().SubscriberKeyUnwrapper.Unwrap(encryptionKey, encryptionAlgorithm, key)
+		if err != nil {
+			return nil, fmt.Errorf("unwrap with encryptionKey(%d) encryptionAlgorithm(%d) error: %+v",
+				encryptionKey, encryptionAlgorithm, err)
+		}
+	}
+	if len(key)*2 != hexLen {
+		return nil, fmt.Errorf("key length is %d", len(key)*2)
+	}
+	return key, nil
+}
+
 func (p *Processor) strictHex(ss string, n int) string {
 	l := len(ss)
 	if l < n {
@@ -80,42 +109,164 @@ func (p *Processor) ConfirmAuthDataProcedure(c *gin.Context,
 	authEvent models.AuthEvent,
 	supi string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "ConfirmAuthDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
 	}
+	if problemDetails := p.putAuthenticationStatus(ctx, supi, authEvent); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	udmUe, ok := p.Context().UdmUeFindBySupi(supi)
+	if !ok {
+		udmUe = p.Context().NewUdmUe(supi)
+	}
+	authEventID := udmUe.StoreAuthEvent(&authEvent)
+
+	c.Header("Location", udmUe.GetAuthEventLocationURI(authEventID))
+	c.JSON(http.StatusCreated, authEvent)
+}
+
+// putAuthenticationStatus stores the authentication event as the authentication status of the UE in
+// the UDR
+func (p *Processor) putAuthenticationStatus(ctx context.Context, supi string,
+	authEvent models.AuthEvent,
+) *models.ProblemDetails {
 	var createAuthParam Nudr_DataRepository.CreateAuthenticationStatusParamOpts
 	optInterface := optional.NewInterface(authEvent)
 	createAuthParam.AuthEvent = optInterface
 
 	client, err := p.Consumer().CreateUDMClientToUDR(supi)
 	if err != nil {
-		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return openapi.ProblemDetailsSystemFailure(err.Error())
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	resp, err := client.AuthenticationStatusDocumentApi.CreateAuthenticationStatus(
 		ctx, supi, &createAuthParam)
 	if err != nil {
+		logger.UeauLog.Errorln("ConfirmAuth err:", err.Error())
+		return util.UdrProblemDetails(resp, err, "USER_NOT_FOUND")
+	}
+	if rspCloseErr := resp.Body.Close(); rspCloseErr != nil {
+		logger.UeauLog.Errorf("CreateAuthenticationStatus response body cannot close: %+v", rspCloseErr)
+	}
+	return nil
+}
+
+// TS 29.503 5.4.2.3 Authentication Result Removal
+func (p *Processor) DeleteAuthDataProcedure(c *gin.Context,
+	authEvent udm_context.AuthEvent,
+	supi string,
+	authEventID string,
+) {
+	defer tracing.StartProcedure(c, "DeleteAuthDataProcedure").End()
+
+	if !authEvent.AuthRemovalInd {
 		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			InvalidParams: []models.InvalidParam{
+				{
+					Param:  "authRemovalInd",
+					Reason: "shall be set to true",
+				},
+			},
 		}
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
 
-		logger.UeauLog.Errorln("ConfirmAuth err:", err.Error())
+	udmUe, ok := p.Context().UdmUeFindBySupi(supi)
+	if ok {
+		if _, exist := udmUe.GetAuthEvent(authEventID); !exist {
+			problemDetails := &models.ProblemDetails{
+				Status: http.StatusNotFound,
+				Cause:  "DATA_NOT_FOUND",
+			}
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
+		}
+	}
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
+	if err != nil {
+		c.JSON(int(pd.Status), pd)
+		return
+	}
+
+	// the UDR keeps one authentication status per UE, so it is deleted only with the last event of the
+	// UE, or if the UDM does not hold the events of the UE (e.g. after a restart). Otherwise it holds the
+	// latest event of the other serving networks.
+	if remaining, exist := udmUe.LatestAuthEvent(authEventID); ok && exist {
+		if problemDetails := p.putAuthenticationStatus(ctx, supi, *remaining); problemDetails != nil {
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
+		}
+	} else {
+		resp, deleteErr := p.Consumer().DeleteAuthenticationStatus(ctx, supi)
+		if deleteErr != nil {
+			problemDetails := util.UdrProblemDetails(resp, deleteErr, "DATA_NOT_FOUND")
+			logger.UeauLog.Errorln("DeleteAuth err:", deleteErr.Error())
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
+		}
+		if rspCloseErr := resp.Body.Close(); rspCloseErr != nil {
+			logger.UeauLog.Errorf("DeleteAuthenticationStatus response body cannot close: %+v", rspCloseErr)
+		}
+	}
+
+	if ok {
+		udmUe.RemoveAuthEvent(authEventID)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetAuthEventProcedure returns a stored authentication event of the UE. When the UDM does not hold
+// the event (e.g. after a restart), the authentication status stored in the UDR is returned instead.
+func (p *Processor) GetAuthEventProcedure(c *gin.Context, supi string, authEventID string) {
+	defer tracing.StartProcedure(c, "GetAuthEventProcedure").End()
+
+	if udmUe, ok := p.Context().UdmUeFindBySupi(supi); ok {
+		if authEvent, exist := udmUe.GetAuthEvent(authEventID); exist {
+			c.JSON(http.StatusOK, authEvent)
+			return
+		}
+	}
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
+	if err != nil {
+		c.JSON(int(pd.Status), pd)
+		return
+	}
+
+	client, err := p.Consumer().CreateUDMClientToUDR(supi)
+	if err != nil {
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
+
+	authEvent, resp, err := client.AuthEventDocumentApi.QueryAuthenticationStatus(ctx, supi, nil)
+	if err != nil {
+		problemDetails := util.UdrProblemDetails(resp, err, "DATA_NOT_FOUND")
+		logger.UeauLog.Warnln("QueryAuthenticationStatus err:", err.Error())
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
 	defer func() {
 		if rspCloseErr := resp.Body.Close(); rspCloseErr != nil {
-			logger.UeauLog.Errorf("CreateAuthenticationStatus response body cannot close: %+v", rspCloseErr)
+			logger.UeauLog.Errorf("QueryAuthenticationStatus response body cannot close: %+v", rspCloseErr)
 		}
 	}()
 
-	c.Status(http.StatusCreated)
+	c.JSON(http.StatusOK, authEvent)
 }
 
 func (p *Processor) GenerateAuthDataProcedure(
@@ -123,16 +274,20 @@ func (p *Processor) GenerateAuthDataProcedure(
 	authInfoRequest models.AuthenticationInfoRequest,
 	supiOrSuci string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GenerateAuthDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
 	}
 	logger.UeauLog.Traceln("In GenerateAuthDataProcedure")
 
-	response := &models.AuthenticationInfoResult{}
+	response := &udm_context.AuthenticationInfoResult{}
 	rand.New(rand.NewSource(time.Now().UnixNano()))
-	supi, err := suci.ToSupi(supiOrSuci, p.Context().SuciProfiles)
+	suciProfiles, releaseSuciProfiles := p.Context().AcquireSuciProfiles()
+	supi, err := suci.ToSupi(supiOrSuci, suciProfiles)
+	releaseSuciProfiles()
 	if err != nil {
 		problemDetails := &models.ProblemDetails{
 			Status: http.StatusForbidden,
@@ -147,34 +302,183 @@ func (p *Processor) GenerateAuthDataProcedure(
 
 	logger.UeauLog.Tracef("supi conversion => [%s]", supi)
 
-	client, err := p.Consumer().CreateUDMClientToUDR(supi)
-	if err != nil {
-		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+	authSubs, problemDetails := p.queryAuthSubsData(ctx, supi)
+	if problemDetails != nil {
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
-	authSubs, res, err := client.AuthenticationDataDocumentApi.QueryAuthSubsData(ctx, supi, nil)
-	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: http.StatusForbidden,
-			Cause:  authenticationRejected,
-			Detail: err.Error(),
+
+	if authInfoRequest.ResynchronizationInfo != nil {
+		metrics.AuthResyncTotal.Inc()
+	}
+	vector, problemDetails := p.generateAuthVector(ctx, supi, &authSubs.AuthenticationSubscription,
+		authInfoRequest.ResynchronizationInfo, supiOrSuci)
+	if problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	RAND, AUTN, RES, CK, IK, SQNxorAK := vector.rand, vector.autn, vector.res, vector.ck, vector.ik, vector.sqnXorAK
+
+	var av models.AuthenticationVector
+	if authSubs.AuthenticationMethod == models.AuthMethod__5_G_AKA {
+		response.AuthType = models.AuthType__5_G_AKA
+
+		// derive XRES*
+		key := append(CK, IK...)
+		FC := ueauth.FC_FOR_RES_STAR_XRES_STAR_DERIVATION
+		P0 := []byte(authInfoRequest.ServingNetworkName)
+		P1 := RAND
+		P2 := RES
+
+		kdfValForXresStar, err := ueauth.GetKDFValue(
+			key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1), P2, ueauth.KDFLen(P2))
+		if err != nil {
+			logger.UeauLog.Errorf("Get kdfValForXresStar err: %+v", err)
 		}
+		xresStar := kdfValForXresStar[len(kdfValForXresStar)/2:]
 
-		switch res.StatusCode {
-		case http.StatusNotFound:
-			logger.UeauLog.Warnf("Return from UDR QueryAuthSubsData error")
-		default:
-			logger.UeauLog.Errorln("Return from UDR QueryAuthSubsData error")
+		// derive Kausf
+		FC = ueauth.FC_FOR_KAUSF_DERIVATION
+		P0 = []byte(authInfoRequest.ServingNetworkName)
+		P1 = SQNxorAK
+		kdfValForKausf, err := ueauth.GetKDFValue(key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1))
+		if err != nil {
+			logger.UeauLog.Errorf("Get kdfValForKausf err: %+v", err)
+		}
+
+		// Fill in rand, xresStar, autn, kausf
+		av.Rand = hex.EncodeToString(RAND)
+		av.XresStar = hex.EncodeToString(xresStar)
+		av.Autn = hex.EncodeToString(AUTN)
+		av.Kausf = hex.EncodeToString(kdfValForKausf)
+		av.AvType = models.AvType__5_G_HE_AKA
+	} else { // EAP-AKA'
+		response.AuthType = models.AuthType_EAP_AKA_PRIME
+
+		// derive CK' and IK'
+		key := append(CK, IK...)
+		FC := ueauth.FC_FOR_CK_PRIME_IK_PRIME_DERIVATION
+		P0 := []byte(authInfoRequest.ServingNetworkName)
+		P1 := SQNxorAK
+		kdfVal, err := ueauth.GetKDFValue(key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1))
+		if err != nil {
+			logger.UeauLog.Errorf("Get kdfVal err: %+v", err)
+		}
+
+		// For TS 35.208 test set 19 & RFC 5448 test vector 1
+		// CK': 0093 962d 0dd8 4aa5 684b 045c 9edf fa04
+		// IK': ccfc 230c a74f cc96 c0a5 d611 64f5 a76
+
+		ckPrime := kdfVal[:len(kdfVal)/2]
+		ikPrime := kdfVal[len(kdfVal)/2:]
+
+		// Fill in rand, xres, autn, ckPrime, ikPrime
+		av.Rand = hex.EncodeToString(RAND)
+		av.Xres = hex.EncodeToString(RES)
+		av.Autn = hex.EncodeToString(AUTN)
+		av.CkPrime = hex.EncodeToString(ckPrime)
+		av.IkPrime = hex.EncodeToString(ikPrime)
+		av.AvType = models.AvType_EAP_AKA_PRIME
+	}
+
+	response.AuthenticationVector = &av
+	response.Supi = supi
+	metrics.AuthVectorsTotal.WithLabelValues(string(response.AuthType)).Inc()
+	// TS 33.535 6.1: indicate to the AUSF that the AKMA anchor key shall be generated
+	response.AkmaInd = authSubs.AkmaAllowed
+	c.JSON(http.StatusOK, response)
+}
+
+// TS 29.503 5.4.2.4 GBA Authentication Vector generation
+func (p *Processor) GenerateGbaAvProcedure(c *gin.Context,
+	gbaAuthInfoRequest udm_context.GbaAuthenticationInfoRequest,
+	supi string,
+) {
+	defer tracing.StartProcedure(c, "GenerateGbaAvProcedure").End()
+
+	if gbaAuthInfoRequest.AuthType != udm_context.GbaAuthType_DIGEST_AKAV1_MD5 {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_INCORRECT",
+			InvalidParams: []models.InvalidParam{
+				{
+					Param:  "authType",
+					Reason: "shall be DIGEST_AKAV1_MD5",
+				},
+			},
 		}
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
-	defer func() {
-		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
-			logger.SdmLog.Errorf("QueryAuthSubsData response body cannot close: %+v", rspCloseErr)
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
+	if err != nil {
+		c.JSON(int(pd.Status), pd)
+		return
+	}
+
+	authSubs, problemDetails := p.queryAuthSubsData(ctx, supi)
+	if problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	vector, problemDetails := p.generateAuthVector(ctx, supi, &authSubs.AuthenticationSubscription,
+		gbaAuthInfoRequest.ResynchronizationInfo, supi)
+	if problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	response := &udm_context.GbaAuthenticationInfoResult{
+		ThreeGAkaAv: &udm_context.ThreeGAkaAv{
+			Rand: hex.EncodeToString(vector.rand),
+			Xres: hex.EncodeToString(vector.res),
+			Autn: hex.EncodeToString(vector.autn),
+			Ck:   hex.EncodeToString(vector.ck),
+			Ik:   hex.EncodeToString(vector.ik),
+		},
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func (p *Processor) queryAuthSubsData(ctx context.Context, supi string) (
+	*udm_context.AuthenticationSubscription, *models.ProblemDetails,
+) {
+	authSubs, res, err := p.Consumer().QueryAuthSubsData(ctx, supi)
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			logger.UeauLog.Warnf("Return from UDR QueryAuthSubsData error")
+		} else {
+			logger.UeauLog.Errorln("Return from UDR QueryAuthSubsData error")
 		}
-	}()
+		return nil, util.UdrProblemDetails(res, err, "USER_NOT_FOUND")
+	}
+	return authSubs, nil
+}
+
+// authVector is the output of the MILENAGE algorithm set (TS 35.206) from which the
+// 5G AKA, EAP-AKA' and GBA authentication vectors are derived
+type authVector struct {
+	rand     []byte
+	autn     []byte
+	res      []byte
+	ck       []byte
+	ik       []byte
+	sqnXorAK []byte
+}
+
+// generateAuthVector runs MILENAGE with the long-term keys of the subscription. The SQN is
+// re-synchronized if resyncInfo is present, and the incremented SQN is stored in the UDR.
+// ueId is only used for logging.
+func (p *Processor) generateAuthVector(ctx context.Context, supi string,
+	authSubs *models.AuthenticationSubscription, resyncInfo *models.ResynchronizationInfo, ueId string,
+) (*authVector, *models.ProblemDetails) {
+	client, err := p.Consumer().CreateUDMClientToUDR(supi)
+	if err != nil {
+		return nil, openapi.ProblemDetailsSystemFailure(err.Error())
+	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	/*
 		K, RAND, CK, IK: 128 bits (16 bytes) (hex len = 32)
@@ -183,28 +487,22 @@ func (p *Processor) GenerateAuthDataProcedure(
 	*/
 
 	hasK, hasOP, hasOPC := false, false, false
-	var kStr, opStr, opcStr string
 	var k, op, opc []byte
 
 	if authSubs.PermanentKey != nil {
-		kStr = authSubs.PermanentKey.PermanentKeyValue
-		if len(kStr) == keyStrLen {
-			k, err = hex.DecodeString(kStr)
-			if err != nil {
-				logger.UeauLog.Errorln("err:", err)
-			} else {
-				hasK = true
-			}
-		} else {
+		permanentKey := authSubs.PermanentKey
+		k, err = p.decodeSubscriberKey(permanentKey.PermanentKeyValue,
+			permanentKey.EncryptionKey, permanentKey.EncryptionAlgorithm, keyStrLen)
+		if err != nil {
 			problemDetails := &models.ProblemDetails{
 				Status: http.StatusForbidden,
 				Cause:  authenticationRejected,
 			}
 
-			logger.UeauLog.Errorln("kStr length is ", len(kStr))
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
+			logger.UeauLog.Errorf("PermanentKey error: %+v", err)
+			return nil, problemDetails
 		}
+		hasK = true
 	} else {
 		problemDetails := &models.ProblemDetails{
 			Status: http.StatusForbidden,
@@ -212,22 +510,18 @@ func (p *Processor) GenerateAuthDataProcedure(
 		}
 
 		logger.UeauLog.Errorln("Nil PermanentKey")
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return nil, problemDetails
 	}
 
 	if authSubs.Milenage != nil {
 		if authSubs.Milenage.Op != nil && authSubs.Milenage.Op.OpValue != "" {
-			opStr = authSubs.Milenage.Op.OpValue
-			if len(opStr) == opStrLen {
-				op, err = hex.DecodeString(opStr)
-				if err != nil {
-					logger.UeauLog.Errorln("err:", err)
-				} else {
-					hasOP = true
-				}
+			milenageOp := authSubs.Milenage.Op
+			op, err = p.decodeSubscriberKey(milenageOp.OpValue,
+				milenageOp.EncryptionKey, milenageOp.EncryptionAlgorithm, opStrLen)
+			if err != nil {
+				logger.UeauLog.Errorf("Op error: %+v", err)
 			} else {
-				logger.UeauLog.Errorln("opStr length is ", len(opStr))
+				hasOP = true
 			}
 		} else {
 			logger.UeauLog.Infoln("Nil Op")
@@ -239,21 +533,16 @@ func (p *Processor) GenerateAuthDataProcedure(
 		}
 
 		logger.UeauLog.Infoln("Nil Milenage")
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return nil, problemDetails
 	}
 
 	if authSubs.Opc != nil && authSubs.Opc.OpcValue != "" {
-		opcStr = authSubs.Opc.OpcValue
-		if len(opcStr) == opcStrLen {
-			opc, err = hex.DecodeString(opcStr)
-			if err != nil {
-				logger.UeauLog.Errorln("err:", err)
-			} else {
-				hasOPC = true
-			}
+		opc, err = p.decodeSubscriberKey(authSubs.Opc.OpcValue,
+			authSubs.Opc.EncryptionKey, authSubs.Opc.EncryptionAlgorithm, opcStrLen)
+		if err != nil {
+			logger.UeauLog.Errorf("Opc error: %+v", err)
 		} else {
-			logger.UeauLog.Errorln("opcStr length is ", len(opcStr))
+			hasOPC = true
 		}
 	} else {
 		logger.UeauLog.Infoln("Nil Opc")
@@ -264,8 +553,7 @@ func (p *Processor) GenerateAuthDataProcedure(
 			Status: http.StatusForbidden,
 			Cause:  authenticationRejected,
 		}
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return nil, problemDetails
 	}
 
 	if !hasOPC {
@@ -281,8 +569,7 @@ func (p *Processor) GenerateAuthDataProcedure(
 			}
 
 			logger.UeauLog.Errorln("Unable to derive OPC")
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
+			return nil, problemDetails
 		}
 	}
 
@@ -297,11 +584,10 @@ func (p *Processor) GenerateAuthDataProcedure(
 		}
 
 		logger.UeauLog.Errorln("err:", err)
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return nil, problemDetails
 	}
 
-	logger.UeauLog.Tracef("K=[%x], sqn=[%x], OP=[%x], OPC=[%x]", k, sqn, op, opc)
+	logger.UeauLog.Tracef("sqn=[%x]", sqn)
 
 	RAND := make([]byte, 16)
 	_, err = cryptoRand.Read(RAND)
@@ -313,8 +599,7 @@ func (p *Processor) GenerateAuthDataProcedure(
 		}
 
 		logger.UeauLog.Errorln("err:", err)
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return nil, problemDetails
 	}
 
 	amfStr := p.strictHex(authSubs.AuthenticationManagementField, 4)
@@ -328,17 +613,16 @@ func (p *Processor) GenerateAuthDataProcedure(
 		}
 
 		logger.UeauLog.Errorln("err:", err)
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return nil, problemDetails
 	}
 
 	logger.UeauLog.Tracef("RAND=[%x], AMF=[%x]", RAND, AMF)
 
 	// re-synchronization
-	if authInfoRequest.ResynchronizationInfo != nil {
+	if resyncInfo != nil {
 		logger.UeauLog.Infof("Authentication re-synchronization")
 
-		Auts, deCodeErr := hex.DecodeString(authInfoRequest.ResynchronizationInfo.Auts)
+		Auts, deCodeErr := hex.DecodeString(resyncInfo.Auts)
 		if deCodeErr != nil {
 			problemDetails := &models.ProblemDetails{
 				Status: http.StatusForbidden,
@@ -347,11 +631,10 @@ func (p *Processor) GenerateAuthDataProcedure(
 			}
 
 			logger.UeauLog.Errorln("err:", deCodeErr)
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
+			return nil, problemDetails
 		}
 
-		randHex, deCodeErr := hex.DecodeString(authInfoRequest.ResynchronizationInfo.Rand)
+		randHex, deCodeErr := hex.DecodeString(resyncInfo.Rand)
 		if deCodeErr != nil {
 			problemDetails := &models.ProblemDetails{
 				Status: http.StatusForbidden,
@@ -360,8 +643,7 @@ func (p *Processor) GenerateAuthDataProcedure(
 			}
 
 			logger.UeauLog.Errorln("err:", deCodeErr)
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
+			return nil, problemDetails
 		}
 
 		SQNms, macS := p.aucSQN(opc, k, Auts, randHex)
@@ -371,12 +653,11 @@ func (p *Processor) GenerateAuthDataProcedure(
 				problemDetails := &models.ProblemDetails{
 					Status: http.StatusForbidden,
 					Cause:  authenticationRejected,
-					Detail: deCodeErr.Error(),
+					Detail: err.Error(),
 				}
 
-				logger.UeauLog.Errorln("err:", deCodeErr)
-				c.JSON(int(problemDetails.Status), problemDetails)
-				return
+				logger.UeauLog.Errorln("err:", err)
+				return nil, problemDetails
 			}
 
 			// increment sqn authSubs.SequenceNumber
@@ -393,22 +674,21 @@ func (p *Processor) GenerateAuthDataProcedure(
 			sqnStr = fmt.Sprintf("%x", bigSQN)
 			sqnStr = p.strictHex(sqnStr, 12)
 		} else {
-			logger.UeauLog.Errorln("Re-Sync MAC failed ", supiOrSuci)
+			logger.UeauLog.Errorln("Re-Sync MAC failed ", ueId)
+			metrics.AuthMacSFailuresTotal.Inc()
 			// Check if suci
-			suciPart := strings.Split(supiOrSuci, "-")
+			suciPart := strings.Split(ueId, "-")
 			if suciPart[suci.PrefixPlace] == suci.PrefixSUCI &&
 				suciPart[suci.SupiTypePlace] == suci.SupiTypeIMSI &&
 				suciPart[suci.SchemePlace] != suci.NullScheme {
-				// Get SuciProfile index and write public key
-				keyIndex, err1 := strconv.Atoi(suciPart[suci.HNPublicKeyIDPlace])
+				// Get SuciProfile by HNPublicKeyID and write public key
+				keyID, err1 := strconv.Atoi(suciPart[suci.HNPublicKeyIDPlace])
 				if err1 != nil {
 					logger.UeauLog.Errorln("Re-Sync Failed UDM Public Key HNPublicKeyIDPlace parse Error")
-				} else if keyIndex < 1 {
-					logger.UeauLog.Errorf("Re-Sync Failed UDM Public Key HNPublicKeyIDPlace keyIndex[%d] < 1",
-						keyIndex)
+				} else if profile, ok := p.Context().GetSuciProfiles()[keyID]; !ok {
+					logger.UeauLog.Errorf("Re-Sync Failed UDM Public Key HNPublicKeyID[%d] unknown", keyID)
 				} else {
-					logger.UeauLog.Errorln("Re-Sync Failed UDM Public Key ",
-						p.Context().SuciProfiles[keyIndex-1].PublicKey)
+					logger.UeauLog.Errorln("Re-Sync Failed UDM Public Key ", profile.PublicKey)
 				}
 			}
 			logger.UeauLog.Errorln("MACS ", macS)
@@ -418,8 +698,7 @@ func (p *Processor) GenerateAuthDataProcedure(
 				Status: http.StatusForbidden,
 				Cause:  "modification is rejected",
 			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
+			return nil, problemDetails
 		}
 	}
 
@@ -434,8 +713,7 @@ func (p *Processor) GenerateAuthDataProcedure(
 		}
 
 		logger.UeauLog.Errorln("err:", err)
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return nil, problemDetails
 	}
 
 	bigSQN.SetString(sqnStr, 16)
@@ -458,15 +736,8 @@ func (p *Processor) GenerateAuthDataProcedure(
 	rsp, err = client.AuthenticationDataDocumentApi.ModifyAuthentication(
 		ctx, supi, patchItemArray)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: http.StatusForbidden,
-			Cause:  "modification is rejected ",
-			Detail: err.Error(),
-		}
-
 		logger.UeauLog.Errorln("update sqn error:", err)
-		c.JSON(int(problemDetails.Status), problemDetails)
-		return
+		return nil, util.UdrProblemDetails(rsp, err, "USER_NOT_FOUND")
 	}
 	defer func() {
 		if rspCloseErr := rsp.Body.Close(); rspCloseErr != nil {
@@ -492,10 +763,9 @@ func (p *Processor) GenerateAuthDataProcedure(
 	if err != nil {
 		logger.UeauLog.Errorln("milenage F2345 err:", err)
 	}
-	logger.UeauLog.Tracef("milenage RES=[%s]", hex.EncodeToString(RES))
 
 	// Generate AUTN
-	logger.UeauLog.Tracef("SQN=[%x], AK=[%x]", sqn, AK)
+	logger.UeauLog.Tracef("SQN=[%x]", sqn)
 	logger.UeauLog.Tracef("AMF=[%x], macA=[%x]", AMF, macA)
 	SQNxorAK := make([]byte, 6)
 	for i := 0; i < len(sqn); i++ {
@@ -505,73 +775,12 @@ func (p *Processor) GenerateAuthDataProcedure(
 	AUTN := append(append(SQNxorAK, AMF...), macA...)
 	logger.UeauLog.Tracef("AUTN=[%x]", AUTN)
 
-	var av models.AuthenticationVector
-	if authSubs.AuthenticationMethod == models.AuthMethod__5_G_AKA {
-		response.AuthType = models.AuthType__5_G_AKA
-
-		// derive XRES*
-		key := append(CK, IK...)
-		FC := ueauth.FC_FOR_RES_STAR_XRES_STAR_DERIVATION
-		P0 := []byte(authInfoRequest.ServingNetworkName)
-		P1 := RAND
-		P2 := RES
-
-		kdfValForXresStar, err := ueauth.GetKDFValue(
-			key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1), P2, ueauth.KDFLen(P2))
-		if err != nil {
-			logger.UeauLog.Errorf("Get kdfValForXresStar err: %+v", err)
-		}
-		xresStar := kdfValForXresStar[len(kdfValForXresStar)/2:]
-		logger.UeauLog.Tracef("xresStar=[%x]", xresStar)
-
-		// derive Kausf
-		FC = ueauth.FC_FOR_KAUSF_DERIVATION
-		P0 = []byte(authInfoRequest.ServingNetworkName)
-		P1 = SQNxorAK
-		kdfValForKausf, err := ueauth.GetKDFValue(key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1))
-		if err != nil {
-			logger.UeauLog.Errorf("Get kdfValForKausf err: %+v", err)
-		}
-		logger.UeauLog.Tracef("Kausf=[%x]", kdfValForKausf)
-
-		// Fill in rand, xresStar, autn, kausf
-		av.Rand = hex.EncodeToString(RAND)
-		av.XresStar = hex.EncodeToString(xresStar)
-		av.Autn = hex.EncodeToString(AUTN)
-		av.Kausf = hex.EncodeToString(kdfValForKausf)
-		av.AvType = models.AvType__5_G_HE_AKA
-	} else { // EAP-AKA'
-		response.AuthType = models.AuthType_EAP_AKA_PRIME
-
-		// derive CK' and IK'
-		key := append(CK, IK...)
-		FC := ueauth.FC_FOR_CK_PRIME_IK_PRIME_DERIVATION
-		P0 := []byte(authInfoRequest.ServingNetworkName)
-		P1 := SQNxorAK
-		kdfVal, err := ueauth.GetKDFValue(key, FC, P0, ueauth.KDFLen(P0), P1, ueauth.KDFLen(P1))
-		if err != nil {
-			logger.UeauLog.Errorf("Get kdfVal err: %+v", err)
-		}
-		logger.UeauLog.Tracef("kdfVal=[%x] (len=%d)", kdfVal, len(kdfVal))
-
-		// For TS 35.208 test set 19 & RFC 5448 test vector 1
-		// CK': 0093 962d 0dd8 4aa5 684b 045c 9edf fa04
-		// IK': ccfc 230c a74f cc96 c0a5 d611 64f5 a76
-
-		ckPrime := kdfVal[:len(kdfVal)/2]
-		ikPrime := kdfVal[len(kdfVal)/2:]
-		logger.UeauLog.Tracef("ckPrime=[%x], kPrime=[%x]", ckPrime, ikPrime)
-
-		// Fill in rand, xres, autn, ckPrime, ikPrime
-		av.Rand = hex.EncodeToString(RAND)
-		av.Xres = hex.EncodeToString(RES)
-		av.Autn = hex.EncodeToString(AUTN)
-		av.CkPrime = hex.EncodeToString(ckPrime)
-		av.IkPrime = hex.EncodeToString(ikPrime)
-		av.AvType = models.AvType_EAP_AKA_PRIME
-	}
-
-	response.AuthenticationVector = &av
-	response.Supi = supi
-	c.JSON(http.StatusOK, response)
+	return &authVector{
+		rand:     RAND,
+		autn:     AUTN,
+		res:      RES,
+		ck:       CK,
+		ik:       IK,
+		sqnXorAK: SQNxorAK,
+	}, nil
 }
@@ -0,0 +1,269 @@
+package processor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/pkg/app"
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/util/milenage"
+)
+
+const (
+	ueauTestUdr  = "http://127.0.0.4:8000"
+	ueauTestSupi = "imsi-208930000000001"
+	// TS 35.208 test set 1
+	ueauTestK   = "465b5ce8b199b49faa5f0a2ee238a6bc"
+	ueauTestOpc = "cd63cb71954a9f4e48a5994e37a02baf"
+	ueauTestSqn = "ff9bb4d0b607"
+	ueauTestAmf = "b9b9"
+)
+
+// processorTestUdm serves the procedures with a consumer reaching the UDR
+type processorTestUdm struct {
+	*app.MockApp
+	consumer *consumer.Consumer
+}
+
+func (u *processorTestUdm) Consumer() *consumer.Consumer { return u.consumer }
+
+func newUeauTestProcessor(t *testing.T) *Processor {
+	ctrl := gomock.NewController(t)
+	t.Cleanup(ctrl.Finish)
+	mockApp := app.NewMockApp(ctrl)
+	mockApp.EXPECT().Context().AnyTimes().Return(&udm_context.UDMContext{
+		NrfDisabled: true,
+		StaticUdrs:  []factory.StaticUdr{{Uri: ueauTestUdr}},
+	})
+	udrConsumer, err := consumer.NewConsumer(mockApp)
+	require.NoError(t, err)
+	p, err := NewProcessor(&processorTestUdm{MockApp: mockApp, consumer: udrConsumer})
+	require.NoError(t, err)
+	return p
+}
+
+// mockAuthSubscription replies the authentication subscription of the test UE, and expects the
+// incremented SQN to be stored
+func mockAuthSubscription(akmaAllowed bool) {
+	gock.New(ueauTestUdr).
+		Get("/nudr-dr/v1/subscription-data/" + ueauTestSupi + "/authentication-data/authentication-subscription").
+		Reply(http.StatusOK).
+		JSON(map[string]interface{}{
+			"authenticationMethod": "5G_AKA",
+			"permanentKey": map[string]interface{}{
+				"permanentKeyValue": ueauTestK,
+			},
+			"sequenceNumber":                ueauTestSqn,
+			"authenticationManagementField": ueauTestAmf,
+			"milenage":                      map[string]interface{}{},
+			"opc": map[string]interface{}{
+				"opcValue": ueauTestOpc,
+			},
+			"akmaAllowed": akmaAllowed,
+		})
+	gock.New(ueauTestUdr).
+		Patch("/nudr-dr/v1/subscription-data/" + ueauTestSupi + "/authentication-data/authentication-subscription").
+		BodyString(`"path":"/sequenceNumber".*"value":"ff9bb4d0b608"`).
+		Reply(http.StatusNoContent)
+}
+
+func newUeauTestContext(w *httptest.ResponseRecorder) *gin.Context {
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	return c
+}
+
+func TestGenerateGbaAvProcedure(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	p := newUeauTestProcessor(t)
+	mockAuthSubscription(false)
+
+	w := httptest.NewRecorder()
+	p.GenerateGbaAvProcedure(newUeauTestContext(w), udm_context.GbaAuthenticationInfoRequest{
+		AuthType: udm_context.GbaAuthType_DIGEST_AKAV1_MD5,
+	}, ueauTestSupi)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.True(t, gock.IsDone())
+
+	var result udm_context.GbaAuthenticationInfoResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.NotNil(t, result.ThreeGAkaAv)
+	av := result.ThreeGAkaAv
+
+	// the AV is derived from the RAND with the current SQN
+	k, err := hex.DecodeString(ueauTestK)
+	require.NoError(t, err)
+	opc, err := hex.DecodeString(ueauTestOpc)
+	require.NoError(t, err)
+	sqn, err := hex.DecodeString(ueauTestSqn)
+	require.NoError(t, err)
+	amf, err := hex.DecodeString(ueauTestAmf)
+	require.NoError(t, err)
+	rand, err := hex.DecodeString(av.Rand)
+	require.NoError(t, err)
+	require.Len(t, rand, 16)
+
+	macA := make([]byte, 8)
+	require.NoError(t, milenage.F1(opc, k, rand, sqn, amf, macA, nil))
+	res, ck, ik, ak := make([]byte, 8), make([]byte, 16), make([]byte, 16), make([]byte, 6)
+	require.NoError(t, milenage.F2345(opc, k, rand, res, ck, ik, ak, nil))
+	sqnXorAk := make([]byte, 6)
+	for i := range sqn {
+		sqnXorAk[i] = sqn[i] ^ ak[i]
+	}
+
+	require.Equal(t, hex.EncodeToString(res), av.Xres)
+	require.Equal(t, hex.EncodeToString(ck), av.Ck)
+	require.Equal(t, hex.EncodeToString(ik), av.Ik)
+	require.Equal(t, hex.EncodeToString(sqnXorAk)+ueauTestAmf+hex.EncodeToString(macA), av.Autn)
+}
+
+func TestGenerateGbaAvProcedureAuthType(t *testing.T) {
+	p := newUeauTestProcessor(t)
+
+	w := httptest.NewRecorder()
+	p.GenerateGbaAvProcedure(newUeauTestContext(w), udm_context.GbaAuthenticationInfoRequest{
+		AuthType: "DIGEST_MD5",
+	}, ueauTestSupi)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	var problemDetails models.ProblemDetails
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problemDetails))
+	require.Equal(t, "MANDATORY_IE_INCORRECT", problemDetails.Cause)
+	require.Equal(t, "authType", problemDetails.InvalidParams[0].Param)
+}
+
+func TestGenerateAuthDataProcedureAkmaInd(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	testCases := []struct {
+		name        string
+		akmaAllowed bool
+	}{
+		{"AKMA Allowed", true},
+		{"AKMA Not Allowed", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newUeauTestProcessor(t)
+			mockAuthSubscription(tc.akmaAllowed)
+
+			w := httptest.NewRecorder()
+			p.GenerateAuthDataProcedure(newUeauTestContext(w), models.AuthenticationInfoRequest{
+				ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org",
+			}, ueauTestSupi)
+			require.Equal(t, http.StatusOK, w.Code)
+			require.True(t, gock.IsDone())
+
+			var result map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			require.Equal(t, "5G_AKA", result["authType"])
+			require.Equal(t, ueauTestSupi, result["supi"])
+			if tc.akmaAllowed {
+				require.Equal(t, true, result["akmaInd"])
+			} else {
+				// akmaInd is omitted when AKMA is not allowed
+				require.NotContains(t, result, "akmaInd")
+			}
+		})
+	}
+}
+
+func TestAuthEventProcedures(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	p := newUeauTestProcessor(t)
+	authStatusPath := "/nudr-dr/v1/subscription-data/" + ueauTestSupi + "/authentication-data/authentication-status"
+	gock.New(ueauTestUdr).Put(authStatusPath).Times(3).Reply(http.StatusNoContent)
+
+	confirmAuth := func(servingNetworkName string, success bool) string {
+		w := httptest.NewRecorder()
+		p.ConfirmAuthDataProcedure(newUeauTestContext(w), models.AuthEvent{
+			NfInstanceId:       "f3d1a3f1-8a2c-4d0e-9b61-3a1b6c2d9e10",
+			Success:            success,
+			AuthType:           models.AuthType__5_G_AKA,
+			ServingNetworkName: servingNetworkName,
+		}, ueauTestSupi)
+		require.Equal(t, http.StatusCreated, w.Code)
+		location := w.Header().Get("Location")
+		require.Regexp(t, factory.UdmUeauResUriPrefix+"/"+ueauTestSupi+"/auth-events/[0-9a-f-]{36}$", location)
+		return location[len(location)-36:]
+	}
+	removeAuth := func(authEventID string, authRemovalInd bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c := newUeauTestContext(w)
+		p.DeleteAuthDataProcedure(c, udm_context.AuthEvent{
+			AuthEvent:      models.AuthEvent{ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org"},
+			AuthRemovalInd: authRemovalInd,
+		}, ueauTestSupi, authEventID)
+		// the 204 has no body to write the header
+		c.Writer.WriteHeaderNow()
+		return w
+	}
+
+	// the UE keeps one event per serving network
+	authEventID := confirmAuth("5G:mnc093.mcc208.3gppnetwork.org", false)
+	require.Equal(t, authEventID, confirmAuth("5G:mnc093.mcc208.3gppnetwork.org", true))
+	otherAuthEventID := confirmAuth("5G:mnc001.mcc001.3gppnetwork.org", true)
+	require.NotEqual(t, authEventID, otherAuthEventID)
+	udmUe, ok := p.Context().UdmUeFindBySupi(ueauTestSupi)
+	require.True(t, ok)
+	require.Len(t, udmUe.AuthEvents, 2)
+	authEvent, ok := udmUe.ServingNetworkAuthEvent("5G:mnc093.mcc208.3gppnetwork.org")
+	require.True(t, ok)
+	require.True(t, authEvent.Success)
+
+	w := httptest.NewRecorder()
+	p.GetAuthEventProcedure(newUeauTestContext(w), ueauTestSupi, authEventID)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"servingNetworkName":"5G:mnc093.mcc208.3gppnetwork.org"`)
+
+	// the removal requires authRemovalInd, and an event of the UE
+	w = removeAuth(authEventID, false)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "authRemovalInd")
+	w = removeAuth("3c1f7a52-9d1e-4b7c-8f3a-2e6d5b4c3a21", true)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	require.Contains(t, w.Body.String(), "DATA_NOT_FOUND")
+
+	// the UDR keeps the authentication status of the other serving network
+	gock.New(ueauTestUdr).Put(authStatusPath).
+		BodyString(`"servingNetworkName":"5G:mnc001.mcc001.3gppnetwork.org"`).
+		Reply(http.StatusNoContent)
+	w = removeAuth(authEventID, true)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	_, ok = udmUe.GetAuthEvent(authEventID)
+	require.False(t, ok)
+	_, ok = udmUe.GetAuthEvent(otherAuthEventID)
+	require.True(t, ok)
+	require.True(t, gock.IsDone())
+
+	// the authentication status is deleted with the last event
+	gock.New(ueauTestUdr).Delete(authStatusPath).Reply(http.StatusNoContent)
+	w = removeAuth(otherAuthEventID, true)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Empty(t, udmUe.AuthEvents)
+	require.True(t, gock.IsDone())
+}
@@ -1,69 +1,113 @@
 package processor
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/tracing"
 )
 
 func (p *Processor) DataChangeNotificationProcedure(c *gin.Context,
 	notifyItems []models.NotifyItem,
 	supi string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDM_SDM, models.NfType_UDM)
+	defer tracing.StartProcedure(c, "DataChangeNotificationProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDM_SDM, models.NfType_UDM)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
 	}
+	ctx = consumer.WithSbiCallback(ctx, "Nudm_SDM_Notification")
+
+	ue, ok := p.Context().UdmUeFindBySupi(supi)
+	if !ok {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusNotFound,
+			Cause:  "USER_NOT_FOUND",
+		}
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
 
-	ue, _ := p.Context().UdmUeFindBySupi(supi)
+	var problemDetails *models.ProblemDetails
+	for _, subscriptionDataSubscription := range ue.UdmSubsToNotify {
+		if pd := p.sendDataChangeNotification(ctx, subscriptionDataSubscription.OriginalCallbackReference,
+			notifyItems); pd != nil {
+			problemDetails = pd
+		}
+	}
+	// the SDM subscribers are notified with the binding they have provided, so the notifications can be
+	// rerouted to an alternate consumer
+	for subscriptionID, sdmSubscription := range ue.SubscribeToNotifChange {
+		bindingCtx := consumer.WithSbiRoutingBinding(ctx, ue.SdmSubscriptionRoutingBindings[subscriptionID])
+		if pd := p.sendDataChangeNotification(bindingCtx, sdmSubscription.CallbackReference,
+			notifyItems); pd != nil {
+			problemDetails = pd
+		}
+	}
 
+	if problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// sendDataChangeNotification sends the changed data to the callback of a subscriber
+func (p *Processor) sendDataChangeNotification(ctx context.Context, onDataChangeNotificationurl string,
+	notifyItems []models.NotifyItem,
+) *models.ProblemDetails {
 	clientAPI := p.Consumer().GetSDMClient("DataChangeNotification")
 
 	var problemDetails *models.ProblemDetails
-	for _, subscriptionDataSubscription := range ue.UdmSubsToNotify {
-		onDataChangeNotificationurl := subscriptionDataSubscription.OriginalCallbackReference
-		dataChangeNotification := models.ModificationNotification{}
-		dataChangeNotification.NotifyItems = notifyItems
-
-		httpResponse, err := clientAPI.DataChangeNotificationCallbackDocumentApi.OnDataChangeNotification(
-			ctx, onDataChangeNotificationurl, dataChangeNotification)
-		if err != nil {
-			if httpResponse == nil {
-				logger.HttpLog.Error(err.Error())
-				problemDetails = &models.ProblemDetails{
-					Status: http.StatusForbidden,
-					Detail: err.Error(),
-				}
-			} else {
-				logger.HttpLog.Errorln(err.Error())
-
-				problemDetails = &models.ProblemDetails{
-					Status: int32(httpResponse.StatusCode),
-					Detail: err.Error(),
-				}
+	dataChangeNotification := models.ModificationNotification{}
+	dataChangeNotification.NotifyItems = notifyItems
+
+	httpResponse, err := clientAPI.DataChangeNotificationCallbackDocumentApi.OnDataChangeNotification(
+		ctx, onDataChangeNotificationurl, dataChangeNotification)
+	if err != nil {
+		if httpResponse == nil {
+			logger.HttpLog.Error(err.Error())
+			problemDetails = &models.ProblemDetails{
+				Status: http.StatusForbidden,
+				Detail: err.Error(),
 			}
-		}
-		defer func() {
-			if rspCloseErr := httpResponse.Body.Close(); rspCloseErr != nil {
-				logger.HttpLog.Errorf("OnDataChangeNotification response body cannot close: %+v", rspCloseErr)
+		} else {
+			logger.HttpLog.Errorln(err.Error())
+
+			problemDetails = &models.ProblemDetails{
+				Status: int32(httpResponse.StatusCode),
+				Detail: err.Error(),
 			}
-		}()
+		}
 	}
-
-	c.JSON(int(problemDetails.Status), problemDetails)
+	if httpResponse != nil {
+		if rspCloseErr := httpResponse.Body.Close(); rspCloseErr != nil {
+			logger.HttpLog.Errorf("OnDataChangeNotification response body cannot close: %+v", rspCloseErr)
+		}
+	}
+	return problemDetails
 }
 
-func (p *Processor) SendOnDeregistrationNotification(ueId string, onDeregistrationNotificationUrl string,
-	deregistData models.DeregistrationData,
+// SendOnDeregistrationNotification notifies the old AMF of its deregistration. The notification is
+// traced as a child of the span in traceCtx.
+func (p *Processor) SendOnDeregistrationNotification(traceCtx context.Context, ueId string,
+	onDeregistrationNotificationUrl string, deregistData models.DeregistrationData,
 ) *models.ProblemDetails {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDM_UECM, models.NfType_UDM)
+	traceCtx, span := tracing.Start(traceCtx, "SendOnDeregistrationNotification")
+	defer span.End()
+
+	ctx, pd, err := p.getTokenCtx(traceCtx, models.ServiceName_NUDM_UECM, models.NfType_UDM)
 	if err != nil {
 		return pd
 	}
+	ctx = consumer.WithSbiCallback(ctx, "Nudm_UECM_DeregistrationNotification")
 
 	clientAPI := p.Consumer().GetUECMClient("SendOnDeregistrationNotification")
 
@@ -98,3 +142,30 @@ func (p *Processor) SendOnDeregistrationNotification(ueId string, onDeregistrati
 
 	return nil
 }
+
+// NfStatusNotificationProcedure handles the NF status notification of the NRF (TS 29.510 5.2.2.6.2)
+func (p *Processor) NfStatusNotificationProcedure(c *gin.Context, notificationData models.NotificationData) {
+	defer tracing.StartProcedure(c, "NfStatusNotificationProcedure").End()
+
+	if notificationData.Event == "" || notificationData.NfInstanceUri == "" {
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusBadRequest,
+			Cause:  "MANDATORY_IE_MISSING",
+			Detail: "event and nfInstanceUri are mandatory",
+		}
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+
+	if err := p.Consumer().HandleNFStatusNotification(&notificationData); err != nil {
+		logger.CallbackLog.Warnf("NF status notification rejected: %+v", err)
+		problemDetails := &models.ProblemDetails{
+			Status: http.StatusForbidden,
+			Cause:  "UNSPECIFIED",
+			Detail: err.Error(),
+		}
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package processor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/util"
+)
+
+func TestDataChangeNotificationRoutingBinding(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+	consumer.InstrumentSbiHeaders(openapi.GetHttpClient())
+
+	p := newUeauTestProcessor(t)
+	gock.New(ueauTestUdr).
+		Post("/nudr-dr/v1/subscription-data/" + ueauTestSupi + "/context-data/sdm-subscriptions").
+		Reply(http.StatusCreated).
+		JSON(map[string]interface{}{
+			"nfInstanceId":          "b0a1c2d3-0000-4000-8000-000000000001",
+			"callbackReference":     "http://127.0.0.18:8000/namf-callback/v1/sdm-notify",
+			"monitoredResourceUris": []string{"/nudm-sdm/v1/" + ueauTestSupi + "/am-data"},
+			"subscriptionId":        "1",
+		})
+	// the notification carries the binding the AMF has provided for its callbacks
+	gock.New("http://127.0.0.18:8000").
+		Post("/namf-callback/v1/sdm-notify").
+		MatchHeader(util.HeaderSbiRoutingBinding, "^bl=nfset; nfset=set1.amfset.5gc.mnc093.mcc208$").
+		MatchHeader(util.HeaderSbiCallback, "Nudm_SDM_Notification").
+		Reply(http.StatusNoContent)
+
+	w := httptest.NewRecorder()
+	c := newUeauTestContext(w)
+	c.Request.Header.Set(util.HeaderSbiBinding, "bl=nfset; nfset=set1.amfset.5gc.mnc093.mcc208; scope=callback")
+	p.SubscribeProcedure(c, &models.SdmSubscription{
+		NfInstanceId:          "b0a1c2d3-0000-4000-8000-000000000001",
+		CallbackReference:     "http://127.0.0.18:8000/namf-callback/v1/sdm-notify",
+		MonitoredResourceUris: []string{"/nudm-sdm/v1/" + ueauTestSupi + "/am-data"},
+	}, ueauTestSupi)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = httptest.NewRecorder()
+	c = newUeauTestContext(w)
+	p.DataChangeNotificationProcedure(c, []models.NotifyItem{{
+		ResourceId: "/nudm-sdm/v1/" + ueauTestSupi + "/am-data",
+	}}, ueauTestSupi)
+	// the 204 has no body to write the header
+	c.Writer.WriteHeaderNow()
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.True(t, gock.IsDone())
+}
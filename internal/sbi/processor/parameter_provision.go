@@ -8,13 +8,18 @@ import (
 	"github.com/free5gc/openapi"
 	"github.com/free5gc/openapi/models"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/tracing"
+	"github.com/free5gc/udm/internal/util"
 )
 
 func (p *Processor) UpdateProcedure(c *gin.Context,
 	updateRequest models.PpData,
 	gpsi string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "UpdateProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -25,14 +30,11 @@ func (p *Processor) UpdateProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, gpsi)
 
 	res, err := clientAPI.ProvisionedParameterDataDocumentApi.ModifyPpData(ctx, gpsi, nil)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: int32(res.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
+		problemDetails := util.UdrProblemDetails(res, err, "USER_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -1,7 +1,14 @@
 package processor
 
 import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
 	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/tracing"
 	"github.com/free5gc/udm/pkg/app"
 )
 
@@ -13,6 +20,11 @@ type ProcessorUdm interface {
 
 type Processor struct {
 	ProcessorUdm
+
+	// notifications being sent in the background, no more are sent once notificationsClosed is set
+	notificationsMu     sync.Mutex
+	notificationsClosed bool
+	notifications       sync.WaitGroup
 }
 
 func NewProcessor(udm ProcessorUdm) (*Processor, error) {
@@ -21,3 +33,54 @@ func NewProcessor(udm ProcessorUdm) (*Processor, error) {
 	}
 	return p, nil
 }
+
+// getTokenCtx returns the context of GetTokenCtx carrying the span of parent, so the trace context
+// of the procedure is propagated to the UDR and the other NFs
+func (p *Processor) getTokenCtx(parent context.Context, serviceName models.ServiceName,
+	targetNF models.NfType,
+) (context.Context, *models.ProblemDetails, error) {
+	ctx, pd, err := p.Context().GetTokenCtx(serviceName, targetNF)
+	if err != nil {
+		return ctx, pd, err
+	}
+	return tracing.WithSpan(ctx, parent), pd, nil
+}
+
+// goNotify sends a notification in the background, which is waited by WaitNotifications. The
+// notification is dropped once WaitNotifications is called.
+func (p *Processor) goNotify(notify func()) {
+	p.notificationsMu.Lock()
+	defer p.notificationsMu.Unlock()
+	if p.notificationsClosed {
+		logger.ProcLog.Warnf("Notification dropped at shutdown")
+		return
+	}
+	p.notifications.Add(1)
+	go func() {
+		defer p.notifications.Done()
+		notify()
+	}()
+}
+
+// WaitNotifications stops accepting notifications and waits for those being sent, e.g. at shutdown.
+// It returns false if the timeout expires first.
+func (p *Processor) WaitNotifications(timeout time.Duration) bool {
+	p.notificationsMu.Lock()
+	p.notificationsClosed = true
+	p.notificationsMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.notifications.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
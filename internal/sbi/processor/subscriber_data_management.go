@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -12,10 +13,15 @@ import (
 	"github.com/free5gc/openapi/models"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/tracing"
+	"github.com/free5gc/udm/internal/util"
 )
 
 func (p *Processor) GetAmDataProcedure(c *gin.Context, supi string, plmnID string, supportedFeatures string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetAmDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -29,23 +35,15 @@ func (p *Processor) GetAmDataProcedure(c *gin.Context, supi string, plmnID strin
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	accessAndMobilitySubscriptionDataResp, res, err := clientAPI.AccessAndMobilitySubscriptionDataDocumentApi.
 		QueryAmData(ctx, supi, plmnID, &queryAmDataParamOpts)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Errorf(err.Error())
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Errorf("Response State: %+v", err.Error())
-		} else {
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Errorf(err.Error())
+		problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -70,9 +68,12 @@ func (p *Processor) GetAmDataProcedure(c *gin.Context, supi string, plmnID strin
 }
 
 func (p *Processor) GetIdTranslationResultProcedure(c *gin.Context, gpsi string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetIdTranslationResultProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
+		return
 	}
 	var idTranslationResult models.IdTranslationResult
 	var getIdentityDataParamOpts Nudr_DataRepository.GetIdentityDataParamOpts
@@ -83,23 +84,15 @@ func (p *Processor) GetIdTranslationResultProcedure(c *gin.Context, gpsi string)
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, gpsi)
 
 	idTranslationResultResp, res, err := clientAPI.QueryIdentityDataBySUPIOrGPSIDocumentApi.GetIdentityData(
 		ctx, gpsi, &getIdentityDataParamOpts)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Errorf(err.Error())
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Errorf("Response State: %+v", err.Error())
-		} else {
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Errorf(err.Error())
+		problemDetails := util.UdrProblemDetails(res, err, "USER_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -135,7 +128,9 @@ func (p *Processor) GetSupiProcedure(c *gin.Context,
 	dataSetNames []string,
 	supportedFeatures string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetSupiProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -156,6 +151,7 @@ func (p *Processor) GetSupiProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	var subscriptionDataSets, subsDataSetBody models.SubscriptionDataSets
 	var ueContextInSmfDataResp models.UeContextInSmfData
@@ -179,20 +175,10 @@ func (p *Processor) GetSupiProcedure(c *gin.Context,
 		amData, res, err := clientAPI.AccessAndMobilitySubscriptionDataDocumentApi.QueryAmData(
 			ctx, supi, plmnID, &queryAmDataParamOpts)
 		if err != nil {
-			if res == nil {
-				logger.SdmLog.Errorf(err.Error())
-			} else if err.Error() != res.Status {
-				logger.SdmLog.Errorf("Response State: %+v", err.Error())
-			} else {
-				problemDetails := &models.ProblemDetails{
-					Status: int32(res.StatusCode),
-					Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-					Detail: err.Error(),
-				}
-
-				c.JSON(int(problemDetails.Status), problemDetails)
-				return
-			}
+			logger.SdmLog.Errorf(err.Error())
+			problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
 		}
 		defer func() {
 			if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -224,20 +210,10 @@ func (p *Processor) GetSupiProcedure(c *gin.Context,
 		smfSelData, res, err := clientAPI.SMFSelectionSubscriptionDataDocumentApi.QuerySmfSelectData(ctx,
 			supi, plmnID, &querySmfSelectDataParamOpts)
 		if err != nil {
-			if res == nil {
-				logger.SdmLog.Errorln(err.Error())
-			} else if err.Error() != res.Status {
-				logger.SdmLog.Errorln(err.Error())
-			} else {
-				problemDetails := &models.ProblemDetails{
-					Status: int32(res.StatusCode),
-					Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-					Detail: err.Error(),
-				}
-
-				c.JSON(int(problemDetails.Status), problemDetails)
-				return
-			}
+			logger.SdmLog.Errorln(err.Error())
+			problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
 		}
 		defer func() {
 			if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -271,20 +247,10 @@ func (p *Processor) GetSupiProcedure(c *gin.Context,
 		pdusess, res, err := clientAPI.SMFRegistrationsCollectionApi.QuerySmfRegList(
 			ctx, supi, &querySmfRegListParamOpts)
 		if err != nil {
-			if res == nil {
-				logger.SdmLog.Errorf(err.Error())
-			} else if err.Error() != res.Status {
-				logger.SdmLog.Errorf("Response State: %+v", err.Error())
-			} else {
-				problemDetails := &models.ProblemDetails{
-					Status: int32(res.StatusCode),
-					Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-					Detail: err.Error(),
-				}
-
-				c.JSON(int(problemDetails.Status), problemDetails)
-				return
-			}
+			logger.SdmLog.Errorf(err.Error())
+			problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
 		}
 		defer func() {
 			if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -336,20 +302,10 @@ func (p *Processor) GetSupiProcedure(c *gin.Context,
 		sessionManagementSubscriptionData, res, err := clientAPI.SessionManagementSubscriptionDataApi.
 			QuerySmData(ctx, supi, plmnID, &querySmDataParamOpts)
 		if err != nil {
-			if res == nil {
-				logger.SdmLog.Errorf(err.Error())
-			} else if err.Error() != res.Status {
-				logger.SdmLog.Errorf("Response State: %+v", err.Error())
-			} else {
-				problemDetails := &models.ProblemDetails{
-					Status: int32(res.StatusCode),
-					Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-					Detail: err.Error(),
-				}
-
-				c.JSON(int(problemDetails.Status), problemDetails)
-				return
-			}
+			logger.SdmLog.Errorf(err.Error())
+			problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+			c.JSON(int(problemDetails.Status), problemDetails)
+			return
 		}
 		defer func() {
 			if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -382,20 +338,8 @@ func (p *Processor) GetSupiProcedure(c *gin.Context,
 		traceData, res, err := clientAPI.TraceDataDocumentApi.QueryTraceData(
 			ctx, supi, plmnID, &queryTraceDataParamOpts)
 		if err != nil {
-			if res == nil {
-				logger.SdmLog.Errorf(err.Error())
-			} else if err.Error() != res.Status {
-				logger.SdmLog.Errorf("Response State: %+v", err.Error())
-			} else {
-				problemDetails := &models.ProblemDetails{
-					Status: int32(res.StatusCode),
-					Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-					Detail: err.Error(),
-				}
-				c.JSON(int(problemDetails.Status), problemDetails)
-				return
-			}
-			problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+			logger.SdmLog.Errorf(err.Error())
+			problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
 			c.JSON(int(problemDetails.Status), problemDetails)
 			return
 		}
@@ -431,7 +375,9 @@ func (p *Processor) GetSupiProcedure(c *gin.Context,
 }
 
 func (p *Processor) GetSharedDataProcedure(c *gin.Context, sharedDataIds []string, supportedFeatures string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetSharedDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -442,6 +388,7 @@ func (p *Processor) GetSharedDataProcedure(c *gin.Context, sharedDataIds []strin
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, "")
 
 	var getSharedDataParamOpts Nudr_DataRepository.GetSharedDataParamOpts
 	getSharedDataParamOpts.SupportedFeatures = optional.NewString(supportedFeatures)
@@ -449,21 +396,10 @@ func (p *Processor) GetSharedDataProcedure(c *gin.Context, sharedDataIds []strin
 	sharedDataResp, res, err := clientAPI.RetrievalOfSharedDataApi.GetSharedData(ctx, sharedDataIds,
 		&getSharedDataParamOpts)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			logger.SdmLog.Warnln(err)
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -492,9 +428,12 @@ func (p *Processor) GetSmDataProcedure(
 	Snssai string,
 	supportedFeatures string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetSmDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
+		return
 	}
 	logger.SdmLog.Infof("getSmDataProcedure: SUPI[%s] PLMNID[%s] DNN[%s] SNssai[%s]", supi, plmnID, Dnn, Snssai)
 
@@ -504,6 +443,7 @@ func (p *Processor) GetSmDataProcedure(
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	var querySmDataParamOpts Nudr_DataRepository.QuerySmDataParamOpts
 	querySmDataParamOpts.SingleNssai = optional.NewInterface(Snssai)
@@ -511,20 +451,10 @@ func (p *Processor) GetSmDataProcedure(
 	sessionManagementSubscriptionDataResp, res, err := clientAPI.SessionManagementSubscriptionDataApi.
 		QuerySmData(ctx, supi, plmnID, &querySmDataParamOpts)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			logger.SdmLog.Warnln(err)
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -576,7 +506,9 @@ func (p *Processor) GetSmDataProcedure(
 }
 
 func (p *Processor) GetNssaiProcedure(c *gin.Context, supi string, plmnID string, supportedFeatures string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetNssaiProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -590,25 +522,15 @@ func (p *Processor) GetNssaiProcedure(c *gin.Context, supi string, plmnID string
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	accessAndMobilitySubscriptionDataResp, res, err := clientAPI.AccessAndMobilitySubscriptionDataDocumentApi.
 		QueryAmData(ctx, supi, plmnID, &queryAmDataParamOpts)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			logger.SdmLog.Warnln(err)
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -635,7 +557,9 @@ func (p *Processor) GetNssaiProcedure(c *gin.Context, supi string, plmnID string
 }
 
 func (p *Processor) GetSmfSelectDataProcedure(c *gin.Context, supi string, plmnID string, supportedFeatures string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetSmfSelectDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -650,27 +574,15 @@ func (p *Processor) GetSmfSelectDataProcedure(c *gin.Context, supi string, plmnI
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	p.Context().CreateSmfSelectionSubsDataforUe(supi, body)
 
 	smfSelectionSubscriptionDataResp, res, err := clientAPI.SMFSelectionSubscriptionDataDocumentApi.
 		QuerySmfSelectData(ctx, supi, plmnID, &querySmfSelectDataParamOpts)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			logger.SdmLog.Warnln(err)
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
-		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -697,7 +609,9 @@ func (p *Processor) GetSmfSelectDataProcedure(c *gin.Context, supi string, plmnI
 }
 
 func (p *Processor) SubscribeToSharedDataProcedure(c *gin.Context, sdmSubscription *models.SdmSubscription) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDM_SDM, models.NfType_UDM)
+	defer tracing.StartProcedure(c, "SubscribeToSharedDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDM_SDM, models.NfType_UDM)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -705,22 +619,16 @@ func (p *Processor) SubscribeToSharedDataProcedure(c *gin.Context, sdmSubscripti
 
 	udmClientAPI := p.Consumer().GetSDMClient("subscribeToSharedData")
 
+	// the binding of the subscription callback is kept with the subscription, so the notifications can
+	// be rerouted to an alternate consumer
+	ctx = consumer.WithSbiBinding(ctx, c.GetHeader(util.HeaderSbiBinding))
 	sdmSubscriptionResp, res, err := udmClientAPI.SubscriptionCreationForSharedDataApi.SubscribeToSharedData(
 		ctx, *sdmSubscription)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -734,6 +642,7 @@ func (p *Processor) SubscribeToSharedDataProcedure(c *gin.Context, sdmSubscripti
 			GetSDMUri() +
 			"//shared-data-subscriptions/" + sdmSubscriptionResp.SubscriptionId
 		c.Header("Location", reourceUri)
+		c.Header(util.HeaderSbiBinding, p.Context().BindingIndication())
 		c.JSON(http.StatusOK, sdmSubscriptionResp)
 	} else if res.StatusCode == http.StatusNotFound {
 		problemDetails := &models.ProblemDetails{
@@ -753,7 +662,9 @@ func (p *Processor) SubscribeToSharedDataProcedure(c *gin.Context, sdmSubscripti
 }
 
 func (p *Processor) SubscribeProcedure(c *gin.Context, sdmSubscription *models.SdmSubscription, supi string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "SubscribeProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -764,24 +675,18 @@ func (p *Processor) SubscribeProcedure(c *gin.Context, sdmSubscription *models.S
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
+	// the UDR sends the notifications of the subscription with the binding of the callback, so they can
+	// be rerouted to an alternate consumer
+	ctx = consumer.WithSbiBinding(ctx, c.GetHeader(util.HeaderSbiBinding))
 	sdmSubscriptionResp, res, err := clientAPI.SDMSubscriptionsCollectionApi.CreateSdmSubscriptions(
 		ctx, supi, *sdmSubscription)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			logger.SdmLog.Warnln(err)
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "USER_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -794,8 +699,11 @@ func (p *Processor) SubscribeProcedure(c *gin.Context, sdmSubscription *models.S
 		if udmUe == nil {
 			udmUe = p.Context().NewUdmUe(supi)
 		}
-		udmUe.CreateSubscriptiontoNotifChange(sdmSubscriptionResp.SubscriptionId, &sdmSubscriptionResp)
+		// the notifications to the callbackReference are sent with the binding of the subscriber
+		udmUe.CreateSubscriptiontoNotifChange(sdmSubscriptionResp.SubscriptionId, &sdmSubscriptionResp,
+			util.CallbackRoutingBinding(c.GetHeader(util.HeaderSbiBinding)))
 		c.Header("Location", udmUe.GetLocationURI2(udm_context.LocationUriSdmSubscription, supi))
+		c.Header(util.HeaderSbiBinding, p.Context().BindingIndication())
 		c.JSON(http.StatusCreated, sdmSubscriptionResp)
 	} else if res.StatusCode == http.StatusNotFound {
 		problemDetails := &models.ProblemDetails{
@@ -813,7 +721,9 @@ func (p *Processor) SubscribeProcedure(c *gin.Context, sdmSubscription *models.S
 }
 
 func (p *Processor) UnsubscribeForSharedDataProcedure(c *gin.Context, subscriptionID string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDM_SDM, models.NfType_UDM)
+	defer tracing.StartProcedure(c, "UnsubscribeForSharedDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDM_SDM, models.NfType_UDM)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -824,20 +734,10 @@ func (p *Processor) UnsubscribeForSharedDataProcedure(c *gin.Context, subscripti
 	res, err := udmClientAPI.SubscriptionDeletionForSharedDataApi.UnsubscribeForSharedData(
 		ctx, subscriptionID)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			logger.SdmLog.Warnln(err)
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -857,7 +757,9 @@ func (p *Processor) UnsubscribeForSharedDataProcedure(c *gin.Context, subscripti
 }
 
 func (p *Processor) UnsubscribeProcedure(c *gin.Context, supi string, subscriptionID string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "UnsubscribeProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -868,23 +770,14 @@ func (p *Processor) UnsubscribeProcedure(c *gin.Context, supi string, subscripti
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	res, err := clientAPI.SDMSubscriptionDocumentApi.RemovesdmSubscriptions(ctx, supi, subscriptionID)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			logger.SdmLog.Warnln(err)
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "USER_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -908,7 +801,9 @@ func (p *Processor) ModifyProcedure(c *gin.Context,
 	supi string,
 	subscriptionID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "ModifyProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -919,6 +814,7 @@ func (p *Processor) ModifyProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	sdmSubscription := models.SdmSubscription{}
 	body := Nudr_DataRepository.UpdatesdmsubscriptionsParamOpts{
@@ -928,19 +824,10 @@ func (p *Processor) ModifyProcedure(c *gin.Context,
 	res, err := clientAPI.SDMSubscriptionDocumentApi.Updatesdmsubscriptions(
 		ctx, supi, subscriptionID, &body)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "USER_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -967,7 +854,9 @@ func (p *Processor) ModifyForSharedDataProcedure(c *gin.Context,
 	supi string,
 	subscriptionID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "ModifyForSharedDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -978,6 +867,7 @@ func (p *Processor) ModifyForSharedDataProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	var sdmSubscription models.SdmSubscription
 	sdmSubs := models.SdmSubscription{}
@@ -988,19 +878,10 @@ func (p *Processor) ModifyForSharedDataProcedure(c *gin.Context,
 	res, err := clientAPI.SDMSubscriptionDocumentApi.Updatesdmsubscriptions(
 		ctx, supi, subscriptionID, &body)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "USER_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -1020,7 +901,9 @@ func (p *Processor) ModifyForSharedDataProcedure(c *gin.Context,
 }
 
 func (p *Processor) GetTraceDataProcedure(c *gin.Context, supi string, plmnID string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetTraceDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -1034,25 +917,17 @@ func (p *Processor) GetTraceDataProcedure(c *gin.Context, supi string, plmnID st
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	p.Context().CreateTraceDataforUe(supi, body)
 
 	traceDataRes, res, err := clientAPI.TraceDataDocumentApi.QueryTraceData(
 		ctx, supi, plmnID, &queryTraceDataParamOpts)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Warnln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Warnln(err)
-		} else {
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Warnln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "USER_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -1080,6 +955,8 @@ func (p *Processor) GetTraceDataProcedure(c *gin.Context, supi string, plmnID st
 }
 
 func (p *Processor) GetUeContextInSmfDataProcedure(c *gin.Context, supi string, supportedFeatures string) {
+	defer tracing.StartProcedure(c, "GetUeContextInSmfDataProcedure").End()
+
 	var body models.UeContextInSmfData
 	var ueContextInSmfData models.UeContextInSmfData
 	var pgwInfoArray []models.PgwInfo
@@ -1096,30 +973,20 @@ func (p *Processor) GetUeContextInSmfDataProcedure(c *gin.Context, supi string,
 	pduSessionMap := make(map[string]models.PduSession)
 	p.Context().CreateUeContextInSmfDataforUe(supi, body)
 
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
 
 	pdusess, res, err := clientAPI.SMFRegistrationsCollectionApi.QuerySmfRegList(
 		ctx, supi, &querySmfRegListParamOpts)
 	if err != nil {
-		if res == nil {
-			logger.SdmLog.Infoln(err)
-		} else if err.Error() != res.Status {
-			logger.SdmLog.Infoln(err)
-		} else {
-			logger.SdmLog.Infoln(err)
-			problemDetails := &models.ProblemDetails{
-				Status: int32(res.StatusCode),
-				Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-				Detail: err.Error(),
-			}
-
-			c.JSON(int(problemDetails.Status), problemDetails)
-			return
-		}
+		logger.SdmLog.Infoln(err)
+		problemDetails := util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
 	}
 	defer func() {
 		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
@@ -1161,6 +1028,93 @@ func (p *Processor) GetUeContextInSmfDataProcedure(c *gin.Context, supi string,
 	}
 }
 
+// GetUeContextInAmfDataProcedure returns the AMFs registered for the UE in the UDR (TS 29.503
+// 5.2.2.2.x). An AMF is left out if the UE failed its authentication in the serving network of the
+// AMF, as confirmed by the AUSF; the AMFs without authentication status are kept, e.g. after a restart.
+func (p *Processor) GetUeContextInAmfDataProcedure(c *gin.Context, supi string, supportedFeatures string) {
+	defer tracing.StartProcedure(c, "GetUeContextInAmfDataProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
+	if err != nil {
+		c.JSON(int(pd.Status), pd)
+		return
+	}
+	clientAPI, err := p.Consumer().CreateUDMClientToUDR(supi)
+	if err != nil {
+		problemDetails := openapi.ProblemDetailsSystemFailure(err.Error())
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	}
+	ctx = consumer.WithUdrIdentity(ctx, supi)
+
+	var amfInfos []udm_context.AmfInfo
+	amf3Gpp, res, err := clientAPI.AMF3GPPAccessRegistrationDocumentApi.QueryAmfContext3gpp(ctx, supi,
+		&Nudr_DataRepository.QueryAmfContext3gppParamOpts{SupportedFeatures: optional.NewString(supportedFeatures)})
+	if problemDetails := amfContextProblem(res, err, "QueryAmfContext3gpp"); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	} else if err == nil {
+		amfInfos = append(amfInfos, udm_context.AmfInfo{
+			AmfInstanceId: amf3Gpp.AmfInstanceId,
+			Guami:         amf3Gpp.Guami,
+			AccessType:    models.AccessType__3_GPP_ACCESS,
+		})
+	}
+	amfNon3Gpp, res, err := clientAPI.AMFNon3GPPAccessRegistrationDocumentApi.QueryAmfContextNon3gpp(ctx, supi,
+		&Nudr_DataRepository.QueryAmfContextNon3gppParamOpts{SupportedFeatures: optional.NewString(supportedFeatures)})
+	if problemDetails := amfContextProblem(res, err, "QueryAmfContextNon3gpp"); problemDetails != nil {
+		c.JSON(int(problemDetails.Status), problemDetails)
+		return
+	} else if err == nil {
+		amfInfos = append(amfInfos, udm_context.AmfInfo{
+			AmfInstanceId: amfNon3Gpp.AmfInstanceId,
+			Guami:         amfNon3Gpp.Guami,
+			AccessType:    models.AccessType_NON_3_GPP_ACCESS,
+		})
+	}
+
+	ueContextInAmfData := udm_context.UeContextInAmfData{}
+	udmUe, _ := p.Context().UdmUeFindBySupi(supi)
+	for _, amfInfo := range amfInfos {
+		if udmUe != nil && amfInfo.Guami != nil && amfInfo.Guami.PlmnId != nil {
+			authEvent, ok := udmUe.ServingNetworkAuthEvent(servingNetworkName(amfInfo.Guami.PlmnId))
+			if ok && !authEvent.Success {
+				logger.SdmLog.Infof("AMF[%s] of UE[%s] is left out: the authentication failed in its serving network",
+					amfInfo.AmfInstanceId, supi)
+				continue
+			}
+		}
+		ueContextInAmfData.AmfInfo = append(ueContextInAmfData.AmfInfo, amfInfo)
+	}
+	c.JSON(http.StatusOK, ueContextInAmfData)
+}
+
+// amfContextProblem returns the ProblemDetails of a failed query of an AMF registration in the UDR,
+// or nil if the query succeeded or the UE has no AMF registered in the access type. The response
+// body is closed.
+func amfContextProblem(res *http.Response, err error, operation string) *models.ProblemDetails {
+	if res != nil {
+		if rspCloseErr := res.Body.Close(); rspCloseErr != nil {
+			logger.SdmLog.Errorf("%s response body cannot close: %+v", operation, rspCloseErr)
+		}
+	}
+	if err == nil || (res != nil && res.StatusCode == http.StatusNotFound) {
+		return nil
+	}
+	logger.SdmLog.Errorf("%s error: %+v", operation, err)
+	return util.UdrProblemDetails(res, err, "DATA_NOT_FOUND")
+}
+
+// servingNetworkName returns the serving network name of the PLMN (TS 24.501 9.12.1), as in the
+// authentication events, e.g. "5G:mnc093.mcc208.3gppnetwork.org"
+func servingNetworkName(plmnId *models.PlmnId) string {
+	mnc := plmnId.Mnc
+	if len(mnc) == 2 {
+		mnc = "0" + mnc
+	}
+	return fmt.Sprintf("5G:mnc%s.mcc%s.3gppnetwork.org", mnc, plmnId.Mcc)
+}
+
 func (p *Processor) containDataSetName(dataSetNames []string, target string) bool {
 	for _, dataSetName := range dataSetNames {
 		if dataSetName == target {
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+)
+
+func TestGetUeContextInAmfDataProcedure(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	amf3Gpp := map[string]interface{}{
+		"amfInstanceId": "a1b2c3d4-0000-4000-8000-000000000001",
+		"guami": map[string]interface{}{
+			"plmnId": map[string]interface{}{"mcc": "208", "mnc": "93"},
+			"amfId":  "cafe00",
+		},
+		"ratType": "NR",
+	}
+	amfNon3Gpp := map[string]interface{}{
+		"amfInstanceId": "a1b2c3d4-0000-4000-8000-000000000002",
+		"guami": map[string]interface{}{
+			"plmnId": map[string]interface{}{"mcc": "001", "mnc": "001"},
+			"amfId":  "cafe01",
+		},
+		"ratType": "WLAN",
+	}
+
+	testCases := []struct {
+		name         string
+		authEvents   []models.AuthEvent
+		non3GppFound bool
+		amfInstances []string
+	}{
+		{
+			name:         "No Authentication Event",
+			non3GppFound: true,
+			amfInstances: []string{"a1b2c3d4-0000-4000-8000-000000000001", "a1b2c3d4-0000-4000-8000-000000000002"},
+		},
+		{
+			name: "Authentication Failed In Serving Network",
+			authEvents: []models.AuthEvent{
+				{Success: false, ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org"},
+				{Success: true, ServingNetworkName: "5G:mnc001.mcc001.3gppnetwork.org"},
+			},
+			non3GppFound: true,
+			amfInstances: []string{"a1b2c3d4-0000-4000-8000-000000000002"},
+		},
+		{
+			name: "Authentication Succeeded Again",
+			authEvents: []models.AuthEvent{
+				{Success: false, ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org"},
+				{Success: true, ServingNetworkName: "5G:mnc093.mcc208.3gppnetwork.org"},
+			},
+			amfInstances: []string{"a1b2c3d4-0000-4000-8000-000000000001"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newUeauTestProcessor(t)
+			udmUe := p.Context().NewUdmUe(ueauTestSupi)
+			for i := range tc.authEvents {
+				udmUe.StoreAuthEvent(&tc.authEvents[i])
+			}
+
+			gock.New(ueauTestUdr).
+				Get("/nudr-dr/v1/subscription-data/" + ueauTestSupi + "/context-data/amf-3gpp-access").
+				Reply(http.StatusOK).
+				JSON(amf3Gpp)
+			non3Gpp := gock.New(ueauTestUdr).
+				Get("/nudr-dr/v1/subscription-data/" + ueauTestSupi + "/context-data/amf-non-3gpp-access")
+			if tc.non3GppFound {
+				non3Gpp.Reply(http.StatusOK).JSON(amfNon3Gpp)
+			} else {
+				non3Gpp.Reply(http.StatusNotFound).JSON(map[string]interface{}{"status": 404, "cause": "DATA_NOT_FOUND"})
+			}
+
+			w := httptest.NewRecorder()
+			p.GetUeContextInAmfDataProcedure(newUeauTestContext(w), ueauTestSupi, "")
+			require.Equal(t, http.StatusOK, w.Code)
+			require.True(t, gock.IsDone())
+
+			var ueContextInAmfData udm_context.UeContextInAmfData
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &ueContextInAmfData))
+			var amfInstances []string
+			for _, amfInfo := range ueContextInAmfData.AmfInfo {
+				amfInstances = append(amfInstances, amfInfo.AmfInstanceId)
+			}
+			require.Equal(t, tc.amfInstances, amfInstances)
+		})
+	}
+}
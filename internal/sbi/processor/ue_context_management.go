@@ -1,6 +1,7 @@
 package processor
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 
@@ -12,11 +13,16 @@ import (
 	"github.com/free5gc/openapi/models"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/tracing"
+	"github.com/free5gc/udm/internal/util"
 )
 
 // ue_context_managemanet_service
 func (p *Processor) GetAmf3gppAccessProcedure(c *gin.Context, ueID string, supportedFeatures string) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetAmf3gppAccessProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -30,15 +36,12 @@ func (p *Processor) GetAmf3gppAccessProcedure(c *gin.Context, ueID string, suppo
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, ueID)
 
 	amf3GppAccessRegistration, resp, err := clientAPI.AMF3GPPAccessRegistrationDocumentApi.
 		QueryAmfContext3gpp(ctx, ueID, &queryAmfContext3gppParamOpts)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
+		problemDetails := util.UdrProblemDetails(resp, err, "CONTEXT_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -54,9 +57,12 @@ func (p *Processor) GetAmf3gppAccessProcedure(c *gin.Context, ueID string, suppo
 func (p *Processor) GetAmfNon3gppAccessProcedure(c *gin.Context, queryAmfContextNon3gppParamOpts Nudr_DataRepository.
 	QueryAmfContextNon3gppParamOpts, ueID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "GetAmfNon3gppAccessProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
+		return
 	}
 	clientAPI, err := p.Consumer().CreateUDMClientToUDR(ueID)
 	if err != nil {
@@ -64,15 +70,12 @@ func (p *Processor) GetAmfNon3gppAccessProcedure(c *gin.Context, queryAmfContext
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, ueID)
 
 	amfNon3GppAccessRegistration, resp, err := clientAPI.AMFNon3GPPAccessRegistrationDocumentApi.
 		QueryAmfContextNon3gpp(ctx, ueID, &queryAmfContextNon3gppParamOpts)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
+		problemDetails := util.UdrProblemDetails(resp, err, "CONTEXT_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -89,21 +92,29 @@ func (p *Processor) RegistrationAmf3gppAccessProcedure(c *gin.Context,
 	registerRequest models.Amf3GppAccessRegistration,
 	ueID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "RegistrationAmf3gppAccessProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
 	}
+
 	// TODO: EPS interworking with N26 is not supported yet in this stage
 	var oldAmf3GppAccessRegContext *models.Amf3GppAccessRegistration
+	var oldRoutingBinding string
 	var ue *udm_context.UdmUeContext
 
 	if p.Context().UdmAmf3gppRegContextExists(ueID) {
 		ue, _ = p.Context().UdmUeFindBySupi(ueID)
 		oldAmf3GppAccessRegContext = ue.Amf3GppAccessRegistration
+		oldRoutingBinding = ue.Amf3GppAccessRoutingBinding
 	}
 
 	p.Context().CreateAmf3gppRegContext(ueID, registerRequest)
+	if udmUe, ok := p.Context().UdmUeFindBySupi(ueID); ok {
+		udmUe.Amf3GppAccessRoutingBinding = util.CallbackRoutingBinding(c.GetHeader(util.HeaderSbiBinding))
+	}
 
 	clientAPI, err := p.Consumer().CreateUDMClientToUDR(ueID)
 	if err != nil {
@@ -111,6 +122,7 @@ func (p *Processor) RegistrationAmf3gppAccessProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, ueID)
 
 	var createAmfContext3gppParamOpts Nudr_DataRepository.CreateAmfContext3gppParamOpts
 	optInterface := optional.NewInterface(registerRequest)
@@ -119,11 +131,7 @@ func (p *Processor) RegistrationAmf3gppAccessProcedure(c *gin.Context,
 		ueID, &createAmfContext3gppParamOpts)
 	if err != nil {
 		logger.UecmLog.Errorln("CreateAmfContext3gpp error : ", err)
-		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
+		problemDetails := util.UdrProblemDetails(resp, err, "USER_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -133,6 +141,9 @@ func (p *Processor) RegistrationAmf3gppAccessProcedure(c *gin.Context,
 		}
 	}()
 
+	// the UE context is kept by this UDM, so the following requests of the AMF are bound to it
+	c.Header(util.HeaderSbiBinding, p.Context().BindingIndication())
+
 	// TS 23.502 4.2.2.2.2 14d: UDM initiate a Nudm_UECM_DeregistrationNotification to the old AMF
 	// corresponding to the same (e.g. 3GPP) access, if one exists
 	if oldAmf3GppAccessRegContext != nil {
@@ -149,15 +160,18 @@ func (p *Processor) RegistrationAmf3gppAccessProcedure(c *gin.Context,
 				AccessType:  models.AccessType__3_GPP_ACCESS,
 			}
 
-			go func() {
+			// the notification is sent after the response, so it does not inherit the cancellation of the request
+			traceCtx := tracing.WithSpan(context.Background(), c.Request.Context())
+			traceCtx = consumer.WithSbiRoutingBinding(traceCtx, oldRoutingBinding)
+			p.goNotify(func() {
 				logger.UecmLog.Infof("Send DeregNotify to old AMF GUAMI=%v", oldAmf3GppAccessRegContext.Guami)
-				pd := p.SendOnDeregistrationNotification(ueID,
+				pd := p.SendOnDeregistrationNotification(traceCtx, ueID,
 					oldAmf3GppAccessRegContext.DeregCallbackUri,
 					deregistData) // Deregistration Notify Triggered
 				if pd != nil {
 					logger.UecmLog.Errorf("RegistrationAmf3gppAccess: send DeregNotify fail %v", pd)
 				}
-			}()
+			})
 		}
 
 		c.JSON(http.StatusOK, registerRequest)
@@ -172,18 +186,26 @@ func (p *Processor) RegisterAmfNon3gppAccessProcedure(c *gin.Context,
 	registerRequest models.AmfNon3GppAccessRegistration,
 	ueID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "RegisterAmfNon3gppAccessProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
 	}
+
 	var oldAmfNon3GppAccessRegContext *models.AmfNon3GppAccessRegistration
+	var oldRoutingBinding string
 	if p.Context().UdmAmfNon3gppRegContextExists(ueID) {
 		ue, _ := p.Context().UdmUeFindBySupi(ueID)
 		oldAmfNon3GppAccessRegContext = ue.AmfNon3GppAccessRegistration
+		oldRoutingBinding = ue.AmfNon3GppAccessRoutingBinding
 	}
 
 	p.Context().CreateAmfNon3gppRegContext(ueID, registerRequest)
+	if udmUe, ok := p.Context().UdmUeFindBySupi(ueID); ok {
+		udmUe.AmfNon3GppAccessRoutingBinding = util.CallbackRoutingBinding(c.GetHeader(util.HeaderSbiBinding))
+	}
 
 	clientAPI, err := p.Consumer().CreateUDMClientToUDR(ueID)
 	if err != nil {
@@ -191,6 +213,7 @@ func (p *Processor) RegisterAmfNon3gppAccessProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, ueID)
 
 	var createAmfContextNon3gppParamOpts Nudr_DataRepository.CreateAmfContextNon3gppParamOpts
 	optInterface := optional.NewInterface(registerRequest)
@@ -199,12 +222,7 @@ func (p *Processor) RegisterAmfNon3gppAccessProcedure(c *gin.Context,
 	resp, err := clientAPI.AMFNon3GPPAccessRegistrationDocumentApi.CreateAmfContextNon3gpp(
 		ctx, ueID, &createAmfContextNon3gppParamOpts)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
-
+		problemDetails := util.UdrProblemDetails(resp, err, "USER_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -214,6 +232,8 @@ func (p *Processor) RegisterAmfNon3gppAccessProcedure(c *gin.Context,
 		}
 	}()
 
+	c.Header(util.HeaderSbiBinding, p.Context().BindingIndication())
+
 	// TS 23.502 4.2.2.2.2 14d: UDM initiate a Nudm_UECM_DeregistrationNotification to the old AMF
 	// corresponding to the same (e.g. 3GPP) access, if one exists
 	if oldAmfNon3GppAccessRegContext != nil {
@@ -221,8 +241,8 @@ func (p *Processor) RegisterAmfNon3gppAccessProcedure(c *gin.Context,
 			DeregReason: models.DeregistrationReason_UE_INITIAL_REGISTRATION,
 			AccessType:  models.AccessType_NON_3_GPP_ACCESS,
 		}
-		p.SendOnDeregistrationNotification(ueID, oldAmfNon3GppAccessRegContext.DeregCallbackUri,
-			deregistData) // Deregistration Notify Triggered
+		p.SendOnDeregistrationNotification(consumer.WithSbiRoutingBinding(c.Request.Context(), oldRoutingBinding),
+			ueID, oldAmfNon3GppAccessRegContext.DeregCallbackUri, deregistData) // Deregistration Notify Triggered
 
 		return
 	} else {
@@ -236,7 +256,9 @@ func (p *Processor) UpdateAmf3gppAccessProcedure(c *gin.Context,
 	request models.Amf3GppAccessRegistrationModification,
 	ueID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "UpdateAmf3gppAccessProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -313,15 +335,12 @@ func (p *Processor) UpdateAmf3gppAccessProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, ueID)
 
 	resp, err := clientAPI.AMF3GPPAccessRegistrationDocumentApi.AmfContext3gpp(ctx, ueID,
 		patchItemReqArray)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
+		problemDetails := util.UdrProblemDetails(resp, err, "CONTEXT_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -344,7 +363,9 @@ func (p *Processor) UpdateAmfNon3gppAccessProcedure(c *gin.Context,
 	request models.AmfNon3GppAccessRegistrationModification,
 	ueID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "UpdateAmfNon3gppAccessProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -421,15 +442,12 @@ func (p *Processor) UpdateAmfNon3gppAccessProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, ueID)
 
 	resp, err := clientAPI.AMFNon3GPPAccessRegistrationDocumentApi.AmfContextNon3gpp(ctx,
 		ueID, patchItemReqArray)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
+		problemDetails := util.UdrProblemDetails(resp, err, "CONTEXT_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -446,7 +464,9 @@ func (p *Processor) DeregistrationSmfRegistrationsProcedure(c *gin.Context,
 	ueID string,
 	pduSessionID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "DeregistrationSmfRegistrationsProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -457,14 +477,11 @@ func (p *Processor) DeregistrationSmfRegistrationsProcedure(c *gin.Context,
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, ueID)
 
 	resp, err := clientAPI.SMFRegistrationDocumentApi.DeleteSmfContext(ctx, ueID, pduSessionID)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
+		problemDetails := util.UdrProblemDetails(resp, err, "CONTEXT_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -483,7 +500,9 @@ func (p *Processor) RegistrationSmfRegistrationsProcedure(
 	ueID string,
 	pduSessionID string,
 ) {
-	ctx, pd, err := p.Context().GetTokenCtx(models.ServiceName_NUDR_DR, models.NfType_UDR)
+	defer tracing.StartProcedure(c, "RegistrationSmfRegistrationsProcedure").End()
+
+	ctx, pd, err := p.getTokenCtx(c.Request.Context(), models.ServiceName_NUDR_DR, models.NfType_UDR)
 	if err != nil {
 		c.JSON(int(pd.Status), pd)
 		return
@@ -510,15 +529,12 @@ func (p *Processor) RegistrationSmfRegistrationsProcedure(
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
+	ctx = consumer.WithUdrIdentity(ctx, ueID)
 
 	resp, err := clientAPI.SMFRegistrationDocumentApi.CreateSmfContextNon3gpp(ctx, ueID,
 		pduID32, &createSmfContextNon3gppParamOpts)
 	if err != nil {
-		problemDetails := &models.ProblemDetails{
-			Status: int32(resp.StatusCode),
-			Cause:  err.(openapi.GenericOpenAPIError).Model().(models.ProblemDetails).Cause,
-			Detail: err.Error(),
-		}
+		problemDetails := util.UdrProblemDetails(resp, err, "USER_NOT_FOUND")
 		c.JSON(int(problemDetails.Status), problemDetails)
 		return
 	}
@@ -528,6 +544,7 @@ func (p *Processor) RegistrationSmfRegistrationsProcedure(
 		}
 	}()
 
+	c.Header(util.HeaderSbiBinding, p.Context().BindingIndication())
 	if contextExisted {
 		c.Status(http.StatusNoContent)
 	} else {
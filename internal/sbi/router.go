@@ -1,7 +1,14 @@
 package sbi
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/udm/internal/metrics"
+	"github.com/free5gc/udm/internal/tracing"
 )
 
 // Route is the information for every URI.
@@ -36,3 +43,60 @@ func AddService(group *gin.RouterGroup, routes []Route) {
 		}
 	}
 }
+
+// routeOperations maps the method and path pattern of the routes to their name
+func routeOperations(routes []Route) map[string]string {
+	operations := make(map[string]string, len(routes))
+	for _, route := range routes {
+		operations[route.Method+" "+route.Pattern] = route.Name
+	}
+	return operations
+}
+
+// routeOperation returns the name of the Route matched by the request, or the path pattern for the
+// other handlers of the group
+func routeOperation(c *gin.Context, group *gin.RouterGroup, operations map[string]string) string {
+	fullPath := c.FullPath()
+	if fullPath == "" {
+		return "unknown"
+	}
+	pattern := strings.TrimPrefix(fullPath, group.BasePath())
+	if pattern == "" {
+		pattern = "/"
+	}
+	if name, ok := operations[c.Request.Method+" "+pattern]; ok {
+		return name
+	}
+	return pattern
+}
+
+// metricsMiddleware counts the requests of the group and observes their latency per operation. The
+// operation is the name of the matched Route, or the path pattern for the other handlers.
+func metricsMiddleware(group *gin.RouterGroup, service string, routes []Route) gin.HandlerFunc {
+	operations := routeOperations(routes)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		operation := routeOperation(c, group, operations)
+		metrics.SbiRequestsTotal.WithLabelValues(service, operation, c.Request.Method,
+			strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.SbiRequestDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+	}
+}
+
+// tracingMiddleware handles the requests of the group in a span named after the service and the
+// operation, e.g. "nudm-ueau GenerateAuthData", which continues the trace of the traceparent header
+func tracingMiddleware(group *gin.RouterGroup, service string, routes []Route) gin.HandlerFunc {
+	operations := routeOperations(routes)
+
+	return func(c *gin.Context) {
+		ctx, span := tracing.StartServer(c.Request, service+" "+routeOperation(c, group, operations))
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+		tracing.SetStatusCode(span, c.Writer.Status())
+	}
+}
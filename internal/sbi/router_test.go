@@ -0,0 +1,106 @@
+package sbi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/free5gc/udm/internal/metrics"
+	"github.com/free5gc/udm/internal/tracing"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	router := gin.New()
+	group := router.Group("/nudm-test/v1")
+	routes := []Route{
+		{
+			"GetTestData",
+			"GET",
+			"/:supi/test-data",
+			func(c *gin.Context) { c.Status(http.StatusNoContent) },
+		},
+	}
+	group.Use(metricsMiddleware(group, "nudm-test", routes))
+	AddService(group, routes)
+
+	for _, path := range []string{"/nudm-test/v1/imsi-208930000000001/test-data", "/nudm-test/v1/unknown"} {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		router.ServeHTTP(w, req)
+	}
+
+	require.Equal(t, 1.0, testutil.ToFloat64(
+		metrics.SbiRequestsTotal.WithLabelValues("nudm-test", "GetTestData", "GET", "204")))
+	// the requests not routed to the group are not counted
+	require.Equal(t, 1, testutil.CollectAndCount(metrics.SbiRequestsTotal))
+}
+
+func TestTracingMiddleware(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	otel.SetTracerProvider(provider)
+
+	// the UDR receives the trace context of the procedure
+	var udrTraceparent string
+	udr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		udrTraceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer udr.Close()
+	client := &http.Client{Transport: &http.Transport{}}
+	tracing.InstrumentClients(client)
+
+	router := gin.New()
+	group := router.Group("/nudm-test/v1")
+	routes := []Route{
+		{
+			"GetTestData",
+			"GET",
+			"/:supi/test-data",
+			func(c *gin.Context) {
+				defer tracing.StartProcedure(c, "GetTestDataProcedure").End()
+
+				req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet,
+					udr.URL+"/nudr-dr/v1/subscription-data/"+c.Param("supi"), nil)
+				require.NoError(t, err)
+				rsp, err := client.Do(req)
+				require.NoError(t, err)
+				require.NoError(t, rsp.Body.Close())
+				c.Status(http.StatusNoContent)
+			},
+		},
+	}
+	group.Use(tracingMiddleware(group, "nudm-test", routes))
+	AddService(group, routes)
+
+	w := httptest.NewRecorder()
+	req, err := http.NewRequest(http.MethodGet, "/nudm-test/v1/imsi-208930000000001/test-data", nil)
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	clientSpan, procedure, server := spans[0], spans[1], spans[2]
+	require.Equal(t, "GET nudr-dr", clientSpan.Name())
+	require.Equal(t, "GetTestDataProcedure", procedure.Name())
+	require.Equal(t, "nudm-test GetTestData", server.Name())
+
+	// the spans continue the trace of the requesting NF
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", server.Parent().SpanID().String())
+	require.Equal(t, server.SpanContext().SpanID(), procedure.Parent().SpanID())
+	require.Equal(t, procedure.SpanContext().SpanID(), clientSpan.Parent().SpanID())
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+clientSpan.SpanContext().SpanID().String()+"-01",
+		udrTraceparent)
+}
@@ -4,19 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strings"
 	"sync"
-	"time"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 
 	"github.com/free5gc/openapi/models"
-	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/metrics"
 	"github.com/free5gc/udm/internal/sbi/consumer"
 	"github.com/free5gc/udm/internal/sbi/processor"
+	"github.com/free5gc/udm/internal/tracing"
 	"github.com/free5gc/udm/internal/util"
 	"github.com/free5gc/udm/pkg/app"
 	"github.com/free5gc/udm/pkg/factory"
@@ -29,6 +32,8 @@ type ServerUdm interface {
 
 	Consumer() *consumer.Consumer
 	Processor() *processor.Processor
+	CancelContext() context.Context
+	ReloadConfig() error
 }
 
 type Server struct {
@@ -36,6 +41,8 @@ type Server struct {
 
 	httpServer *http.Server
 	router     *gin.Engine
+	// set when the shutdown starts, so the readiness probe fails before the listener closes
+	draining atomic.Bool
 }
 
 func NewServer(udm ServerUdm, tlsKeyLogPath string) (*Server, error) {
@@ -45,8 +52,9 @@ func NewServer(udm ServerUdm, tlsKeyLogPath string) (*Server, error) {
 	}
 
 	cfg := s.Config()
-	bindAddr := cfg.GetSbiBindingAddr()
-	logger.SBILog.Infof("Binding addr: [%s]", bindAddr)
+	bindAddrs := cfg.GetSbiBindingAddrs()
+	bindAddr := bindAddrs[0]
+	logger.SBILog.Infof("Binding addr: %v", bindAddrs)
 	var err error
 	if s.httpServer, err = httpwrapper.NewHttp2Server(bindAddr, tlsKeyLogPath, s.router); err != nil {
 		logger.InitLog.Errorf("Initialize HTTP server failed: %v", err)
@@ -54,24 +62,72 @@ func NewServer(udm ServerUdm, tlsKeyLogPath string) (*Server, error) {
 	}
 	s.httpServer.ErrorLog = log.New(logger.SBILog.WriterLevel(logrus.ErrorLevel), "HTTP2: ", 0)
 
+	if cfg.GetSbiScheme() == "https" {
+		sbiTls := cfg.GetSbiTls()
+		if sbiTls == nil {
+			return nil, fmt.Errorf("sbi tls is required by the https scheme")
+		}
+		reloader, reloaderErr := util.NewCertReloader(sbiTls.Pem, sbiTls.Key, sbiTls.Ca)
+		if reloaderErr != nil {
+			logger.InitLog.Errorf("Load SBI certificate failed: %v", reloaderErr)
+			return nil, reloaderErr
+		}
+		s.httpServer.TLSConfig = reloader.ServerTLSConfig(s.httpServer.TLSConfig)
+		if sbiTls.Ca != "" {
+			logger.InitLog.Infof("Client certificates are verified by CA [%s]", sbiTls.Ca)
+		}
+	}
+
 	return s, err
 }
 
+// Run starts the SBI server and registers the UDM to the NRF in the background, so the health
+// probes are served while the NRF is unreachable. The registration is traced as a child of the span
+// in traceCtx.
 func (s *Server) Run(traceCtx context.Context, wg *sync.WaitGroup) error {
 	logger.SBILog.Info("Starting server...")
 
-	var err error
-	_, s.Context().NfId, err = s.Consumer().RegisterNFInstance(context.Background())
-	if err != nil {
-		logger.InitLog.Errorf("UDM register to NRF Error[%s]", err.Error())
+	wg.Add(1)
+	go s.startServer(wg)
+
+	if s.Context().NrfDisabled {
+		logger.InitLog.Infof("NRF is disabled, skip the NF registration")
+		return nil
 	}
 
 	wg.Add(1)
-	go s.startServer(wg)
+	go s.startNfRegistration(traceCtx, wg)
 
 	return nil
 }
 
+// startNfRegistration registers the UDM to the NRF, retrying until it succeeds, then keeps the
+// registration with the heartbeat and subscribes to the NF status changes
+func (s *Server) startNfRegistration(traceCtx context.Context, wg *sync.WaitGroup) {
+	defer func() {
+		if p := recover(); p != nil {
+			// Print stack for panic to log. Fatalf() will let program exit.
+			logger.SBILog.Fatalf("panic: %v\n%s", p, string(debug.Stack()))
+		}
+		wg.Done()
+	}()
+
+	_, nfId, err := s.Consumer().RegisterNFInstance(tracing.WithSpan(s.CancelContext(), traceCtx))
+	if err != nil {
+		logger.InitLog.Errorf("UDM register to NRF Error[%s]", err.Error())
+		return
+	}
+	// the NRF only returns the NF instance ID when the profile is created (201), which is the NfId
+	// the profile is put with unless the NRF assigned another one
+	if nfId != "" && nfId != s.Context().NfId {
+		s.Context().NfId = nfId
+	}
+
+	wg.Add(2)
+	go s.startNfHeartbeat(wg)
+	go s.startNfStatusSubscriptions(wg)
+}
+
 func (s *Server) startServer(wg *sync.WaitGroup) {
 	defer func() {
 		if p := recover(); p != nil {
@@ -89,12 +145,13 @@ func (s *Server) startServer(wg *sync.WaitGroup) {
 	var err error
 	cfg := s.Config()
 	scheme := cfg.GetSbiScheme()
-	if scheme == "http" {
+	if addrs := cfg.GetSbiBindingAddrs(); len(addrs) > 1 {
+		err = s.serveDualStack(scheme, addrs)
+	} else if scheme == "http" {
 		err = s.httpServer.ListenAndServe()
 	} else if scheme == "https" {
-		err = s.httpServer.ListenAndServeTLS(
-			cfg.GetCertPemPath(),
-			cfg.GetCertKeyPath())
+		// the certificates are served by the TLSConfig, which reloads them
+		err = s.httpServer.ListenAndServeTLS("", "")
 	} else {
 		err = fmt.Errorf("No support this scheme[%s]", scheme)
 	}
@@ -105,16 +162,96 @@ func (s *Server) startServer(wg *sync.WaitGroup) {
 	logger.SBILog.Infof("SBI server (listen on %s) stopped", s.httpServer.Addr)
 }
 
+// serveDualStack serves on both the IPv4 and the IPv6 binding address. The IPv6 listener is IPv6
+// only, so both can bind the same port.
+func (s *Server) serveDualStack(scheme string, addrs []string) error {
+	if scheme != "http" && scheme != "https" {
+		return fmt.Errorf("No support this scheme[%s]", scheme)
+	}
+
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		network := "tcp4"
+		if host, _, err := net.SplitHostPort(addr); err == nil && strings.Contains(host, ":") {
+			network = "tcp6"
+		}
+		listener, err := net.Listen(network, addr)
+		if err != nil {
+			for _, l := range listeners {
+				if closeErr := l.Close(); closeErr != nil {
+					logger.SBILog.Warnf("Close listener %s error: %v", l.Addr(), closeErr)
+				}
+			}
+			return err
+		}
+		logger.SBILog.Infof("SBI server listens on %s", listener.Addr())
+		listeners = append(listeners, listener)
+	}
+
+	errCh := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			if scheme == "https" {
+				errCh <- s.httpServer.ServeTLS(listener, "", "")
+			} else {
+				errCh <- s.httpServer.Serve(listener)
+			}
+		}(listener)
+	}
+
+	var serveErr error
+	for range listeners {
+		if err := <-errCh; err != http.ErrServerClosed && serveErr == nil {
+			// stop the other listeners as well
+			serveErr = err
+			if closeErr := s.httpServer.Close(); closeErr != nil {
+				logger.SBILog.Warnf("Close SBI server error: %v", closeErr)
+			}
+		}
+	}
+	if serveErr == nil {
+		return http.ErrServerClosed
+	}
+	return serveErr
+}
+
+func (s *Server) startNfHeartbeat(wg *sync.WaitGroup) {
+	defer func() {
+		if p := recover(); p != nil {
+			// Print stack for panic to log. Fatalf() will let program exit.
+			logger.SBILog.Fatalf("panic: %v\n%s", p, string(debug.Stack()))
+		}
+		wg.Done()
+	}()
+
+	s.Consumer().HeartbeatNFInstance(s.CancelContext())
+}
+
+func (s *Server) startNfStatusSubscriptions(wg *sync.WaitGroup) {
+	defer func() {
+		if p := recover(); p != nil {
+			// Print stack for panic to log. Fatalf() will let program exit.
+			logger.SBILog.Fatalf("panic: %v\n%s", p, string(debug.Stack()))
+		}
+		wg.Done()
+	}()
+
+	s.Consumer().RunNFStatusSubscriptions(s.CancelContext())
+}
+
+// Drain marks the UDM not ready, so the orchestrator stops sending traffic before the shutdown
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
 func (s *Server) Shutdown() {
 	s.shutdownHttpServer()
 }
 
 func (s *Server) Stop() {
-	const defaultShutdownTimeout time.Duration = 2 * time.Second
-
 	if s.httpServer != nil {
 		logger.SBILog.Infof("Stop SBI server (listen on %s)", s.httpServer.Addr)
-		toCtx, cancel := context.WithTimeout(context.Background(), defaultShutdownTimeout)
+		toCtx, cancel := context.WithTimeout(context.Background(), s.Config().GetShutdown().ServerTimeout)
 		defer cancel()
 		if err := s.httpServer.Shutdown(toCtx); err != nil {
 			logger.SBILog.Errorf("Could not close SBI server: %#v", err)
@@ -123,13 +260,11 @@ func (s *Server) Stop() {
 }
 
 func (s *Server) shutdownHttpServer() {
-	const shutdownTimeout time.Duration = 2 * time.Second
-
 	if s.httpServer == nil {
 		return
 	}
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.Config().GetShutdown().ServerTimeout)
 	defer cancel()
 
 	err := s.httpServer.Shutdown(shutdownCtx)
@@ -138,30 +273,80 @@ func (s *Server) shutdownHttpServer() {
 	}
 }
 
+// peerNfTypeCheck authorizes the NF types of the client certificates allowed to the service
+func (s *Server) peerNfTypeCheck(serviceName models.ServiceName) gin.HandlerFunc {
+	var allowedNfTypes []string
+	if sbiTls := s.Config().GetSbiTls(); sbiTls != nil {
+		allowedNfTypes = sbiTls.AllowedNfTypes[string(serviceName)]
+	}
+	return util.NewPeerNfTypeCheck(serviceName, allowedNfTypes).Check
+}
+
+// nrfPeerCheck allows only the NRF to send the NF status notifications if the client certificates are
+// verified
+func (s *Server) nrfPeerCheck() gin.HandlerFunc {
+	var allowedNfTypes []string
+	if sbiTls := s.Config().GetSbiTls(); sbiTls != nil && sbiTls.Ca != "" {
+		allowedNfTypes = []string{string(models.NfType_NRF)}
+	}
+	return util.NewPeerNfTypeCheck(models.ServiceName_NNRF_NFM, allowedNfTypes).Check
+}
+
+// authorizationCheck validates the access tokens of the requests to the service
+func (s *Server) authorizationCheck(serviceName models.ServiceName) gin.HandlerFunc {
+	routerAuthorizationCheck := util.NewRouterAuthorizationCheck(serviceName)
+	return func(c *gin.Context) {
+		routerAuthorizationCheck.Check(c, s.Context())
+	}
+}
+
 func newRouter(s *Server) *gin.Engine {
 	router := logger_util.NewGinWithLogrus(logger.GinLog)
+	sbiHeaderCheck := util.NewSbiHeaderCheck(s.Context)
+	overloadControl := util.NewOverloadControl(s.Config().GetOverload(), s.Context, metrics.UdrLatency)
+
+	// Health, without OAuth
+	udmHealthRoutes := s.getHealthRoutes()
+	udmHealthGroup := s.router.Group("")
+	AddService(udmHealthGroup, udmHealthRoutes)
 
 	// EE
 	udmEERoutes := s.getEventExposureRoutes()
 	udmEEGroup := s.router.Group(factory.UdmEeResUriPrefix)
-	routerAuthorizationCheck := util.NewRouterAuthorizationCheck(models.ServiceName_NUDM_EE)
-	udmEEGroup.Use(func(c *gin.Context) {
-		routerAuthorizationCheck.Check(c, s.Context())
-	})
+	udmEEGroup.Use(metricsMiddleware(udmEEGroup, string(models.ServiceName_NUDM_EE), udmEERoutes))
+	udmEEGroup.Use(tracingMiddleware(udmEEGroup, string(models.ServiceName_NUDM_EE), udmEERoutes))
+	udmEEGroup.Use(sbiHeaderCheck.Check)
+	udmEEGroup.Use(s.peerNfTypeCheck(models.ServiceName_NUDM_EE))
+	udmEEGroup.Use(s.authorizationCheck(models.ServiceName_NUDM_EE))
+	udmEEGroup.Use(overloadControl.Limit(models.ServiceName_NUDM_EE))
 	AddService(udmEEGroup, udmEERoutes)
 
-	// Callback
+	// Callback, without OAuth: the notifications of the UDR and the NRF carry no access token
 	udmCallBackRoutes := s.getHttpCallBackRoutes()
 	udmCallNackGroup := s.router.Group("")
+	udmCallNackGroup.Use(metricsMiddleware(udmCallNackGroup, "callback", udmCallBackRoutes))
+	udmCallNackGroup.Use(tracingMiddleware(udmCallNackGroup, "callback", udmCallBackRoutes))
+	udmCallNackGroup.Use(sbiHeaderCheck.Check)
 	AddService(udmCallNackGroup, udmCallBackRoutes)
 
+	// NRF callback, without OAuth: only the NRF may send them if the client certificates are verified
+	udmNrfCallBackRoutes := s.getNrfCallBackRoutes()
+	udmNrfCallBackGroup := s.router.Group("")
+	udmNrfCallBackGroup.Use(metricsMiddleware(udmNrfCallBackGroup, "callback", udmNrfCallBackRoutes))
+	udmNrfCallBackGroup.Use(tracingMiddleware(udmNrfCallBackGroup, "callback", udmNrfCallBackRoutes))
+	udmNrfCallBackGroup.Use(sbiHeaderCheck.Check)
+	udmNrfCallBackGroup.Use(s.nrfPeerCheck())
+	AddService(udmNrfCallBackGroup, udmNrfCallBackRoutes)
+
 	// UEAU
 	udmUEAURoutes := s.getUEAuthenticationRoutes()
 	udmUEAUGroup := s.router.Group(factory.UdmUeauResUriPrefix)
-	routerAuthorizationCheck = util.NewRouterAuthorizationCheck(models.ServiceName_NUDM_UEAU)
-	udmUEAUGroup.Use(func(c *gin.Context) {
-		routerAuthorizationCheck.Check(c, udm_context.GetSelf())
-	})
+	udmUEAUGroup.Use(metricsMiddleware(udmUEAUGroup, string(models.ServiceName_NUDM_UEAU), udmUEAURoutes))
+	udmUEAUGroup.Use(tracingMiddleware(udmUEAUGroup, string(models.ServiceName_NUDM_UEAU), udmUEAURoutes))
+	udmUEAUGroup.Use(sbiHeaderCheck.Check)
+	udmUEAUGroup.Use(s.peerNfTypeCheck(models.ServiceName_NUDM_UEAU))
+	udmUEAUGroup.Use(s.authorizationCheck(models.ServiceName_NUDM_UEAU))
+	udmUEAUGroup.Use(overloadControl.Limit(models.ServiceName_NUDM_UEAU))
 	AddService(udmUEAUGroup, udmUEAURoutes)
 
 	genAuthDataPath := "/:supi/security-information/generate-auth-data"
@@ -170,19 +355,23 @@ func newRouter(s *Server) *gin.Engine {
 	// UECM
 	udmUECMRoutes := s.getUEContextManagementRoutes()
 	udmUECMGroup := s.router.Group(factory.UdmUecmResUriPrefix)
-	routerAuthorizationCheck = util.NewRouterAuthorizationCheck(models.ServiceName_NUDM_UECM)
-	udmUECMGroup.Use(func(c *gin.Context) {
-		routerAuthorizationCheck.Check(c, udm_context.GetSelf())
-	})
+	udmUECMGroup.Use(metricsMiddleware(udmUECMGroup, string(models.ServiceName_NUDM_UECM), udmUECMRoutes))
+	udmUECMGroup.Use(tracingMiddleware(udmUECMGroup, string(models.ServiceName_NUDM_UECM), udmUECMRoutes))
+	udmUECMGroup.Use(sbiHeaderCheck.Check)
+	udmUECMGroup.Use(s.peerNfTypeCheck(models.ServiceName_NUDM_UECM))
+	udmUECMGroup.Use(s.authorizationCheck(models.ServiceName_NUDM_UECM))
+	udmUECMGroup.Use(overloadControl.Limit(models.ServiceName_NUDM_UECM))
 	AddService(udmUECMGroup, udmUECMRoutes)
 
 	// SDM
 	udmSDMRoutes := s.getSubscriberDataManagementRoutes()
 	udmSDMGroup := s.router.Group(factory.UdmSdmResUriPrefix)
-	routerAuthorizationCheck = util.NewRouterAuthorizationCheck(models.ServiceName_NUDM_SDM)
-	udmSDMGroup.Use(func(c *gin.Context) {
-		routerAuthorizationCheck.Check(c, udm_context.GetSelf())
-	})
+	udmSDMGroup.Use(metricsMiddleware(udmSDMGroup, string(models.ServiceName_NUDM_SDM), udmSDMRoutes))
+	udmSDMGroup.Use(tracingMiddleware(udmSDMGroup, string(models.ServiceName_NUDM_SDM), udmSDMRoutes))
+	udmSDMGroup.Use(sbiHeaderCheck.Check)
+	udmSDMGroup.Use(s.peerNfTypeCheck(models.ServiceName_NUDM_SDM))
+	udmSDMGroup.Use(s.authorizationCheck(models.ServiceName_NUDM_SDM))
+	udmSDMGroup.Use(overloadControl.Limit(models.ServiceName_NUDM_SDM))
 	AddService(udmSDMGroup, udmSDMRoutes)
 
 	oneLayerPath := "/:supi"
@@ -197,11 +386,22 @@ func newRouter(s *Server) *gin.Engine {
 	// PP
 	udmPPRoutes := s.getParameterProvisionRoutes()
 	udmPPGroup := s.router.Group(factory.UdmPpResUriPrefix)
-	routerAuthorizationCheck = util.NewRouterAuthorizationCheck(models.ServiceName_NUDM_PP)
-	udmPPGroup.Use(func(c *gin.Context) {
-		routerAuthorizationCheck.Check(c, udm_context.GetSelf())
-	})
+	udmPPGroup.Use(metricsMiddleware(udmPPGroup, string(models.ServiceName_NUDM_PP), udmPPRoutes))
+	udmPPGroup.Use(tracingMiddleware(udmPPGroup, string(models.ServiceName_NUDM_PP), udmPPRoutes))
+	udmPPGroup.Use(sbiHeaderCheck.Check)
+	udmPPGroup.Use(s.peerNfTypeCheck(models.ServiceName_NUDM_PP))
+	udmPPGroup.Use(s.authorizationCheck(models.ServiceName_NUDM_PP))
+	udmPPGroup.Use(overloadControl.Limit(models.ServiceName_NUDM_PP))
 	AddService(udmPPGroup, udmPPRoutes)
 
+	// Management, only served if a token is configured
+	if token := s.Config().GetManagementToken(); token != "" {
+		udmMgmtRoutes := s.getManagementRoutes()
+		udmMgmtGroup := s.router.Group(factory.UdmMgmtResUriPrefix)
+		managementAuthorizationCheck := util.NewManagementAuthorizationCheck(token)
+		udmMgmtGroup.Use(managementAuthorizationCheck.Check)
+		AddService(udmMgmtGroup, udmMgmtRoutes)
+	}
+
 	return router
 }
@@ -0,0 +1,144 @@
+package sbi
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/internal/sbi/processor"
+	"github.com/free5gc/udm/pkg/app"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+// routerTestUdm serves the router without consumer and processor, so the requests under test must
+// not reach the procedures
+type routerTestUdm struct {
+	*app.MockApp
+}
+
+func (u *routerTestUdm) Consumer() *consumer.Consumer    { return nil }
+func (u *routerTestUdm) Processor() *processor.Processor { return nil }
+func (u *routerTestUdm) CancelContext() context.Context  { return context.Background() }
+func (u *routerTestUdm) ReloadConfig() error             { return nil }
+
+func TestRouterAuthorization(t *testing.T) {
+	nrfKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pubKeyBytes, err := x509.MarshalPKIXPublicKey(&nrfKey.PublicKey)
+	require.NoError(t, err)
+	nrfCertPem := filepath.Join(t.TempDir(), "nrf.pem")
+	require.NoError(t, os.WriteFile(nrfCertPem, pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: pubKeyBytes,
+	}), 0o600))
+	accessToken := func(scope string) string {
+		token, signErr := jwt.NewWithClaims(jwt.SigningMethodRS512, jwt.MapClaims{
+			"aud":   "UDM",
+			"scope": scope,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}).SignedString(nrfKey)
+		require.NoError(t, signErr)
+		return "Bearer " + token
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockApp := app.NewMockApp(ctrl)
+	mockApp.EXPECT().Context().AnyTimes().Return(&udm_context.UDMContext{
+		NfId:           "54804518-4191-46b3-955c-ac631f953ed8",
+		NrfCertPem:     nrfCertPem,
+		OAuth2Required: true,
+	})
+	mockApp.EXPECT().Config().AnyTimes().Return(&factory.Config{})
+	s := &Server{
+		ServerUdm: &routerTestUdm{MockApp: mockApp},
+		router:    gin.New(),
+	}
+	router := s.router
+	newRouter(s)
+
+	testCases := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		statusCode    int
+	}{
+		{"EE", http.MethodGet, factory.UdmEeResUriPrefix + "/", accessToken("nudm-ee"), http.StatusOK},
+		{"EE Without Token", http.MethodGet, factory.UdmEeResUriPrefix + "/", "", http.StatusUnauthorized},
+		{"EE Other Service", http.MethodGet, factory.UdmEeResUriPrefix + "/", accessToken("nudm-pp"), http.StatusForbidden},
+		{
+			"EE Operation Scope", http.MethodPost, factory.UdmEeResUriPrefix + "/msisdn-886912345678/ee-subscriptions",
+			accessToken("nudm-ee nudm-ee:ee-subscriptions:read"), http.StatusForbidden,
+		},
+		{"UEAU", http.MethodGet, factory.UdmUeauResUriPrefix + "/", accessToken("nudm-ueau"), http.StatusOK},
+		{"UEAU Without Token", http.MethodGet, factory.UdmUeauResUriPrefix + "/", "", http.StatusUnauthorized},
+		{
+			"UEAU Other Service", http.MethodGet, factory.UdmUeauResUriPrefix + "/",
+			accessToken("nudm-sdm"), http.StatusForbidden,
+		},
+		{
+			"UEAU Operation Scope", http.MethodPost,
+			factory.UdmUeauResUriPrefix + "/suci-0-208-93-0-0-0-00001/security-information/generate-auth-data",
+			accessToken("nudm-ueau nudm-ueau:auth-events:write"), http.StatusForbidden,
+		},
+		{"UECM", http.MethodGet, factory.UdmUecmResUriPrefix + "/", accessToken("nudm-uecm"), http.StatusOK},
+		{"UECM Without Token", http.MethodGet, factory.UdmUecmResUriPrefix + "/", "", http.StatusUnauthorized},
+		{
+			"UECM Other Service", http.MethodGet, factory.UdmUecmResUriPrefix + "/",
+			accessToken("nudm-ee"), http.StatusForbidden,
+		},
+		{
+			"UECM Operation Scope", http.MethodPut,
+			factory.UdmUecmResUriPrefix + "/imsi-208930000000001/registrations/amf-3gpp-access",
+			accessToken("nudm-uecm nudm-uecm:amf-registration:read"), http.StatusForbidden,
+		},
+		{"SDM", http.MethodGet, factory.UdmSdmResUriPrefix + "/", accessToken("nudm-sdm"), http.StatusOK},
+		{"SDM Without Token", http.MethodGet, factory.UdmSdmResUriPrefix + "/", "", http.StatusUnauthorized},
+		{
+			"SDM Other Service", http.MethodGet, factory.UdmSdmResUriPrefix + "/",
+			accessToken("nudm-ueau"), http.StatusForbidden,
+		},
+		{
+			"SDM Operation Scope", http.MethodGet, factory.UdmSdmResUriPrefix + "/imsi-208930000000001/am-data",
+			accessToken("nudm-sdm nudm-sdm:nssai:read"), http.StatusForbidden,
+		},
+		{"PP", http.MethodGet, factory.UdmPpResUriPrefix + "/", accessToken("nudm-pp"), http.StatusOK},
+		{"PP Without Token", http.MethodGet, factory.UdmPpResUriPrefix + "/", "", http.StatusUnauthorized},
+		{"PP Other Service", http.MethodGet, factory.UdmPpResUriPrefix + "/", accessToken("nudm-uecm"), http.StatusForbidden},
+		{
+			"PP Operation Scope", http.MethodPatch, factory.UdmPpResUriPrefix + "/msisdn-886912345678/pp-data",
+			accessToken("nudm-pp nudm-pp:pp-data:read"), http.StatusForbidden,
+		},
+		// the notifications and the probes are served without access token
+		{"Callback", http.MethodGet, "/", "", http.StatusOK},
+		{"Health", http.MethodGet, "/healthz", "", http.StatusOK},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(tc.method, tc.path, nil)
+			require.NoError(t, err)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.statusCode, w.Code)
+		})
+	}
+}
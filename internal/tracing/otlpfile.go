@@ -0,0 +1,260 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// otlpFileExporter writes the spans in the OTLP file format: a line per export, holding the
+// ExportTraceServiceRequest in the OTLP/JSON encoding, i.e. hex IDs and integer enums.
+type otlpFileExporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+func newOtlpFileExporter(w io.Writer) *otlpFileExporter {
+	return &otlpFileExporter{encoder: json.NewEncoder(w)}
+}
+
+func (e *otlpFileExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	request := otlpTracesData{ResourceSpans: resourceSpans(spans)}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.encoder.Encode(request)
+}
+
+// Shutdown does nothing, the file is closed by the shutdown function of Init
+func (e *otlpFileExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+type otlpTracesData struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	SchemaUrl  string           `json:"schemaUrl,omitempty"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope     otlpScope  `json:"scope"`
+	Spans     []otlpSpan `json:"spans"`
+	SchemaUrl string     `json:"schemaUrl,omitempty"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId                string         `json:"traceId"`
+	SpanId                 string         `json:"spanId"`
+	TraceState             string         `json:"traceState,omitempty"`
+	ParentSpanId           string         `json:"parentSpanId,omitempty"`
+	Flags                  uint32         `json:"flags,omitempty"`
+	Name                   string         `json:"name"`
+	Kind                   int            `json:"kind"`
+	StartTimeUnixNano      uint64         `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano        uint64         `json:"endTimeUnixNano,string"`
+	Attributes             []otlpKeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int            `json:"droppedAttributesCount,omitempty"`
+	Events                 []otlpEvent    `json:"events,omitempty"`
+	DroppedEventsCount     int            `json:"droppedEventsCount,omitempty"`
+	Links                  []otlpLink     `json:"links,omitempty"`
+	DroppedLinksCount      int            `json:"droppedLinksCount,omitempty"`
+	Status                 otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano uint64         `json:"timeUnixNano,string"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpLink struct {
+	TraceId    string         `json:"traceId"`
+	SpanId     string         `json:"spanId"`
+	TraceState string         `json:"traceState,omitempty"`
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *int64          `json:"intValue,omitempty,string"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+// the status codes of OTLP, which differ from codes.Code
+const (
+	otlpStatusUnset = 0
+	otlpStatusOk    = 1
+	otlpStatusError = 2
+)
+
+// resourceSpans groups the spans by resource, then by instrumentation scope, in their order
+func resourceSpans(spans []sdktrace.ReadOnlySpan) []otlpResourceSpans {
+	var resources []*resource.Resource
+	var result []otlpResourceSpans
+	for _, span := range spans {
+		i := 0
+		for i < len(resources) && !resources[i].Equal(span.Resource()) {
+			i++
+		}
+		if i == len(resources) {
+			resources = append(resources, span.Resource())
+			result = append(result, otlpResourceSpans{
+				Resource:  otlpResource{Attributes: keyValues(span.Resource().Attributes())},
+				SchemaUrl: span.Resource().SchemaURL(),
+			})
+		}
+		result[i].ScopeSpans = appendScopeSpan(result[i].ScopeSpans, span.InstrumentationScope(), toOtlpSpan(span))
+	}
+	return result
+}
+
+func appendScopeSpan(scopeSpans []otlpScopeSpans, scope instrumentation.Scope, span otlpSpan) []otlpScopeSpans {
+	for i := range scopeSpans {
+		if scopeSpans[i].Scope.Name == scope.Name && scopeSpans[i].Scope.Version == scope.Version &&
+			scopeSpans[i].SchemaUrl == scope.SchemaURL {
+			scopeSpans[i].Spans = append(scopeSpans[i].Spans, span)
+			return scopeSpans
+		}
+	}
+	return append(scopeSpans, otlpScopeSpans{
+		Scope:     otlpScope{Name: scope.Name, Version: scope.Version},
+		Spans:     []otlpSpan{span},
+		SchemaUrl: scope.SchemaURL,
+	})
+}
+
+func toOtlpSpan(span sdktrace.ReadOnlySpan) otlpSpan {
+	spanContext := span.SpanContext()
+	otlp := otlpSpan{
+		TraceId:                spanContext.TraceID().String(),
+		SpanId:                 spanContext.SpanID().String(),
+		TraceState:             spanContext.TraceState().String(),
+		Flags:                  uint32(spanContext.TraceFlags()),
+		Name:                   span.Name(),
+		Kind:                   int(span.SpanKind()),
+		StartTimeUnixNano:      unixNano(span.StartTime().UnixNano()),
+		EndTimeUnixNano:        unixNano(span.EndTime().UnixNano()),
+		Attributes:             keyValues(span.Attributes()),
+		DroppedAttributesCount: span.DroppedAttributes(),
+		DroppedEventsCount:     span.DroppedEvents(),
+		DroppedLinksCount:      span.DroppedLinks(),
+		Status:                 otlpStatus{Message: span.Status().Description},
+	}
+	if span.Parent().HasSpanID() {
+		otlp.ParentSpanId = span.Parent().SpanID().String()
+	}
+	switch span.Status().Code {
+	case codes.Ok:
+		otlp.Status.Code = otlpStatusOk
+	case codes.Error:
+		otlp.Status.Code = otlpStatusError
+	default:
+		otlp.Status.Code = otlpStatusUnset
+	}
+	for _, event := range span.Events() {
+		otlp.Events = append(otlp.Events, otlpEvent{
+			TimeUnixNano: unixNano(event.Time.UnixNano()),
+			Name:         event.Name,
+			Attributes:   keyValues(event.Attributes),
+		})
+	}
+	for _, link := range span.Links() {
+		otlp.Links = append(otlp.Links, otlpLink{
+			TraceId:    link.SpanContext.TraceID().String(),
+			SpanId:     link.SpanContext.SpanID().String(),
+			TraceState: link.SpanContext.TraceState().String(),
+			Attributes: keyValues(link.Attributes),
+		})
+	}
+	return otlp
+}
+
+func unixNano(nano int64) uint64 {
+	if nano < 0 {
+		return 0
+	}
+	return uint64(nano)
+}
+
+func keyValues(attributes []attribute.KeyValue) []otlpKeyValue {
+	var keyValues []otlpKeyValue
+	for _, kv := range attributes {
+		keyValues = append(keyValues, otlpKeyValue{Key: string(kv.Key), Value: anyValue(kv.Value)})
+	}
+	return keyValues
+}
+
+func anyValue(v attribute.Value) otlpAnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpAnyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := v.AsInt64()
+		return otlpAnyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpAnyValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		return arrayValue(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return arrayValue(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return arrayValue(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return arrayValue(v.AsStringSlice(), attribute.StringValue)
+	default:
+		s := v.Emit()
+		return otlpAnyValue{StringValue: &s}
+	}
+}
+
+func arrayValue[T any](values []T, value func(T) attribute.Value) otlpAnyValue {
+	array := &otlpArrayValue{Values: []otlpAnyValue{}}
+	for _, v := range values {
+		array.Values = append(array.Values, anyValue(value(v)))
+	}
+	return otlpAnyValue{ArrayValue: array}
+}
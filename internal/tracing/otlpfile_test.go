@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestOtlpFileExporter(t *testing.T) {
+	var buf bytes.Buffer
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSyncer(newOtlpFileExporter(&buf)),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	tracer := provider.Tracer(tracerName)
+
+	ctx, parent := tracer.Start(context.Background(), "GenerateAuthDataProcedure")
+	_, child := tracer.Start(ctx, "GET /nudr-dr/v1/subscription-data/{ueId}/authentication-data",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.Int("http.response.status_code", 504),
+			attribute.StringSlice("udm.services", []string{"nudm-ueau"}),
+		))
+	child.SetStatus(codes.Error, "Gateway Timeout")
+	child.End()
+	parent.End()
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	// a line per export, the child span is exported first
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	var export map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &export))
+
+	resourceSpans := export["resourceSpans"].([]any)[0].(map[string]any)
+	require.Equal(t, []any{map[string]any{
+		"key":   "service.name",
+		"value": map[string]any{"stringValue": "udm"},
+	}}, resourceSpans["resource"].(map[string]any)["attributes"])
+	scopeSpans := resourceSpans["scopeSpans"].([]any)[0].(map[string]any)
+	require.Equal(t, map[string]any{"name": tracerName}, scopeSpans["scope"])
+
+	span := scopeSpans["spans"].([]any)[0].(map[string]any)
+	require.Equal(t, child.SpanContext().TraceID().String(), span["traceId"])
+	require.Equal(t, child.SpanContext().SpanID().String(), span["spanId"])
+	require.Equal(t, parent.SpanContext().SpanID().String(), span["parentSpanId"])
+	require.Equal(t, float64(trace.SpanKindClient), span["kind"])
+	require.IsType(t, "", span["startTimeUnixNano"])
+	require.Equal(t, []any{
+		map[string]any{"key": "http.response.status_code", "value": map[string]any{"intValue": "504"}},
+		map[string]any{"key": "udm.services", "value": map[string]any{"arrayValue": map[string]any{
+			"values": []any{map[string]any{"stringValue": "nudm-ueau"}},
+		}}},
+	}, span["attributes"])
+	require.Equal(t, map[string]any{"message": "Gateway Timeout", "code": float64(otlpStatusError)}, span["status"])
+}
@@ -0,0 +1,142 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+const (
+	tracerName  = "github.com/free5gc/udm"
+	serviceName = "udm"
+)
+
+// the W3C traceparent and tracestate headers are propagated, even if the spans are not exported
+var propagator = propagation.TraceContext{}
+
+func init() {
+	otel.SetTextMapPropagator(propagator)
+}
+
+// Init sets up the tracer provider exporting the spans as configured. The spans are not recorded if
+// cfg is nil. The returned function flushes the spans and stops the exporter.
+func Init(cfg *factory.Tracing, nfInstanceId string) (func(context.Context) error, error) {
+	if cfg == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	var err error
+	switch cfg.Exporter {
+	case factory.TracingExporterOtlpHttp:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	case factory.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case factory.TracingExporterFile:
+		file, err = os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+		if err == nil {
+			exporter = newOtlpFileExporter(file)
+		}
+	default:
+		err = fmt.Errorf("unsupported tracing exporter [%s]", cfg.Exporter)
+	}
+	if err != nil {
+		if file != nil {
+			err = closeWithError(file, err)
+		}
+		return nil, err
+	}
+
+	sampleRatio := cfg.SampleRatio
+	if sampleRatio == 0 {
+		sampleRatio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.instance.id", nfInstanceId),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = closeWithError(file, err)
+		}
+		return err
+	}, nil
+}
+
+func closeWithError(c io.Closer, err error) error {
+	if closeErr := c.Close(); err == nil {
+		return closeErr
+	}
+	return err
+}
+
+// Start starts a span as a child of the span in ctx
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, opts...)
+}
+
+// StartServer starts the span of an SBI request, as a child of the span in its traceparent header
+func StartServer(req *http.Request, name string) (context.Context, trace.Span) {
+	ctx := propagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	return Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.path", req.URL.Path),
+		))
+}
+
+// SetStatusCode records the HTTP status code of the response, the 5xx status codes are errors
+func SetStatusCode(span trace.Span, code int) {
+	span.SetAttributes(attribute.Int("http.response.status_code", code))
+	if code >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(code))
+	}
+}
+
+// StartProcedure starts the span of a procedure as a child of the span of the SBI request. The span
+// is carried by the request of c from then on, e.g.
+//
+//	defer tracing.StartProcedure(c, "GenerateAuthDataProcedure").End()
+func StartProcedure(c *gin.Context, name string) trace.Span {
+	if c.Request == nil {
+		_, span := Start(context.Background(), name)
+		return span
+	}
+	ctx, span := Start(c.Request.Context(), name)
+	c.Request = c.Request.WithContext(ctx)
+	return span
+}
+
+// WithSpan returns ctx carrying the span of parent, e.g. to propagate the trace context of a request
+// with the token context of GetTokenCtx. The cancellation of parent is not inherited.
+func WithSpan(ctx, parent context.Context) context.Context {
+	return trace.ContextWithSpan(ctx, trace.SpanFromContext(parent))
+}
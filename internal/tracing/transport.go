@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var instrumentOnce sync.Once
+
+// transport sends the requests of the openapi clients (UDR, NRF, notification callbacks) in a client
+// span, and propagates the trace context in the traceparent header
+type transport struct {
+	next http.RoundTripper
+}
+
+// InstrumentClients wraps the transports of the HTTP clients shared by the openapi clients, e.g.
+// openapi.GetHttpClient() and openapi.GetHttpsClient()
+func InstrumentClients(clients ...*http.Client) {
+	instrumentOnce.Do(func() {
+		for _, client := range clients {
+			next := client.Transport
+			if next == nil {
+				next = http.DefaultTransport
+			}
+			client.Transport = &transport{next: next}
+		}
+	})
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Start(req.Context(), clientSpanName(req),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.path", req.URL.Path),
+		))
+	defer span.End()
+
+	// a RoundTripper must not modify the request
+	req = req.Clone(ctx)
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	rsp, err := t.next.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return rsp, err
+	}
+	SetStatusCode(span, rsp.StatusCode)
+	return rsp, nil
+}
+
+// clientSpanName names the span after the service of the request, e.g. "GET nudr-dr" for
+// "/nudr-dr/v1/subscription-data/...". The callbacks are named after the method only.
+func clientSpanName(req *http.Request) string {
+	segments := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 3)
+	if len(segments) == 3 && strings.HasPrefix(segments[0], "n") && strings.HasPrefix(segments[1], "v") {
+		return req.Method + " " + segments[0]
+	}
+	return req.Method
+}
@@ -0,0 +1,181 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+// the number of consumers above which the idle ones are removed from a rate limiter
+const maxRateLimitBuckets = 10000
+
+// OverloadControl limits the rate of the requests of each consumer NF instance to the services, and
+// signals the load of the UDM to the consumers by the 3gpp-Sbi-Lci and 3gpp-Sbi-Oci headers
+// (TS 29.500 6.3 and 6.4). The load is the highest of the in-flight requests and the UDR latency
+// relative to their maximum.
+type OverloadControl struct {
+	config     *factory.Overload
+	getContext NFContextGetter
+	udrLatency func() time.Duration
+	inFlight   atomic.Int64
+}
+
+// NewOverloadControl returns the overload control of config, which is disabled if config is nil
+func NewOverloadControl(config *factory.Overload, getContext NFContextGetter,
+	udrLatency func() time.Duration,
+) *OverloadControl {
+	return &OverloadControl{
+		config:     config,
+		getContext: getContext,
+		udrLatency: udrLatency,
+	}
+}
+
+// Limit returns the middleware of the service, which has its own rate limit per consumer. It follows
+// the authentication of the consumers, i.e. the client certificate and access token checks.
+func (oc *OverloadControl) Limit(serviceName models.ServiceName) gin.HandlerFunc {
+	if oc.config == nil {
+		return func(*gin.Context) {}
+	}
+	var limiter *rateLimiter
+	if oc.config.RateLimit > 0 {
+		limiter = newRateLimiter(oc.config.RateLimit, oc.config.Burst)
+	}
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		oc.setHeaders(c, now)
+		// the requests of the unauthenticated consumers are not limited, as they cannot be told apart
+		if consumer := consumerId(c); limiter != nil && consumer != "" {
+			if ok, retryAfter := limiter.allow(consumer, now); !ok {
+				logger.UtilLog.Debugf("OverloadControl: rate limit of %s exceeded by [%s]", serviceName, consumer)
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				problemDetails := &models.ProblemDetails{
+					Title:  "Too many requests",
+					Status: http.StatusTooManyRequests,
+					Cause:  "NF_CONGESTION_RISK",
+					Detail: "the rate limit of " + string(serviceName) + " is exceeded",
+				}
+				c.AbortWithStatusJSON(int(problemDetails.Status), problemDetails)
+				return
+			}
+		}
+
+		oc.inFlight.Add(1)
+		defer oc.inFlight.Add(-1)
+		c.Next()
+	}
+}
+
+// LoadMetric returns the load of the UDM in percent
+func (oc *OverloadControl) LoadMetric() int {
+	load := oc.inFlight.Load() * 100 / int64(oc.config.MaxInFlight)
+	if latencyLoad := int64(oc.udrLatency() * 100 / oc.config.MaxUdrLatency); latencyLoad > load {
+		load = latencyLoad
+	}
+	return int(min(load, 100))
+}
+
+// overloadReduction returns the percentage of the traffic the consumers are asked to reduce, which
+// grows from 1% at the overload threshold, or 0 if the UDM is not overloaded
+func (oc *OverloadControl) overloadReduction(load int) int {
+	threshold := oc.config.OverloadThreshold
+	if load < threshold {
+		return 0
+	}
+	return max((load-threshold)*100/(101-threshold), 1)
+}
+
+// setHeaders sets the load control information of the response, and the overload control information
+// if the UDM is overloaded
+func (oc *OverloadControl) setHeaders(c *gin.Context, now time.Time) {
+	scope := "NF-Instance: " + oc.getContext().NfId
+	timestamp := `"` + now.UTC().Format(http.TimeFormat) + `"`
+
+	load := oc.LoadMetric()
+	c.Header(HeaderSbiLci, fmt.Sprintf("Timestamp: %s; Load-Metric: %d%%; %s", timestamp, load, scope))
+	if reduction := oc.overloadReduction(load); reduction > 0 {
+		c.Header(HeaderSbiOci, fmt.Sprintf("Timestamp: %s; Period-of-Validity: %ds; Overload-Reduction-Metric: %d%%; %s",
+			timestamp, int(oc.config.OciValidityPeriod.Seconds()), reduction, scope))
+	}
+}
+
+// consumerId identifies the consumer NF instance of the request by its client certificate, or by the
+// subject of its access token, or returns "" if the consumer is not authenticated. The User-Agent and
+// the address of the request are not used, as any client can set them to another consumer's.
+func consumerId(c *gin.Context) string {
+	if peerNf, ok := GetPeerNf(c); ok && peerNf.NfInstanceId != "" {
+		return peerNf.NfInstanceId
+	}
+	if consumer, ok := GetAuthorizedConsumer(c); ok {
+		return consumer
+	}
+	return ""
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter holds a token bucket per consumer, refilled at rate tokens per second up to burst
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		burst:   float64(max(burst, 1)),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token of the consumer, or returns the time until its next token if it has none
+func (l *rateLimiter) allow(consumer string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[consumer]
+	if !ok {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			l.prune(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[consumer] = bucket
+	} else {
+		bucket.tokens = l.refill(bucket, now)
+		bucket.last = now
+	}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *rateLimiter) refill(bucket *tokenBucket, now time.Time) float64 {
+	return math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+}
+
+// prune removes the buckets of the consumers idle long enough to be full again
+func (l *rateLimiter) prune(now time.Time) {
+	for consumer, bucket := range l.buckets {
+		if l.refill(bucket, now) >= l.burst {
+			delete(l.buckets, consumer)
+		}
+	}
+}
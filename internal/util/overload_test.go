@@ -0,0 +1,125 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/pkg/factory"
+)
+
+func TestOverloadControl(t *testing.T) {
+	udmContext := &udm_context.UDMContext{NfId: "54804518-4191-46b3-955c-ac631f953ed8"}
+	udrLatency := 100 * time.Millisecond
+	overloadControl := NewOverloadControl(&factory.Overload{
+		RateLimit:         1,
+		Burst:             2,
+		MaxInFlight:       10,
+		MaxUdrLatency:     time.Second,
+		OverloadThreshold: 80,
+		OciValidityPeriod: time.Minute,
+	}, func() *udm_context.UDMContext {
+		return udmContext
+	}, func() time.Duration {
+		return udrLatency
+	})
+
+	router := gin.New()
+	// the consumers are authenticated before the rate limit, here by the subject of their access token
+	router.Use(func(c *gin.Context) {
+		if consumer := c.GetHeader("Authorization"); consumer != "" {
+			c.Set(authorizedConsumerKey, consumer)
+		}
+	})
+	sdmGroup := router.Group(factory.UdmSdmResUriPrefix)
+	sdmGroup.Use(overloadControl.Limit(models.ServiceName_NUDM_SDM))
+	sdmGroup.GET("/:supi/am-data", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	uecmGroup := router.Group(factory.UdmUecmResUriPrefix)
+	uecmGroup.Use(overloadControl.Limit(models.ServiceName_NUDM_UECM))
+	uecmGroup.GET("/:ueId/registrations", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(path, consumer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", consumer)
+		rsp := httptest.NewRecorder()
+		router.ServeHTTP(rsp, req)
+		return rsp
+	}
+	amf := "9e0c1ee4-2b5e-4c2d-9b31-7b36e0d0c6a1"
+	otherAmf := "a4ab7e8c-5c4b-4e2d-8a8e-2f3b6b9e0d11"
+	amData := "/nudm-sdm/v1/imsi-208930000000001/am-data"
+
+	// the burst of the consumer is accepted, then it is limited
+	for i := 0; i < 2; i++ {
+		rsp := request(amData, amf)
+		require.Equal(t, http.StatusOK, rsp.Code)
+		require.Equal(t, "Load-Metric: 10%; NF-Instance: 54804518-4191-46b3-955c-ac631f953ed8",
+			rsp.Header().Get(HeaderSbiLci)[len(`Timestamp: "Mon, 19 Oct 2026 10:00:00 GMT"; `):])
+		require.Empty(t, rsp.Header().Get(HeaderSbiOci))
+	}
+	rsp := request(amData, amf)
+	require.Equal(t, http.StatusTooManyRequests, rsp.Code)
+	require.Equal(t, "1", rsp.Header().Get("Retry-After"))
+	require.Contains(t, rsp.Body.String(), "NF_CONGESTION_RISK")
+
+	// the other consumers and the other services have their own limits
+	require.Equal(t, http.StatusOK, request(amData, otherAmf).Code)
+	require.Equal(t, http.StatusOK, request("/nudm-uecm/v1/imsi-208930000000001/registrations", amf).Code)
+
+	// the unauthenticated requests are not limited, and cannot take the tokens of a consumer by its
+	// User-Agent
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/nudm-uecm/v1/imsi-208930000000001/registrations", nil)
+		req.Header.Set("User-Agent", "AMF-"+otherAmf)
+		rsp = httptest.NewRecorder()
+		router.ServeHTTP(rsp, req)
+		require.Equal(t, http.StatusOK, rsp.Code)
+	}
+	require.Equal(t, http.StatusOK, request("/nudm-uecm/v1/imsi-208930000000001/registrations", otherAmf).Code)
+
+	// the consumers are asked to reduce their traffic once the UDR latency overloads the UDM
+	udrLatency = 900 * time.Millisecond
+	rsp = request(amData, otherAmf)
+	require.Contains(t, rsp.Header().Get(HeaderSbiLci), "Load-Metric: 90%")
+	require.Contains(t, rsp.Header().Get(HeaderSbiOci),
+		"Period-of-Validity: 60s; Overload-Reduction-Metric: 47%; NF-Instance: 54804518-4191-46b3-955c-ac631f953ed8")
+
+	// the overload control is disabled without configuration
+	router = gin.New()
+	router.Use(NewOverloadControl(nil, nil, nil).Limit(models.ServiceName_NUDM_SDM))
+	router.GET(amData, func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	rsp = request(amData, amf)
+	require.Equal(t, http.StatusOK, rsp.Code)
+	require.Empty(t, rsp.Header().Get(HeaderSbiLci))
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, 1)
+	now := time.Now()
+
+	ok, _ := limiter.allow("amf", now)
+	require.True(t, ok)
+	ok, retryAfter := limiter.allow("amf", now)
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// the bucket is refilled at the rate
+	ok, _ = limiter.allow("amf", now.Add(500*time.Millisecond))
+	require.True(t, ok)
+
+	// the idle consumers are removed
+	limiter.prune(now.Add(time.Second))
+	require.Empty(t, limiter.buckets)
+}
@@ -0,0 +1,86 @@
+package util
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/openapi/models"
+	"github.com/free5gc/udm/internal/logger"
+)
+
+// the key of the peer NF in the gin context
+const peerNfKey = "peerNf"
+
+// PeerNf is the identity of the NF given by the URI SAN "<nftype>.<nfinstance>" of its client certificate
+type PeerNf struct {
+	NfType       models.NfType
+	NfInstanceId string
+}
+
+// PeerNfTypeCheck authorizes the requests to a service by the NF type of the client certificate
+type PeerNfTypeCheck struct {
+	serviceName    models.ServiceName
+	allowedNfTypes []string
+}
+
+func NewPeerNfTypeCheck(serviceName models.ServiceName, allowedNfTypes []string) *PeerNfTypeCheck {
+	return &PeerNfTypeCheck{
+		serviceName:    serviceName,
+		allowedNfTypes: allowedNfTypes,
+	}
+}
+
+func (pnc *PeerNfTypeCheck) Check(c *gin.Context) {
+	peerNf, ok := peerNfFromTLS(c.Request)
+	if ok {
+		c.Set(peerNfKey, peerNf)
+	}
+	if len(pnc.allowedNfTypes) == 0 {
+		return
+	}
+	if !ok {
+		logger.UtilLog.Debugf("PeerNfTypeCheck::Check Forbidden: no NF identity in client certificate")
+		c.JSON(http.StatusForbidden, gin.H{"error": "no NF identity in client certificate"})
+		c.Abort()
+		return
+	}
+	for _, nfType := range pnc.allowedNfTypes {
+		if strings.EqualFold(nfType, string(peerNf.NfType)) {
+			logger.UtilLog.Debugf("PeerNfTypeCheck::Check Authorized: %s[%s]", peerNf.NfType, peerNf.NfInstanceId)
+			return
+		}
+	}
+	logger.UtilLog.Debugf("PeerNfTypeCheck::Check Forbidden: %s[%s] to %s",
+		peerNf.NfType, peerNf.NfInstanceId, pnc.serviceName)
+	c.JSON(http.StatusForbidden, gin.H{"error": "NF type " + string(peerNf.NfType) + " is not allowed"})
+	c.Abort()
+}
+
+// peerNfFromTLS returns the NF identity of the verified client certificate of the request
+func peerNfFromTLS(req *http.Request) (*PeerNf, bool) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, false
+	}
+	for _, uri := range req.TLS.PeerCertificates[0].URIs {
+		nfType, nfInstanceId, ok := strings.Cut(uri.String(), ".")
+		if ok && nfType != "" && nfInstanceId != "" {
+			return &PeerNf{
+				NfType:       models.NfType(strings.ToUpper(nfType)),
+				NfInstanceId: nfInstanceId,
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// GetPeerNf returns the NF identity of the client certificate given by PeerNfTypeCheck
+func GetPeerNf(c *gin.Context) (*PeerNf, bool) {
+	value, ok := c.Get(peerNfKey)
+	if !ok {
+		return nil, false
+	}
+	peerNf, ok := value.(*PeerNf)
+	return peerNf, ok
+}
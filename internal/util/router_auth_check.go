@@ -1,7 +1,10 @@
 package util
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,6 +15,9 @@ import (
 
 type NFContextGetter func() *udm_context.UDMContext
 
+// the key of the NF instance ID of the consumer authorized by its access token in the gin context
+const authorizedConsumerKey = "authorizedConsumer"
+
 type RouterAuthorizationCheck struct {
 	serviceName models.ServiceName
 }
@@ -24,7 +30,14 @@ func NewRouterAuthorizationCheck(serviceName models.ServiceName) *RouterAuthoriz
 
 func (rac *RouterAuthorizationCheck) Check(c *gin.Context, udmContext udm_context.NFContext) {
 	token := c.Request.Header.Get("Authorization")
-	err := udmContext.AuthorizationCheck(token, rac.serviceName)
+	scope := operationScope(rac.serviceName, c.Request.Method, c.Request.URL.Path)
+	consumer, err := udmContext.AuthorizationCheck(token, rac.serviceName, scope)
+	if errors.Is(err, udm_context.ErrInsufficientScope) {
+		logger.UtilLog.Debugf("RouterAuthorizationCheck::Check Forbidden: %s", err.Error())
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		c.Abort()
+		return
+	}
 	if err != nil {
 		logger.UtilLog.Debugf("RouterAuthorizationCheck::Check Unauthorized: %s", err.Error())
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
@@ -33,4 +46,83 @@ func (rac *RouterAuthorizationCheck) Check(c *gin.Context, udmContext udm_contex
 	}
 
 	logger.UtilLog.Debugf("RouterAuthorizationCheck::Check Authorized")
+	if consumer != "" {
+		c.Set(authorizedConsumerKey, consumer)
+	}
+}
+
+// GetAuthorizedConsumer returns the NF instance ID of the consumer authorized by RouterAuthorizationCheck,
+// i.e. the subject of its access token
+func GetAuthorizedConsumer(c *gin.Context) (string, bool) {
+	consumer := c.GetString(authorizedConsumerKey)
+	return consumer, consumer != ""
+}
+
+// scopeResources names the resources of the operation scopes which differ from their path segment,
+// e.g. the AMF registrations of both accesses are granted by "nudm-uecm:amf-registration:write"
+var scopeResources = map[string]string{
+	"amf-3gpp-access":      "amf-registration",
+	"amf-non-3gpp-access":  "amf-registration",
+	"smf-registrations":    "smf-registration",
+	"smsf-3gpp-access":     "smsf-registration",
+	"smsf-non-3gpp-access": "smsf-registration",
+}
+
+// operationScope returns the resource and operation level scope of the request to the service, i.e.
+// "<service>:<resource>:<read|write>", or "" for the requests which are not to a resource. The resource
+// is the path segment following the UE identity, or the registration type of the UECM, and the
+// operation is read for GET.
+func operationScope(serviceName models.ServiceName, method, path string) string {
+	// the path starts with the apiRoot of the service, e.g. "/nudm-sdm/v2"
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) <= 2 {
+		return ""
+	}
+	segments = segments[2:]
+
+	var resource string
+	switch {
+	case segments[0] == "shared-data" || segments[0] == "shared-data-subscriptions":
+		resource = segments[0]
+	case len(segments) == 1:
+		// the data sets of the UE, e.g. GET /nudm-sdm/v2/{supi}
+		resource = "subscription-data"
+	case segments[1] == "registrations" && len(segments) > 2:
+		resource = segments[2]
+	default:
+		resource = segments[1]
+	}
+	if name, ok := scopeResources[resource]; ok {
+		resource = name
+	}
+
+	operation := "write"
+	if method == http.MethodGet {
+		operation = "read"
+	}
+	return string(serviceName) + ":" + resource + ":" + operation
+}
+
+// ManagementAuthorizationCheck checks the bearer token of the management API, which is configured
+// instead of issued by the NRF
+type ManagementAuthorizationCheck struct {
+	token string
+}
+
+func NewManagementAuthorizationCheck(token string) *ManagementAuthorizationCheck {
+	return &ManagementAuthorizationCheck{
+		token: token,
+	}
+}
+
+func (mac *ManagementAuthorizationCheck) Check(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(mac.token)) != 1 {
+		logger.UtilLog.Debugf("ManagementAuthorizationCheck::Check Unauthorized")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid management token"})
+		c.Abort()
+		return
+	}
+
+	logger.UtilLog.Debugf("ManagementAuthorizationCheck::Check Authorized")
 }
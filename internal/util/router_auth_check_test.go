@@ -7,13 +7,16 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
 
 	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
 )
 
 const (
-	Valid   = "valid"
-	Invalid = "invalid"
+	Valid     = "valid"
+	Invalid   = "invalid"
+	Forbidden = "forbidden"
 )
 
 type mockUDMContext struct{}
@@ -22,12 +25,17 @@ func newMockUDMContext() *mockUDMContext {
 	return &mockUDMContext{}
 }
 
-func (m *mockUDMContext) AuthorizationCheck(token string, serviceName models.ServiceName) error {
+func (m *mockUDMContext) AuthorizationCheck(token string, serviceName models.ServiceName,
+	operationScope string,
+) (string, error) {
 	if token == Valid {
-		return nil
+		return "9e0c1ee4-2b5e-4c2d-9b31-7b36e0d0c6a1", nil
+	}
+	if token == Forbidden {
+		return "", errors.Wrap(udm_context.ErrInsufficientScope, operationScope)
 	}
 
-	return errors.New("invalid token")
+	return "", errors.New("invalid token")
 }
 
 func TestRouterAuthorizationCheck_Check(t *testing.T) {
@@ -71,6 +79,15 @@ func TestRouterAuthorizationCheck_Check(t *testing.T) {
 				statusCode: http.StatusUnauthorized,
 			},
 		},
+		{
+			name: "Insufficient Scope",
+			args: Args{
+				token: Forbidden,
+			},
+			want: Want{
+				statusCode: http.StatusForbidden,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -88,6 +105,134 @@ func TestRouterAuthorizationCheck_Check(t *testing.T) {
 			if w.Code != tt.want.statusCode {
 				t.Errorf("StatusCode should be %d, but got %d", tt.want.statusCode, w.Code)
 			}
+			if _, ok := GetAuthorizedConsumer(c); ok != (tt.want.statusCode == http.StatusOK) {
+				t.Errorf("the consumer should be authorized only with a valid token")
+			}
+		})
+	}
+}
+
+func TestOperationScope(t *testing.T) {
+	tests := []struct {
+		serviceName models.ServiceName
+		method      string
+		path        string
+		scope       string
+	}{
+		{
+			serviceName: models.ServiceName_NUDM_SDM,
+			method:      "GET",
+			path:        "/nudm-sdm/v1/",
+			scope:       "",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_SDM,
+			method:      "GET",
+			path:        "/nudm-sdm/v1/imsi-208930000000001",
+			scope:       "nudm-sdm:subscription-data:read",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_SDM,
+			method:      "GET",
+			path:        "/nudm-sdm/v1/imsi-208930000000001/am-data",
+			scope:       "nudm-sdm:am-data:read",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_SDM,
+			method:      "PUT",
+			path:        "/nudm-sdm/v1/imsi-208930000000001/am-data/sor-ack",
+			scope:       "nudm-sdm:am-data:write",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_SDM,
+			method:      "GET",
+			path:        "/nudm-sdm/v1/shared-data",
+			scope:       "nudm-sdm:shared-data:read",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_SDM,
+			method:      "DELETE",
+			path:        "/nudm-sdm/v1/shared-data-subscriptions/1",
+			scope:       "nudm-sdm:shared-data-subscriptions:write",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_UECM,
+			method:      "PUT",
+			path:        "/nudm-uecm/v1/imsi-208930000000001/registrations/amf-non-3gpp-access",
+			scope:       "nudm-uecm:amf-registration:write",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_UECM,
+			method:      "DELETE",
+			path:        "/nudm-uecm/v1/imsi-208930000000001/registrations/smf-registrations/1",
+			scope:       "nudm-uecm:smf-registration:write",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_UEAU,
+			method:      "POST",
+			path:        "/nudm-ueau/v1/suci-0-208-93-0-0-0-00001/security-information/generate-auth-data",
+			scope:       "nudm-ueau:security-information:write",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_EE,
+			method:      "POST",
+			path:        "/nudm-ee/v1/msisdn-886912345678/ee-subscriptions",
+			scope:       "nudm-ee:ee-subscriptions:write",
+		},
+		{
+			serviceName: models.ServiceName_NUDM_PP,
+			method:      "PATCH",
+			path:        "/nudm-pp/v1/msisdn-886912345678/pp-data",
+			scope:       "nudm-pp:pp-data:write",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			require.Equal(t, tt.scope, operationScope(tt.serviceName, tt.method, tt.path))
+		})
+	}
+}
+
+func TestManagementAuthorizationCheck_Check(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		statusCode    int
+	}{
+		{
+			name:          "Valid Token",
+			authorization: "Bearer secret",
+			statusCode:    http.StatusOK,
+		},
+		{
+			name:          "Invalid Token",
+			authorization: "Bearer wrong",
+			statusCode:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Missing Bearer",
+			authorization: "secret",
+			statusCode:    http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			var err error
+			c.Request, err = http.NewRequest("POST", "/mgmt/reload", nil)
+			if err != nil {
+				t.Errorf("error on http request: %+v", err)
+			}
+			c.Request.Header.Set("Authorization", tt.authorization)
+
+			mac := NewManagementAuthorizationCheck("secret")
+			mac.Check(c)
+			if w.Code != tt.statusCode {
+				t.Errorf("StatusCode should be %d, but got %d", tt.statusCode, w.Code)
+			}
 		})
 	}
 }
@@ -0,0 +1,223 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/free5gc/openapi/models"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/logger"
+)
+
+// custom HTTP headers of the SBI (TS 29.500 5.2.3.3)
+const (
+	HeaderSbiTargetApiRoot   = "3gpp-Sbi-Target-apiRoot"
+	HeaderSbiCallback        = "3gpp-Sbi-Callback"
+	HeaderSbiRoutingBinding  = "3gpp-Sbi-Routing-Binding"
+	HeaderSbiBinding         = "3gpp-Sbi-Binding"
+	HeaderSbiDiscoveryPrefix = "3gpp-Sbi-Discovery-"
+	HeaderSbiOci             = "3gpp-Sbi-Oci"
+	HeaderSbiLci             = "3gpp-Sbi-Lci"
+)
+
+// the keys of the parsed headers in the gin context
+const (
+	sbiCallbackKey       = "sbiCallback"
+	sbiRoutingBindingKey = "sbiRoutingBinding"
+)
+
+// SbiCallback is the 3gpp-Sbi-Callback header of a notification, e.g.
+// "Nudr_DataRepository_DataChangeNotify; apiversion=1"
+type SbiCallback struct {
+	Type       string
+	ApiVersion string
+}
+
+// SbiBinding is the binding indication of the 3gpp-Sbi-Binding and 3gpp-Sbi-Routing-Binding headers,
+// e.g. "bl=nfset; nfset=set1.udrset.5gc.mnc012.mcc345"
+type SbiBinding struct {
+	// Level is the binding level, e.g. "nfinstance" or "nfset"
+	Level string
+	// Params are the other parameters, e.g. nfinst, nfset, nfservinst or scope
+	Params map[string]string
+}
+
+// ParseSbiCallback parses the value of the 3gpp-Sbi-Callback header
+func ParseSbiCallback(value string) (*SbiCallback, error) {
+	parts := strings.Split(value, ";")
+	callback := &SbiCallback{Type: strings.TrimSpace(parts[0])}
+	if callback.Type == "" || strings.Contains(callback.Type, "=") {
+		return nil, fmt.Errorf("missing callback type")
+	}
+	for _, part := range parts[1:] {
+		name, paramValue, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || name != "apiversion" || paramValue == "" {
+			return nil, fmt.Errorf("invalid parameter [%s]", strings.TrimSpace(part))
+		}
+		callback.ApiVersion = paramValue
+	}
+	return callback, nil
+}
+
+// ParseSbiBinding parses a binding indication: the parameters separated by ";", of which the binding
+// level "bl" is mandatory
+func ParseSbiBinding(value string) (*SbiBinding, error) {
+	binding := &SbiBinding{Params: make(map[string]string)}
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, paramValue, ok := strings.Cut(part, "=")
+		name, paramValue = strings.TrimSpace(name), strings.TrimSpace(paramValue)
+		if !ok || name == "" || paramValue == "" {
+			return nil, fmt.Errorf("invalid parameter [%s]", part)
+		}
+		if name == "bl" {
+			binding.Level = paramValue
+		} else {
+			binding.Params[name] = paramValue
+		}
+	}
+	if binding.Level == "" {
+		return nil, fmt.Errorf("missing binding level")
+	}
+	return binding, nil
+}
+
+// String formats the binding indication as a header value, with the parameters in a stable order
+func (b *SbiBinding) String() string {
+	names := make([]string, 0, len(b.Params))
+	for name := range b.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("bl=" + b.Level)
+	for _, name := range names {
+		sb.WriteString("; " + name + "=" + b.Params[name])
+	}
+	return sb.String()
+}
+
+// CallbackRoutingBinding returns the 3gpp-Sbi-Routing-Binding of the notifications sent to an NF
+// which has provided the 3gpp-Sbi-Binding header, so an alternate NF can be selected if it is not
+// reachable. It returns "" if the header is absent, malformed or does not apply to the callbacks.
+func CallbackRoutingBinding(header string) string {
+	if header == "" {
+		return ""
+	}
+	binding, err := ParseSbiBinding(header)
+	if err != nil {
+		logger.UtilLog.Warnf("Ignore invalid %s [%s]: %+v", HeaderSbiBinding, header, err)
+		return ""
+	}
+	if scope, ok := binding.Params["scope"]; ok {
+		if !strings.Contains(scope, "callback") {
+			return ""
+		}
+		delete(binding.Params, "scope")
+	}
+	return binding.String()
+}
+
+// SbiHeaderCheck parses the 3gpp-Sbi-Callback and 3gpp-Sbi-Routing-Binding headers of the requests
+// received through an SCP. The parsed headers are kept in the gin context, and a request with a
+// malformed header, or bound to another UDM, is rejected.
+type SbiHeaderCheck struct {
+	getContext NFContextGetter
+}
+
+func NewSbiHeaderCheck(getContext NFContextGetter) *SbiHeaderCheck {
+	return &SbiHeaderCheck{
+		getContext: getContext,
+	}
+}
+
+func (shc *SbiHeaderCheck) Check(c *gin.Context) {
+	if value := c.GetHeader(HeaderSbiCallback); value != "" {
+		callback, err := ParseSbiCallback(value)
+		if err != nil {
+			abortInvalidHeader(c, HeaderSbiCallback, err)
+			return
+		}
+		logger.UtilLog.Debugf("SbiHeaderCheck: callback type[%s]", callback.Type)
+		c.Set(sbiCallbackKey, callback)
+	}
+	if value := c.GetHeader(HeaderSbiRoutingBinding); value != "" {
+		binding, err := ParseSbiBinding(value)
+		if err != nil {
+			abortInvalidHeader(c, HeaderSbiRoutingBinding, err)
+			return
+		}
+		logger.UtilLog.Debugf("SbiHeaderCheck: routing binding level[%s]", binding.Level)
+		if !servesBinding(binding, shc.getContext()) {
+			logger.UtilLog.Infof("SbiHeaderCheck: request bound to another UDM [%s]", value)
+			problemDetails := &models.ProblemDetails{
+				Title:  "Misdirected request",
+				Status: http.StatusMisdirectedRequest,
+				Detail: "the request is bound to another UDM: " + value,
+			}
+			c.AbortWithStatusJSON(int(problemDetails.Status), problemDetails)
+			return
+		}
+		c.Set(sbiRoutingBindingKey, binding)
+	}
+}
+
+// servesBinding checks that the UDM is the NF instance of the binding, or belongs to its NF set. The
+// UDM binds the UE contexts to its instance, so a request reaches another UDM of the set only after
+// the reselection by the SCP, e.g. when the instance has failed; the UE context is then not found.
+func servesBinding(binding *SbiBinding, udmContext *udm_context.UDMContext) bool {
+	nfInstanceId, hasInstance := binding.Params["nfinst"]
+	nfSetId, hasSet := binding.Params["nfset"]
+	if hasInstance && nfInstanceId == udmContext.NfId {
+		return true
+	}
+	if hasSet && udmContext.NfSetId != "" && strings.EqualFold(nfSetId, udmContext.NfSetId) {
+		return true
+	}
+	// a binding to a service instance or set is resolved by the SCP
+	return !hasInstance && !hasSet
+}
+
+func abortInvalidHeader(c *gin.Context, header string, err error) {
+	logger.UtilLog.Debugf("SbiHeaderCheck: invalid %s: %+v", header, err)
+	problemDetails := &models.ProblemDetails{
+		Title:  "Malformed request syntax",
+		Status: http.StatusBadRequest,
+		Cause:  "INVALID_MSG_FORMAT",
+		InvalidParams: []models.InvalidParam{
+			{
+				Param:  header,
+				Reason: err.Error(),
+			},
+		},
+	}
+	c.AbortWithStatusJSON(int(problemDetails.Status), problemDetails)
+}
+
+// GetSbiCallback returns the 3gpp-Sbi-Callback header parsed by SbiHeaderCheck
+func GetSbiCallback(c *gin.Context) (*SbiCallback, bool) {
+	value, ok := c.Get(sbiCallbackKey)
+	if !ok {
+		return nil, false
+	}
+	callback, ok := value.(*SbiCallback)
+	return callback, ok
+}
+
+// GetSbiRoutingBinding returns the 3gpp-Sbi-Routing-Binding header parsed by SbiHeaderCheck
+func GetSbiRoutingBinding(c *gin.Context) (*SbiBinding, bool) {
+	value, ok := c.Get(sbiRoutingBindingKey)
+	if !ok {
+		return nil, false
+	}
+	binding, ok := value.(*SbiBinding)
+	return binding, ok
+}
@@ -0,0 +1,113 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	udm_context "github.com/free5gc/udm/internal/context"
+)
+
+func TestParseSbiBinding(t *testing.T) {
+	binding, err := ParseSbiBinding("bl=nfset; nfset=set1.udrset.5gc.mnc012.mcc345;scope=other-service")
+	require.NoError(t, err)
+	require.Equal(t, "nfset", binding.Level)
+	require.Equal(t, map[string]string{
+		"nfset": "set1.udrset.5gc.mnc012.mcc345",
+		"scope": "other-service",
+	}, binding.Params)
+
+	for _, value := range []string{"", "nfinst=54804518-4191-46b3-955c-ac631f953ed8", "bl=nfset; nfset", "bl="} {
+		_, err = ParseSbiBinding(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestCallbackRoutingBinding(t *testing.T) {
+	testCases := []struct {
+		name    string
+		binding string
+		expect  string
+	}{
+		{"No Binding", "", ""},
+		{"Malformed Binding", "nfinst=54804518-4191-46b3-955c-ac631f953ed8", ""},
+		{
+			"Binding Without Scope",
+			"bl=nfset; nfset=set1.amfset.5gc.mnc093.mcc208; nfinst=54804518-4191-46b3-955c-ac631f953ed8",
+			"bl=nfset; nfinst=54804518-4191-46b3-955c-ac631f953ed8; nfset=set1.amfset.5gc.mnc093.mcc208",
+		},
+		{
+			"Callback Scope",
+			"bl=nfinstance; nfinst=54804518-4191-46b3-955c-ac631f953ed8; scope=callback",
+			"bl=nfinstance; nfinst=54804518-4191-46b3-955c-ac631f953ed8",
+		},
+		{"Other Service Scope", "bl=nfset; nfset=set1.amfset.5gc.mnc093.mcc208; scope=other-service", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expect, CallbackRoutingBinding(tc.binding))
+		})
+	}
+}
+
+func TestParseSbiCallback(t *testing.T) {
+	callback, err := ParseSbiCallback("Nudr_DataRepository_DataChangeNotify; apiversion=1")
+	require.NoError(t, err)
+	require.Equal(t, &SbiCallback{Type: "Nudr_DataRepository_DataChangeNotify", ApiVersion: "1"}, callback)
+
+	callback, err = ParseSbiCallback("Nnrf_NFManagement_NFStatusNotify")
+	require.NoError(t, err)
+	require.Equal(t, "", callback.ApiVersion)
+
+	for _, value := range []string{" ", "apiversion=1", "Nudm_SDM_Notification; version=1"} {
+		_, err = ParseSbiCallback(value)
+		require.Error(t, err, value)
+	}
+}
+
+func TestSbiHeaderCheck(t *testing.T) {
+	udmContext := &udm_context.UDMContext{
+		NfId:    "54804518-4191-46b3-955c-ac631f953ed8",
+		NfSetId: "set1.udmset.5gc.mnc093.mcc208",
+	}
+	sbiHeaderCheck := NewSbiHeaderCheck(func() *udm_context.UDMContext { return udmContext })
+
+	router := gin.New()
+	router.Use(sbiHeaderCheck.Check)
+	router.POST("/nf-status-notify", func(c *gin.Context) {
+		callback, ok := GetSbiCallback(c)
+		require.True(t, ok)
+		require.Equal(t, "Nnrf_NFManagement_NFStatusNotify", callback.Type)
+		_, ok = GetSbiRoutingBinding(c)
+		require.True(t, ok)
+		c.Status(http.StatusNoContent)
+	})
+
+	testCases := []struct {
+		name           string
+		routingBinding string
+		statusCode     int
+	}{
+		{"Valid Headers", "bl=nfinstance; nfinst=54804518-4191-46b3-955c-ac631f953ed8", http.StatusNoContent},
+		{"Binding Indication", udmContext.BindingIndication(), http.StatusNoContent},
+		{"Same NF Set", "bl=nfset; nfset=set1.udmset.5gc.mnc093.mcc208", http.StatusNoContent},
+		{"Service Set", "bl=nfserviceset; nfserviceset=set2.sn-nudm-sdm.nfi-1", http.StatusNoContent},
+		{"Other NF Instance", "bl=nfinstance; nfinst=c2d4b0a6-3e2f-4d16-b0b4-58d1c7a2e9f0", http.StatusMisdirectedRequest},
+		{"Other NF Set", "bl=nfset; nfset=set2.udmset.5gc.mnc093.mcc208", http.StatusMisdirectedRequest},
+		{"Malformed Routing Binding", "nfinst=54804518-4191-46b3-955c-ac631f953ed8", http.StatusBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, err := http.NewRequest(http.MethodPost, "/nf-status-notify", nil)
+			require.NoError(t, err)
+			req.Header.Set(HeaderSbiCallback, "Nnrf_NFManagement_NFStatusNotify")
+			req.Header.Set(HeaderSbiRoutingBinding, tc.routingBinding)
+			router.ServeHTTP(w, req)
+			require.Equal(t, tc.statusCode, w.Code)
+		})
+	}
+}
@@ -2,6 +2,8 @@ package util
 
 import (
 	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/free5gc/openapi/models"
 )
@@ -22,8 +24,12 @@ func SearchNFServiceUri(nfProfile models.NfProfile, serviceName models.ServiceNa
 					point := (*service.IpEndPoints)[0]
 					if point.Ipv4Address != "" {
 						nfUri = getSbiUri(service.Scheme, point.Ipv4Address, point.Port)
+					} else if point.Ipv6Address != "" {
+						nfUri = getSbiUri(service.Scheme, point.Ipv6Address, point.Port)
 					} else if len(nfProfile.Ipv4Addresses) != 0 {
 						nfUri = getSbiUri(service.Scheme, nfProfile.Ipv4Addresses[0], point.Port)
+					} else if len(nfProfile.Ipv6Addresses) != 0 {
+						nfUri = getSbiUri(service.Scheme, nfProfile.Ipv6Addresses[0], point.Port)
 					}
 				}
 			}
@@ -36,15 +42,16 @@ func SearchNFServiceUri(nfProfile models.NfProfile, serviceName models.ServiceNa
 	return
 }
 
-func getSbiUri(scheme models.UriScheme, ipv4Address string, port int32) (uri string) {
+// getSbiUri formats the URI of the address, where an IPv6 address is bracketed
+func getSbiUri(scheme models.UriScheme, ipAddress string, port int32) (uri string) {
 	if port != 0 {
-		uri = fmt.Sprintf("%s://%s:%d", scheme, ipv4Address, port)
+		uri = fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(ipAddress, strconv.Itoa(int(port))))
 	} else {
 		switch scheme {
 		case models.UriScheme_HTTP:
-			uri = fmt.Sprintf("%s://%s:80", scheme, ipv4Address)
+			uri = fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(ipAddress, "80"))
 		case models.UriScheme_HTTPS:
-			uri = fmt.Sprintf("%s://%s:443", scheme, ipv4Address)
+			uri = fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(ipAddress, "443"))
 		}
 	}
 	return
@@ -0,0 +1,54 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi/models"
+)
+
+func TestSearchNFServiceUriIPv6(t *testing.T) {
+	testCases := []struct {
+		name     string
+		profile  models.NfProfile
+		expected string
+	}{
+		{
+			name: "IPv6 endpoint",
+			profile: models.NfProfile{
+				NfServices: &[]models.NfService{
+					{
+						ServiceName:     models.ServiceName_NUDR_DR,
+						NfServiceStatus: models.NfServiceStatus_REGISTERED,
+						Scheme:          models.UriScheme_HTTP,
+						IpEndPoints:     &[]models.IpEndPoint{{Ipv6Address: "2001:db8::4", Port: 8000}},
+					},
+				},
+			},
+			expected: "http://[2001:db8::4]:8000",
+		},
+		{
+			name: "IPv6 address of the profile",
+			profile: models.NfProfile{
+				Ipv6Addresses: []string{"2001:db8::4"},
+				NfServices: &[]models.NfService{
+					{
+						ServiceName:     models.ServiceName_NUDR_DR,
+						NfServiceStatus: models.NfServiceStatus_REGISTERED,
+						Scheme:          models.UriScheme_HTTPS,
+						IpEndPoints:     &[]models.IpEndPoint{{}},
+					},
+				},
+			},
+			expected: "https://[2001:db8::4]:443",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			uri := SearchNFServiceUri(tc.profile, models.ServiceName_NUDR_DR, models.NfServiceStatus_REGISTERED)
+			require.Equal(t, tc.expected, uri)
+		})
+	}
+}
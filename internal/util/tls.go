@@ -0,0 +1,178 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/free5gc/udm/internal/logger"
+)
+
+// CertReloader serves a certificate, and the CA bundle verifying the peer certificates if any, from
+// their files. The files are loaded again when they change, so the certificates are renewed without
+// restarting the UDM. A change which cannot be loaded is logged, and the previous files are kept.
+type CertReloader struct {
+	pemPath string
+	keyPath string
+	caPath  string
+
+	mu       sync.Mutex
+	modTimes [3]time.Time
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+}
+
+func NewCertReloader(pemPath, keyPath, caPath string) (*CertReloader, error) {
+	r := &CertReloader{
+		pemPath: pemPath,
+		keyPath: keyPath,
+		caPath:  caPath,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload loads the files again if any of them has changed since the last load
+func (r *CertReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var modTimes [3]time.Time
+	for i, path := range []string{r.pemPath, r.keyPath, r.caPath} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return r.keepPrevious(err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	if r.cert != nil && modTimes == r.modTimes {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.pemPath, r.keyPath)
+	if err != nil {
+		return r.keepPrevious(err)
+	}
+	var caPool *x509.CertPool
+	if r.caPath != "" {
+		ca, readErr := os.ReadFile(r.caPath)
+		if readErr != nil {
+			return r.keepPrevious(readErr)
+		}
+		caPool = x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(ca) {
+			return r.keepPrevious(fmt.Errorf("no certificate in CA bundle [%s]", r.caPath))
+		}
+	}
+	if r.cert != nil {
+		logger.UtilLog.Infof("Certificate [%s] reloaded", r.pemPath)
+	}
+	r.modTimes, r.cert, r.caPool = modTimes, &cert, caPool
+	return nil
+}
+
+func (r *CertReloader) keepPrevious(err error) error {
+	if r.cert == nil {
+		return err
+	}
+	logger.UtilLog.Warnf("Reload certificate [%s] error, keep the previous one: %+v", r.pemPath, err)
+	return nil
+}
+
+func (r *CertReloader) current() (*tls.Certificate, *x509.CertPool, error) {
+	if err := r.reload(); err != nil {
+		return nil, nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, r.caPool, nil
+}
+
+// GetCertificate serves the certificate of the server
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cert, _, err := r.current()
+	return cert, err
+}
+
+// GetClientCertificate serves the certificate of the client
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cert, _, err := r.current()
+	return cert, err
+}
+
+// VerifyClientCertificate verifies the client certificate against the CA bundle. It is used instead of
+// ClientCAs, which cannot be changed once the server is started.
+func (r *CertReloader) VerifyClientCertificate(cs tls.ConnectionState) error {
+	_, caPool, err := r.current()
+	if err != nil {
+		return err
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("no client certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         caPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err
+}
+
+// ServerTLSConfig returns the TLS config of the server based on config, e.g. with the key log writer.
+// The client certificates are required and verified if the CA bundle is set.
+func (r *CertReloader) ServerTLSConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	config.GetCertificate = r.GetCertificate
+	if r.caPath != "" {
+		config.ClientAuth = tls.RequireAnyClientCert
+		config.VerifyConnection = r.VerifyClientCertificate
+	}
+	return config
+}
+
+// PresentClientCertificate makes the HTTPS client present the certificate to the servers requesting
+// a client certificate. It is set on the transport of the client, e.g. openapi.GetHttpsClient(),
+// before the transport is wrapped.
+func PresentClientCertificate(client *http.Client, pemPath, keyPath string) error {
+	reloader, err := NewCertReloader(pemPath, keyPath, "")
+	if err != nil {
+		return err
+	}
+	var config **tls.Config
+	switch transport := client.Transport.(type) {
+	case *http2.Transport:
+		config = &transport.TLSClientConfig
+	case *http.Transport:
+		config = &transport.TLSClientConfig
+	default:
+		return fmt.Errorf("unsupported HTTP client transport %T", client.Transport)
+	}
+	if *config == nil {
+		*config = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	(*config).GetClientCertificate = reloader.GetClientCertificate
+	return nil
+}
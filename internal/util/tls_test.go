@@ -0,0 +1,163 @@
+package util
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+
+	"github.com/free5gc/openapi/models"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	pemPath string
+	keyPath string
+}
+
+// newTestCert issues a certificate signed by issuer, or a self-signed CA if issuer is nil
+func newTestCert(t *testing.T, dir, name string, issuer *testCert, uriSan string) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+	}
+	if uriSan != "" {
+		uri, parseErr := url.Parse(uriSan)
+		require.NoError(t, parseErr)
+		template.URIs = []*url.URL{uri}
+	}
+	parent, parentKey := template, key
+	if issuer == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		parent, parentKey = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	c := &testCert{
+		cert:    cert,
+		key:     key,
+		pemPath: filepath.Join(dir, name+".pem"),
+		keyPath: filepath.Join(dir, name+".key"),
+	}
+	require.NoError(t, os.WriteFile(c.pemPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(c.keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}),
+		0o600))
+	return c
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil, "")
+	udm := newTestCert(t, dir, "udm", ca, "")
+
+	reloader, err := NewCertReloader(udm.pemPath, udm.keyPath, ca.pemPath)
+	require.NoError(t, err)
+	cert, err := reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, udm.cert.Raw, cert.Certificate[0])
+
+	// the renewed certificate is served once its files change
+	renewed := newTestCert(t, dir, "udm", ca, "")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(renewed.pemPath, later, later))
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, renewed.cert.Raw, cert.Certificate[0])
+
+	// a broken certificate is not served
+	require.NoError(t, os.WriteFile(renewed.pemPath, []byte("broken"), 0o600))
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(renewed.pemPath, later, later))
+	cert, err = reloader.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, renewed.cert.Raw, cert.Certificate[0])
+
+	_, err = NewCertReloader(renewed.pemPath, renewed.keyPath, "")
+	require.Error(t, err)
+}
+
+func TestMutualTls(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, dir, "ca", nil, "")
+	otherCa := newTestCert(t, dir, "other-ca", nil, "")
+	udm := newTestCert(t, dir, "udm", ca, "")
+	ausf := newTestCert(t, dir, "ausf", ca, "ausf.9e0c1ee4-2b5e-4c2d-9b31-7b36e0d0c6a1")
+	amf := newTestCert(t, dir, "amf", ca, "amf.54804518-4191-46b3-955c-ac631f953ed8")
+	untrusted := newTestCert(t, dir, "untrusted", otherCa, "ausf.9e0c1ee4-2b5e-4c2d-9b31-7b36e0d0c6a1")
+
+	router := gin.New()
+	router.Use(NewPeerNfTypeCheck(models.ServiceName_NUDM_UEAU, []string{"AUSF"}).Check)
+	router.POST("/nudm-ueau/v1/:supi/auth-events", func(c *gin.Context) {
+		peerNf, ok := GetPeerNf(c)
+		require.True(t, ok)
+		require.Equal(t, models.NfType_AUSF, peerNf.NfType)
+		require.Equal(t, "9e0c1ee4-2b5e-4c2d-9b31-7b36e0d0c6a1", peerNf.NfInstanceId)
+		c.Status(http.StatusCreated)
+	})
+	reloader, err := NewCertReloader(udm.pemPath, udm.keyPath, ca.pemPath)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(router)
+	server.EnableHTTP2 = true
+	server.TLS = reloader.ServerTLSConfig(nil)
+	server.StartTLS()
+	defer server.Close()
+
+	testCases := []struct {
+		name       string
+		client     *testCert
+		statusCode int
+	}{
+		{"Allowed NF Type", ausf, http.StatusCreated},
+		{"Other NF Type", amf, http.StatusForbidden},
+		{"Untrusted Client Certificate", untrusted, 0},
+		{"No Client Certificate", nil, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the client of the openapi
+			client := &http.Client{Transport: &http2.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec G402
+			}}
+			if tc.client != nil {
+				require.NoError(t, PresentClientCertificate(client, tc.client.pemPath, tc.client.keyPath))
+			}
+			rsp, err := client.Post(server.URL+"/nudm-ueau/v1/imsi-208930000000001/auth-events", "", nil)
+			if tc.statusCode == 0 {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, rsp.Body.Close())
+			require.Equal(t, tc.statusCode, rsp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+)
+
+// UdrProblemDetails translates the error of a request to the UDR into the ProblemDetails of the
+// response of the UDM:
+//   - without response, e.g. on a timeout, 504 UPSTREAM_SERVER_ERROR, or 500 SYSTEM_FAILURE if the
+//     request was not sent, e.g. without UDR
+//   - on a 404, notFoundCause, e.g. USER_NOT_FOUND, DATA_NOT_FOUND or CONTEXT_NOT_FOUND
+//   - on a successful response which cannot be decoded, 500 SYSTEM_FAILURE
+//   - on the other errors, the status and the cause of the UDR
+func UdrProblemDetails(rsp *http.Response, err error, notFoundCause string) *models.ProblemDetails {
+	if rsp == nil {
+		if !isNetworkError(err) {
+			return ProblemDetailsSystemFailure(errorDetail(err))
+		}
+		return &models.ProblemDetails{
+			Title:  "Upstream server error",
+			Status: http.StatusGatewayTimeout,
+			Cause:  "UPSTREAM_SERVER_ERROR",
+			Detail: "no response from the UDR: " + errorDetail(err),
+		}
+	}
+
+	udrProblem := udrProblemDetails(err)
+	detail := udrProblem.Detail
+	if detail == "" {
+		detail = errorDetail(err)
+	}
+	switch {
+	case rsp.StatusCode == http.StatusNotFound:
+		return &models.ProblemDetails{
+			Title:  "Not found",
+			Status: http.StatusNotFound,
+			Cause:  notFoundCause,
+			Detail: detail,
+		}
+	case rsp.StatusCode < http.StatusBadRequest:
+		return ProblemDetailsSystemFailure("invalid response of the UDR: " + detail)
+	}
+
+	problemDetails := &models.ProblemDetails{
+		Title:         udrProblem.Title,
+		Status:        int32(rsp.StatusCode),
+		Cause:         udrProblem.Cause,
+		Detail:        detail,
+		InvalidParams: udrProblem.InvalidParams,
+	}
+	if problemDetails.Cause == "" && rsp.StatusCode >= http.StatusInternalServerError {
+		problemDetails.Cause = "SYSTEM_FAILURE"
+	}
+	return problemDetails
+}
+
+// udrProblemDetails returns the ProblemDetails sent by the UDR, which is empty if the response has none
+func udrProblemDetails(err error) models.ProblemDetails {
+	var apiError openapi.GenericOpenAPIError
+	if !errors.As(err, &apiError) {
+		return models.ProblemDetails{}
+	}
+	switch model := apiError.Model().(type) {
+	case models.ProblemDetails:
+		return model
+	case *models.ProblemDetails:
+		if model != nil {
+			return *model
+		}
+	}
+	return models.ProblemDetails{}
+}
+
+// isNetworkError reports whether the request failed in the transport, e.g. on a timeout or a refused
+// connection, rather than before being sent
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled)
+}
+
+func errorDetail(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
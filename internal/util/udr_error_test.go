@@ -0,0 +1,103 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi"
+	"github.com/free5gc/openapi/models"
+)
+
+func TestUdrProblemDetails(t *testing.T) {
+	testCases := []struct {
+		name           string
+		rsp            *http.Response
+		err            error
+		expectedStatus int32
+		expectedCause  string
+		expectedDetail string
+	}{
+		{
+			name: "Timeout",
+			err: &url.Error{
+				Op:  "Get",
+				URL: "http://127.0.0.4:8000/nudr-dr/v1/subscription-data/imsi-208930000000001",
+				Err: context.DeadlineExceeded,
+			},
+			expectedStatus: http.StatusGatewayTimeout,
+			expectedCause:  "UPSTREAM_SERVER_ERROR",
+			expectedDetail: "no response from the UDR: Get \"http://127.0.0.4:8000/nudr-dr/v1/subscription-data/" +
+				"imsi-208930000000001\": context deadline exceeded",
+		},
+		{
+			name:           "Request Not Sent",
+			err:            errors.New("No UDR URI found"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCause:  "SYSTEM_FAILURE",
+			expectedDetail: "No UDR URI found",
+		},
+		{
+			name: "Not Found",
+			rsp:  &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"},
+			err: openapi.GenericOpenAPIError{
+				ErrorStatus: "404 Not Found",
+				ErrorModel: models.ProblemDetails{
+					Status: http.StatusNotFound,
+					Cause:  "DATA_NOT_FOUND",
+					Detail: "authentication subscription not found",
+				},
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedCause:  "USER_NOT_FOUND",
+			expectedDetail: "authentication subscription not found",
+		},
+		{
+			name:           "Not Found Without ProblemDetails",
+			rsp:            &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"},
+			err:            openapi.GenericOpenAPIError{ErrorStatus: "unexpected end of JSON input"},
+			expectedStatus: http.StatusNotFound,
+			expectedCause:  "USER_NOT_FOUND",
+			expectedDetail: "unexpected end of JSON input",
+		},
+		{
+			name: "Error Of The UDR",
+			rsp:  &http.Response{StatusCode: http.StatusForbidden, Status: "403 Forbidden"},
+			err: openapi.GenericOpenAPIError{
+				ErrorStatus: "403 Forbidden",
+				ErrorModel:  models.ProblemDetails{Status: http.StatusForbidden, Cause: "MODIFICATION_NOT_ALLOWED"},
+			},
+			expectedStatus: http.StatusForbidden,
+			expectedCause:  "MODIFICATION_NOT_ALLOWED",
+			expectedDetail: "403 Forbidden",
+		},
+		{
+			name:           "Server Error Without ProblemDetails",
+			rsp:            &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable"},
+			err:            openapi.GenericOpenAPIError{ErrorStatus: "503 Service Unavailable"},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCause:  "SYSTEM_FAILURE",
+			expectedDetail: "503 Service Unavailable",
+		},
+		{
+			name:           "Invalid Response",
+			rsp:            &http.Response{StatusCode: http.StatusOK, Status: "200 OK"},
+			err:            openapi.GenericOpenAPIError{ErrorStatus: "invalid character '<'"},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCause:  "SYSTEM_FAILURE",
+			expectedDetail: "invalid response of the UDR: invalid character '<'",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problemDetails := UdrProblemDetails(tc.rsp, tc.err, "USER_NOT_FOUND")
+			require.Equal(t, tc.expectedStatus, problemDetails.Status)
+			require.Equal(t, tc.expectedCause, problemDetails.Cause)
+			require.Equal(t, tc.expectedDetail, problemDetails.Detail)
+		})
+	}
+}
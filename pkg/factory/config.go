@@ -5,10 +5,16 @@
 package factory
 
 import (
+	"encoding/hex"
 	"fmt"
+	"math"
+	"net"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 
@@ -35,6 +41,22 @@ const (
 	UdmUecmResUriPrefix           = "/nudm-uecm/v1"
 	UdmPpResUriPrefix             = "/nudm-pp/v1"
 	UdmUeauResUriPrefix           = "/nudm-ueau/v1"
+	UdmNfStatusNotifyUri          = "/nf-status-notify"
+	UdmMgmtResUriPrefix           = "/mgmt"
+	UdmDefaultDrainPeriod         = 2 * time.Second
+	UdmDefaultNotificationTimeout = 5 * time.Second
+	UdmDefaultNrfTimeout          = 3 * time.Second
+	UdmDefaultServerTimeout       = 2 * time.Second
+	UdmDefaultMaxInFlight         = 1000
+	UdmDefaultMaxUdrLatency       = time.Second
+	UdmDefaultOverloadThreshold   = 80
+	UdmDefaultOciValidityPeriod   = 60 * time.Second
+)
+
+const (
+	TracingExporterOtlpHttp = "otlphttp"
+	TracingExporterStdout   = "stdout"
+	TracingExporterFile     = "file"
 )
 
 type Config struct {
@@ -42,6 +64,9 @@ type Config struct {
 	Configuration *Configuration `yaml:"configuration" valid:"required"`
 	Logger        *Logger        `yaml:"logger" valid:"required"`
 	sync.RWMutex
+
+	// the file read by ReadConfig, which is read again on reload
+	path string
 }
 
 func (c *Config) Validate() (bool, error) {
@@ -63,10 +88,317 @@ type Info struct {
 type Configuration struct {
 	Sbi             *Sbi               `yaml:"sbi,omitempty"  valid:"required"`
 	ServiceNameList []string           `yaml:"serviceNameList,omitempty"  valid:"required"`
-	NrfUri          string             `yaml:"nrfUri,omitempty"  valid:"required, url"`
+	NrfUri          string             `yaml:"nrfUri,omitempty"  valid:"optional, url"`
 	NrfCertPem      string             `yaml:"nrfCertPem,omitempty" valid:"optional"`
 	SuciProfiles    []suci.SuciProfile `yaml:"SuciProfile,omitempty"`
+	// SubscriberKeyProtection unwraps the K, OP and OPc stored encrypted in the UDR
+	SubscriberKeyProtection *SubscriberKeyProtection `yaml:"subscriberKeyProtection,omitempty" valid:"optional"`
+	// UdmInfo is registered to the NRF for the UDM selection (TS 29.510 6.1.6.2.15)
+	UdmInfo *UdmInfo `yaml:"udmInfo,omitempty" valid:"optional"`
+	// DisableNrf runs the UDM without NRF: it is not registered, and the UDRs are taken from Udrs
+	DisableNrf bool `yaml:"disableNrf,omitempty" valid:"optional"`
+	// Udrs are used instead of the UDRs discovered from the NRF
+	Udrs []StaticUdr `yaml:"udrs,omitempty" valid:"optional"`
+	// Scp routes the requests of the UDM through an SCP instead of sending them to the NFs directly
+	Scp *Scp `yaml:"scp,omitempty" valid:"optional"`
+	// Binding completes the binding indication sent to the consumers of the UE contexts
+	Binding *Binding `yaml:"binding,omitempty" valid:"optional"`
+	// Overload enables the rate limiting of the consumers and the overload control headers
+	Overload *Overload `yaml:"overload,omitempty" valid:"optional"`
+	// Shutdown configures the timeouts of the graceful shutdown
+	Shutdown *Shutdown `yaml:"shutdown,omitempty" valid:"optional"`
+	// Management enables the management API, e.g. the config reload
+	Management *Management `yaml:"management,omitempty" valid:"optional"`
+	// Metrics enables the Prometheus metrics listener
+	Metrics *Metrics `yaml:"metrics,omitempty" valid:"optional"`
+	// Tracing enables the export of the OpenTelemetry spans
+	Tracing *Tracing `yaml:"tracing,omitempty" valid:"optional"`
+}
+
+// Tracing configures the exporter of the OpenTelemetry spans. The stdout and file exporters write
+// the spans in JSON, e.g. for testing without a collector.
+type Tracing struct {
+	// Exporter is "otlphttp", "stdout" or "file"
+	Exporter string `yaml:"exporter" valid:"required,in(otlphttp|stdout|file)"`
+	// Endpoint is the host:port of the OTLP/HTTP collector, e.g. "127.0.0.1:4318"
+	Endpoint string `yaml:"endpoint,omitempty" valid:"optional"`
+	// Insecure sends the spans to the collector over HTTP instead of HTTPS
+	Insecure bool `yaml:"insecure,omitempty" valid:"optional"`
+	// File is the path the spans are appended to by the file exporter, in the OTLP file format (a line
+	// of OTLP/JSON per export), which the collectors and the tracing backends can replay
+	File string `yaml:"file,omitempty" valid:"optional"`
+	// SampleRatio is the ratio of the traces started by the UDM which are sampled, all of them if 0.
+	// The traces started by the requesting NFs follow their sampling decision.
+	SampleRatio float64 `yaml:"sampleRatio,omitempty" valid:"optional"`
+}
+
+func (t *Tracing) validate() (bool, error) {
+	switch {
+	case t.Exporter == TracingExporterOtlpHttp && t.Endpoint == "":
+		return false, govalidator.Errors{fmt.Errorf("Tracing endpoint is required by the otlphttp exporter")}
+	case t.Exporter == TracingExporterFile && t.File == "":
+		return false, govalidator.Errors{fmt.Errorf("Tracing file is required by the file exporter")}
+	case t.SampleRatio < 0 || t.SampleRatio > 1:
+		return false, govalidator.Errors{fmt.Errorf("Invalid tracing sampleRatio: %v", t.SampleRatio)}
+	}
+	return true, nil
+}
+
+// Metrics configures the listener of the Prometheus metrics, which is separated from the SBI
+type Metrics struct {
+	// BindingAddr is the host:port serving /metrics, e.g. "127.0.0.3:9091"
+	BindingAddr string `yaml:"bindingAddr" valid:"required"`
+}
+
+func (m *Metrics) validate() (bool, error) {
+	if _, _, err := net.SplitHostPort(m.BindingAddr); err != nil {
+		return false, govalidator.Errors{fmt.Errorf("Invalid metrics bindingAddr: %+v", err)}
+	}
+	return true, nil
+}
+
+// Overload configures the rate limiting of the consumers and the overload control (TS 29.500 6.3 and
+// 6.4). The load of the UDM is the highest of its in-flight requests and the latency of the UDR relative
+// to their maximum, and is sent in the 3gpp-Sbi-Lci header. From OverloadThreshold, the consumers are
+// asked to reduce their traffic by the 3gpp-Sbi-Oci header. The unset values take the defaults.
+type Overload struct {
+	// RateLimit is the number of requests per second accepted from each consumer NF instance by each
+	// service, and Burst the number of requests accepted at once. The requests are not limited if 0.
+	// The consumers are identified by their client certificate or access token, so the requests are
+	// only limited with mTLS or OAuth2.
+	RateLimit float64 `yaml:"rateLimit,omitempty" valid:"optional"`
+	Burst     int     `yaml:"burst,omitempty" valid:"optional"`
+	// MaxInFlight is the number of in-flight requests loading the UDM at 100%
+	MaxInFlight int `yaml:"maxInFlight,omitempty" valid:"optional"`
+	// MaxUdrLatency is the latency of the UDR loading the UDM at 100%
+	MaxUdrLatency time.Duration `yaml:"maxUdrLatency,omitempty" valid:"optional"`
+	// OverloadThreshold is the load in percent from which the UDM is overloaded
+	OverloadThreshold int `yaml:"overloadThreshold,omitempty" valid:"optional"`
+	// OciValidityPeriod is the period of validity of the overload control information
+	OciValidityPeriod time.Duration `yaml:"ociValidityPeriod,omitempty" valid:"optional"`
+}
+
+func (o *Overload) validate() (bool, error) {
+	switch {
+	case o.RateLimit < 0 || o.Burst < 0:
+		return false, govalidator.Errors{fmt.Errorf("Invalid overload: rateLimit and burst should not be negative")}
+	case o.MaxInFlight < 0 || o.MaxUdrLatency < 0 || o.OciValidityPeriod < 0:
+		return false, govalidator.Errors{fmt.Errorf(
+			"Invalid overload: maxInFlight, maxUdrLatency and ociValidityPeriod should not be negative")}
+	case o.OverloadThreshold < 0 || o.OverloadThreshold > 100:
+		return false, govalidator.Errors{fmt.Errorf("Invalid overload threshold: %d", o.OverloadThreshold)}
+	}
+	return true, nil
+}
+
+// Management configures the management API served under /mgmt
+type Management struct {
+	// Token is the bearer token required in the Authorization header of the management requests
+	Token string `yaml:"token" valid:"required"`
+}
+
+// Shutdown configures the graceful shutdown: the UDM is marked UNDISCOVERABLE in the NRF, serves the
+// in-flight requests for DrainPeriod, sends the pending notifications, deregisters from the NRF and
+// then stops the HTTP server. The unset values take the defaults.
+type Shutdown struct {
+	DrainPeriod time.Duration `yaml:"drainPeriod,omitempty"`
+	// the maximum time to wait for the pending notifications
+	NotificationTimeout time.Duration `yaml:"notificationTimeout,omitempty"`
+	// the timeout of each request to the NRF
+	NrfTimeout time.Duration `yaml:"nrfTimeout,omitempty"`
+	// the maximum time to wait for the HTTP server to close the connections
+	ServerTimeout time.Duration `yaml:"serverTimeout,omitempty"`
+}
+
+func (s *Shutdown) validate() (bool, error) {
+	if s.DrainPeriod < 0 || s.NotificationTimeout < 0 || s.NrfTimeout < 0 || s.ServerTimeout < 0 {
+		return false, govalidator.Errors{fmt.Errorf("Invalid shutdown: timeouts should not be negative")}
+	}
+	return true, nil
+}
+
+// StaticUdr is a UDR used without NRF discovery. A UDR without supiRanges serves the SUPIs out of
+// the ranges of the other UDRs, and the other identities (e.g. GPSIs).
+type StaticUdr struct {
+	Uri        string          `yaml:"uri" valid:"required,url"`
+	SupiRanges []IdentityRange `yaml:"supiRanges,omitempty"`
+}
+
+// Scp is the SCP of the indirect communication (TS 23.501 6.3.1.0). The UDRs are discovered by the
+// UDM (model C), or by the SCP from the 3gpp-Sbi-Discovery-* headers (model D).
+type Scp struct {
+	// Uri is the apiRoot of the SCP, with its apiPrefix if any, e.g. "https://scp.example.com:443"
+	Uri string `yaml:"uri" valid:"required,url"`
+	// DelegatedDiscovery lets the SCP discover the UDRs (model D)
+	DelegatedDiscovery bool `yaml:"delegatedDiscovery,omitempty" valid:"optional"`
+}
+
+// Binding is the binding indication of the UE contexts created by the UECM registrations and the SDM
+// subscriptions (TS 29.500 6.12). The UEs are bound to the UDM instance, as their contexts are only
+// kept in its memory; the NF set lets the consumers select another UDM when the instance has failed.
+type Binding struct {
+	// NfSetId is the NF set of the UDM, e.g. "set1.udmset.5gc.mnc093.mcc208", registered to the NRF
+	NfSetId string `yaml:"nfSetId,omitempty" valid:"optional"`
+}
+
+type UdmInfo struct {
+	GroupId                        string                 `yaml:"groupId,omitempty"`
+	SupiRanges                     []IdentityRange        `yaml:"supiRanges,omitempty"`
+	GpsiRanges                     []IdentityRange        `yaml:"gpsiRanges,omitempty"`
+	ExternalGroupIdentifiersRanges []IdentityRange        `yaml:"externalGroupIdentifiersRanges,omitempty"`
+	RoutingIndicators              []string               `yaml:"routingIndicators,omitempty"`
+	InternalGroupIdentifiersRanges []InternalGroupIdRange `yaml:"internalGroupIdentifiersRanges,omitempty"`
+}
+
+// IdentityRange is a range of SUPIs, GPSIs or external group identifiers given by either the
+// start and end numbers or a regular expression (TS 29.510 6.1.6.2.16 and 6.1.6.2.17)
+type IdentityRange struct {
+	Start   string `yaml:"start,omitempty" valid:"optional"`
+	End     string `yaml:"end,omitempty" valid:"optional"`
+	Pattern string `yaml:"pattern,omitempty" valid:"optional"`
+}
+
+// InternalGroupIdRange is a range of internal group identifiers (TS 29.510 6.1.6.2.63)
+type InternalGroupIdRange struct {
+	Start   string `yaml:"start,omitempty" valid:"optional"`
+	End     string `yaml:"end,omitempty" valid:"optional"`
+	Pattern string `yaml:"pattern,omitempty" valid:"optional"`
 }
+
+const (
+	// TS 29.571 5.2.2 GroupId
+	internalGroupIdPattern  = "^[A-Fa-f0-9]{8}-[0-9]{3}-[0-9]{2,3}-([A-Fa-f0-9][A-Fa-f0-9]){1,10}$"
+	identityRangePattern    = "^[0-9]+$"
+	routingIndicatorPattern = "^[0-9]{1,4}$"
+)
+
+func (u *UdmInfo) validate() (bool, error) {
+	var errs govalidator.Errors
+	for _, r := range u.SupiRanges {
+		if err := validateRange("supiRanges", r.Start, r.End, r.Pattern, identityRangePattern); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, r := range u.GpsiRanges {
+		if err := validateRange("gpsiRanges", r.Start, r.End, r.Pattern, identityRangePattern); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, r := range u.ExternalGroupIdentifiersRanges {
+		err := validateRange("externalGroupIdentifiersRanges", r.Start, r.End, r.Pattern, identityRangePattern)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, r := range u.InternalGroupIdentifiersRanges {
+		err := validateRange("internalGroupIdentifiersRanges", r.Start, r.End, r.Pattern, internalGroupIdPattern)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, routingIndicator := range u.RoutingIndicators {
+		if !govalidator.StringMatches(routingIndicator, routingIndicatorPattern) {
+			errs = append(errs, fmt.Errorf("Invalid routingIndicators: %s, should be 1 to 4 decimal digits",
+				routingIndicator))
+		}
+	}
+	if len(errs) > 0 {
+		return false, error(errs)
+	}
+
+	result, err := govalidator.ValidateStruct(u)
+	return result, err
+}
+
+// validateRange checks that a range has either a pattern or both start and end matching
+// valuePattern, with start not greater than end
+func validateRange(name, start, end, pattern, valuePattern string) error {
+	if pattern != "" {
+		if start != "" || end != "" {
+			return fmt.Errorf("Invalid %s: either start/end or pattern should be set", name)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("Invalid %s: pattern %s: %+v", name, pattern, err)
+		}
+		return nil
+	}
+	if !govalidator.StringMatches(start, valuePattern) || !govalidator.StringMatches(end, valuePattern) {
+		return fmt.Errorf("Invalid %s: start %s and end %s should match %s", name, start, end, valuePattern)
+	}
+	if len(start) != len(end) || strings.ToLower(start) > strings.ToLower(end) {
+		return fmt.Errorf("Invalid %s: start %s should not be greater than end %s", name, start, end)
+	}
+	return nil
+}
+
+type SubscriberKeyProtection struct {
+	Keks      []Kek      `yaml:"keks,omitempty" valid:"optional"`
+	KmsPlugin *KmsPlugin `yaml:"kmsPlugin,omitempty" valid:"optional"`
+}
+
+// Kek is a key-encryption-key referenced by the encryptionKey of the subscriber keys in the UDR
+type Kek struct {
+	EncryptionKey int32 `yaml:"encryptionKey" valid:"required"`
+	// hex encoded AES-128/192/256 key, or the file containing it
+	Key     string `yaml:"key,omitempty" valid:"optional"`
+	KeyFile string `yaml:"keyFile,omitempty" valid:"optional"`
+}
+
+// KmsPlugin is the command unwrapping the keys of the KEKs which are not configured here,
+// see keywrap.PluginRequest and keywrap.PluginResponse
+type KmsPlugin struct {
+	Command string        `yaml:"command" valid:"required"`
+	Args    []string      `yaml:"args,omitempty" valid:"optional"`
+	Timeout time.Duration `yaml:"timeout,omitempty" valid:"optional"`
+}
+
+// AES-128, AES-192 or AES-256 key
+const kekPattern = "^([A-Fa-f0-9]{32}|[A-Fa-f0-9]{48}|[A-Fa-f0-9]{64})$"
+
+func (p *SubscriberKeyProtection) validate() (bool, error) {
+	var errs govalidator.Errors
+	ids := make(map[int32]bool)
+	for _, kek := range p.Keks {
+		if ids[kek.EncryptionKey] {
+			errs = append(errs, fmt.Errorf("Invalid Kek: duplicate encryptionKey(%d)", kek.EncryptionKey))
+		}
+		ids[kek.EncryptionKey] = true
+		if (kek.Key == "") == (kek.KeyFile == "") {
+			errs = append(errs, fmt.Errorf("Invalid Kek(%d): either key or keyFile should be set", kek.EncryptionKey))
+		} else if kek.Key != "" && !govalidator.StringMatches(kek.Key, kekPattern) {
+			errs = append(errs, fmt.Errorf("Invalid Kek(%d): key should be 32, 48 or 64 hexadecimal digits",
+				kek.EncryptionKey))
+		}
+	}
+	if len(errs) > 0 {
+		return false, error(errs)
+	}
+
+	result, err := govalidator.ValidateStruct(p)
+	return result, err
+}
+
+// GetKeks loads the KEKs with encryptionKey as key
+func (p *SubscriberKeyProtection) GetKeks() (map[int32][]byte, error) {
+	keks := make(map[int32][]byte)
+	for _, kek := range p.Keks {
+		keyHex := kek.Key
+		if kek.KeyFile != "" {
+			content, err := os.ReadFile(kek.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("Read Kek(%d) keyFile error: %+v", kek.EncryptionKey, err)
+			}
+			keyHex = strings.TrimSpace(string(content))
+		}
+		key, err := hex.DecodeString(keyHex)
+		if err != nil {
+			return nil, fmt.Errorf("Decode Kek(%d) error: %+v", kek.EncryptionKey, err)
+		}
+		keks[kek.EncryptionKey] = key
+	}
+	return keks, nil
+}
+
 type Logger struct {
 	Enable       bool   `yaml:"enable" valid:"type(bool)"`
 	Level        string `yaml:"level" valid:"required,in(trace|debug|info|warn|error|fatal|panic)"`
@@ -103,10 +435,22 @@ func (c *Configuration) validate() (bool, error) {
 				errs = append(errs, err)
 			}
 
-			privateKey := s.PrivateKey
-			if result := govalidator.StringMatches(privateKey, "^[A-Fa-f0-9]{64}$"); !result {
-				err := fmt.Errorf("Invalid PrivateKey: %s, should be 64 hexadecimal digits", privateKey)
-				errs = append(errs, err)
+			switch s.Backend {
+			case "", suci.BackendSoftware:
+				privateKey := s.PrivateKey
+				if result := govalidator.StringMatches(privateKey, "^[A-Fa-f0-9]{64}$"); !result {
+					err := fmt.Errorf("Invalid PrivateKey: should be 64 hexadecimal digits")
+					errs = append(errs, err)
+				}
+			case suci.BackendPkcs11:
+				if s.PrivateKey != "" {
+					errs = append(errs, fmt.Errorf("PrivateKey should not be set for the pkcs11 backend"))
+				}
+				if p := s.Pkcs11; p == nil || p.Module == "" || p.TokenLabel == "" || p.KeyLabel == "" {
+					errs = append(errs, fmt.Errorf("Invalid Pkcs11: Module, TokenLabel and KeyLabel are required"))
+				}
+			default:
+				errs = append(errs, fmt.Errorf("Invalid Backend: %s, should be software or pkcs11", s.Backend))
 			}
 
 			publicKey := s.PublicKey
@@ -115,6 +459,76 @@ func (c *Configuration) validate() (bool, error) {
 					"or 130(profile B, uncompressed) hexadecimal digits", publicKey)
 				errs = append(errs, err)
 			}
+
+			// the RoutingIndicator is optional, and checked against the SUCI only if it is set
+			routingIndicator := s.RoutingIndicator
+			if routingIndicator != "" && !govalidator.StringMatches(routingIndicator, routingIndicatorPattern) {
+				err := fmt.Errorf("Invalid RoutingIndicator: %s, should be 1 to 4 decimal digits", routingIndicator)
+				errs = append(errs, err)
+			}
+		}
+		if _, err := suci.ProfileMap(c.SuciProfiles); err != nil {
+			errs = append(errs, fmt.Errorf("Invalid SuciProfile: %+v", err))
+		}
+		if len(errs) > 0 {
+			return false, error(errs)
+		}
+	}
+
+	if p := c.SubscriberKeyProtection; p != nil {
+		if result, err := p.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if u := c.UdmInfo; u != nil {
+		if result, err := u.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if m := c.Metrics; m != nil {
+		if result, err := m.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if t := c.Tracing; t != nil {
+		if result, err := t.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if o := c.Overload; o != nil {
+		if result, err := o.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if shutdown := c.Shutdown; shutdown != nil {
+		if result, err := shutdown.validate(); err != nil {
+			return result, err
+		}
+	}
+
+	if c.NrfUri == "" && !c.DisableNrf {
+		return false, govalidator.Errors{fmt.Errorf("Invalid nrfUri: required unless disableNrf is set")}
+	}
+	if c.DisableNrf && len(c.Udrs) == 0 && (c.Scp == nil || !c.Scp.DelegatedDiscovery) {
+		return false, govalidator.Errors{fmt.Errorf(
+			"Invalid udrs: required if disableNrf is set, unless the SCP discovers the UDRs")}
+	}
+	if c.Udrs != nil {
+		var errs govalidator.Errors
+		for _, udr := range c.Udrs {
+			if !govalidator.IsURL(udr.Uri) {
+				errs = append(errs, fmt.Errorf("Invalid udrs: uri %s should be a URL", udr.Uri))
+			}
+			for _, r := range udr.SupiRanges {
+				if err := validateRange("udrs supiRanges", r.Start, r.End, r.Pattern, identityRangePattern); err != nil {
+					errs = append(errs, err)
+				}
+			}
 		}
 		if len(errs) > 0 {
 			return false, error(errs)
@@ -137,11 +551,26 @@ func (c *Config) GetCertKeyPath() string {
 	return c.Configuration.Sbi.Tls.Key
 }
 
+// GetSbiTls returns the TLS settings of the SBI, or nil if they are not configured
+func (c *Config) GetSbiTls() *Tls {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration == nil || c.Configuration.Sbi == nil || c.Configuration.Sbi.Tls == nil {
+		return nil
+	}
+	tls := *c.Configuration.Sbi.Tls
+	return &tls
+}
+
 type Sbi struct {
 	Scheme       string `yaml:"scheme" valid:"scheme"`
-	RegisterIPv4 string `yaml:"registerIPv4,omitempty" valid:"host,required"` // IP that is registered at NRF.
-	// IPv6Addr string `yaml:"ipv6Addr,omitempty"`
-	BindingIPv4 string `yaml:"bindingIPv4,omitempty" valid:"host,required"` // IP used to run the server in the node.
+	RegisterIPv4 string `yaml:"registerIPv4,omitempty" valid:"host,optional"` // IP that is registered at NRF.
+	RegisterIPv6 string `yaml:"registerIPv6,omitempty" valid:"ipv6,optional"` // IPv6 that is registered at NRF.
+	// FQDN that is registered at NRF, and used in the URIs of the UDM instead of the IP
+	Fqdn        string `yaml:"fqdn,omitempty" valid:"dns,optional"`
+	BindingIPv4 string `yaml:"bindingIPv4,omitempty" valid:"host,optional"` // IP used to run the server in the node.
+	// IPv6 used to run the server in the node, together with BindingIPv4 on a dual-stack node
+	BindingIPv6 string `yaml:"bindingIPv6,omitempty" valid:"host,optional"`
 	Port        int    `yaml:"port,omitempty" valid:"port,required"`
 	Tls         *Tls   `yaml:"tls,omitempty" valid:"optional"`
 }
@@ -157,16 +586,46 @@ func (s *Sbi) validate() (bool, error) {
 		}
 	}
 
+	if s.RegisterIPv4 == "" && s.RegisterIPv6 == "" && s.Fqdn == "" {
+		return false, govalidator.Errors{fmt.Errorf("Invalid sbi: registerIPv4, registerIPv6 or fqdn is required")}
+	}
+	if s.BindingIPv4 == "" && s.BindingIPv6 == "" {
+		return false, govalidator.Errors{fmt.Errorf("Invalid sbi: bindingIPv4 or bindingIPv6 is required")}
+	}
+
 	result, err := govalidator.ValidateStruct(s)
 	return result, err
 }
 
+// Tls configures the certificates of the SBI. The certificates are loaded again when their files change.
 type Tls struct {
 	Pem string `yaml:"pem,omitempty" valid:"type(string),minstringlength(1),required"`
 	Key string `yaml:"key,omitempty" valid:"type(string),minstringlength(1),required"`
+	// Ca is the CA bundle verifying the client certificates. The client certificates are required
+	// (mutual TLS) if it is set.
+	Ca string `yaml:"ca,omitempty" valid:"optional"`
+	// AllowedNfTypes are the NF types allowed per service, e.g. "nudm-ueau: [AUSF]", given by the URI
+	// SAN "<nftype>.<nfinstance>" of the client certificate. The services not listed allow all NF types.
+	AllowedNfTypes map[string][]string `yaml:"allowedNfTypes,omitempty" valid:"optional"`
+	// Client is the certificate presented to the UDR, the NRF and the callbacks over HTTPS
+	Client *TlsCert `yaml:"client,omitempty" valid:"optional"`
+}
+
+// TlsCert is a certificate and its private key
+type TlsCert struct {
+	Pem string `yaml:"pem" valid:"type(string),minstringlength(1),required"`
+	Key string `yaml:"key" valid:"type(string),minstringlength(1),required"`
 }
 
 func (t *Tls) validate() (bool, error) {
+	if len(t.AllowedNfTypes) > 0 && t.Ca == "" {
+		return false, govalidator.Errors{fmt.Errorf("Invalid tls: allowedNfTypes requires the client certificates of ca")}
+	}
+	for serviceName, nfTypes := range t.AllowedNfTypes {
+		if len(nfTypes) == 0 {
+			return false, govalidator.Errors{fmt.Errorf("Invalid tls allowedNfTypes: no NF type for %s", serviceName)}
+		}
+	}
 	result, err := govalidator.ValidateStruct(t)
 	return result, err
 }
@@ -270,10 +729,24 @@ func (c *Config) GetLogReportCaller() bool {
 	return c.Logger.ReportCaller
 }
 
-func (c *Config) GetSbiBindingAddr() string {
+// GetSbiBindingAddrs returns the addresses the SBI server listens on: the IPv4 and the IPv6 address
+// on a dual-stack node, or only one of them
+func (c *Config) GetSbiBindingAddrs() []string {
+	port := strconv.Itoa(c.GetSbiPort())
+	bindIPv6 := c.GetSbiBindingIPv6()
+
 	c.RLock()
-	defer c.RUnlock()
-	return c.GetSbiBindingIP() + ":" + strconv.Itoa(c.GetSbiPort())
+	hasIPv4 := c.Configuration != nil && c.Configuration.Sbi != nil && c.Configuration.Sbi.BindingIPv4 != ""
+	c.RUnlock()
+
+	var addrs []string
+	if hasIPv4 || bindIPv6 == "" {
+		addrs = append(addrs, net.JoinHostPort(c.GetSbiBindingIP(), port))
+	}
+	if bindIPv6 != "" {
+		addrs = append(addrs, net.JoinHostPort(bindIPv6, port))
+	}
+	return addrs
 }
 
 func (c *Config) GetSbiBindingIP() string {
@@ -293,6 +766,21 @@ func (c *Config) GetSbiBindingIP() string {
 	return bindIP
 }
 
+// GetSbiBindingIPv6 returns the IPv6 binding address, or "" if the SBI server does not listen on IPv6
+func (c *Config) GetSbiBindingIPv6() string {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration == nil || c.Configuration.Sbi == nil || c.Configuration.Sbi.BindingIPv6 == "" {
+		return ""
+	}
+	bindIPv6 := os.Getenv(c.Configuration.Sbi.BindingIPv6)
+	if bindIPv6 != "" {
+		logger.CfgLog.Infof("Parsing ServerIPv6 [%s] from ENV Variable", bindIPv6)
+		return bindIPv6
+	}
+	return c.Configuration.Sbi.BindingIPv6
+}
+
 func (c *Config) GetSbiPort() int {
 	c.RLock()
 	defer c.RUnlock()
@@ -310,3 +798,102 @@ func (c *Config) GetSbiScheme() string {
 	}
 	return UdmSbiDefaultScheme
 }
+
+// GetConfigPath returns the file the config is read from
+func (c *Config) GetConfigPath() string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.path
+}
+
+// GetManagementToken returns the bearer token of the management API, or "" if it is disabled
+func (c *Config) GetManagementToken() string {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration == nil || c.Configuration.Management == nil {
+		return ""
+	}
+	return c.Configuration.Management.Token
+}
+
+// GetMetricsBindingAddr returns the address of the metrics listener, or "" if it is disabled
+func (c *Config) GetMetricsBindingAddr() string {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration == nil || c.Configuration.Metrics == nil {
+		return ""
+	}
+	return c.Configuration.Metrics.BindingAddr
+}
+
+// GetTracing returns the tracing settings, or nil if the spans are not exported
+func (c *Config) GetTracing() *Tracing {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration == nil || c.Configuration.Tracing == nil {
+		return nil
+	}
+	tracing := *c.Configuration.Tracing
+	return &tracing
+}
+
+// Reload takes the settings which can be changed at runtime from cfg: the SUCI profiles, the NRF URI
+// and the service name list. The logger settings are taken by SetLogEnable, SetLogLevel and
+// SetLogReportCaller, and the other settings require a restart.
+func (c *Config) Reload(cfg *Config) {
+	c.Lock()
+	defer c.Unlock()
+	c.Configuration.SuciProfiles = cfg.Configuration.SuciProfiles
+	c.Configuration.NrfUri = cfg.Configuration.NrfUri
+	c.Configuration.ServiceNameList = cfg.Configuration.ServiceNameList
+}
+
+// GetOverload returns the overload configuration with the defaults of the unset values, or nil if the
+// overload control is disabled
+func (c *Config) GetOverload() *Overload {
+	c.RLock()
+	defer c.RUnlock()
+	if c.Configuration == nil || c.Configuration.Overload == nil {
+		return nil
+	}
+	overload := *c.Configuration.Overload
+	if overload.Burst == 0 {
+		overload.Burst = int(math.Ceil(overload.RateLimit))
+	}
+	if overload.MaxInFlight == 0 {
+		overload.MaxInFlight = UdmDefaultMaxInFlight
+	}
+	if overload.MaxUdrLatency == 0 {
+		overload.MaxUdrLatency = UdmDefaultMaxUdrLatency
+	}
+	if overload.OverloadThreshold == 0 {
+		overload.OverloadThreshold = UdmDefaultOverloadThreshold
+	}
+	if overload.OciValidityPeriod == 0 {
+		overload.OciValidityPeriod = UdmDefaultOciValidityPeriod
+	}
+	return &overload
+}
+
+// GetShutdown returns the shutdown configuration with the defaults of the unset values
+func (c *Config) GetShutdown() Shutdown {
+	c.RLock()
+	defer c.RUnlock()
+	shutdown := Shutdown{}
+	if c.Configuration != nil && c.Configuration.Shutdown != nil {
+		shutdown = *c.Configuration.Shutdown
+	}
+	if shutdown.DrainPeriod == 0 {
+		shutdown.DrainPeriod = UdmDefaultDrainPeriod
+	}
+	if shutdown.NotificationTimeout == 0 {
+		shutdown.NotificationTimeout = UdmDefaultNotificationTimeout
+	}
+	if shutdown.NrfTimeout == 0 {
+		shutdown.NrfTimeout = UdmDefaultNrfTimeout
+	}
+	if shutdown.ServerTimeout == 0 {
+		shutdown.ServerTimeout = UdmDefaultServerTimeout
+	}
+	return shutdown
+}
@@ -0,0 +1,61 @@
+package factory
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfigTemplate = `info:
+  version: 1.0.3
+configuration:
+  sbi:
+    scheme: http
+    registerIPv4: 127.0.0.3
+    bindingIPv4: 127.0.0.3
+    port: 8000
+  serviceNameList:
+    - nudm-ueau
+  nrfUri: http://127.0.0.10:8000
+  SuciProfile:
+    - ProtectionScheme: 1
+      PrivateKey: c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d
+      PublicKey: 5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650
+%s
+logger:
+  enable: true
+  level: info
+`
+
+func TestReadConfigSuciProfileRoutingIndicator(t *testing.T) {
+	testCases := []struct {
+		name             string
+		routingIndicator string
+		expectedErr      bool
+	}{
+		{name: "unset", routingIndicator: "", expectedErr: false},
+		{name: "4 digits", routingIndicator: "      RoutingIndicator: \"0001\"", expectedErr: false},
+		{name: "1 digit", routingIndicator: "      RoutingIndicator: \"1\"", expectedErr: false},
+		{name: "5 digits", routingIndicator: "      RoutingIndicator: \"00001\"", expectedErr: true},
+		{name: "not decimal", routingIndicator: "      RoutingIndicator: \"00a1\"", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgPath := filepath.Join(t.TempDir(), "udmcfg.yaml")
+			content := fmt.Sprintf(testConfigTemplate, tc.routingIndicator)
+			if err := os.WriteFile(cfgPath, []byte(content), 0o600); err != nil {
+				t.Fatalf("WriteFile fail: %+v", err)
+			}
+
+			_, err := ReadConfig(cfgPath)
+			if tc.expectedErr && err == nil {
+				t.Errorf("ReadConfig should fail")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("ReadConfig fail: %+v", err)
+			}
+		})
+	}
+}
@@ -16,7 +16,6 @@ import (
 
 var UdmConfig *Config
 
-// TODO: Support configuration update from REST api
 func InitConfigFactory(f string, cfg *Config) error {
 	if f == "" {
 		// Use default config path
@@ -47,6 +46,7 @@ func ReadConfig(cfgPath string) (*Config, error) {
 		logger.CfgLog.Errorf("[-- PLEASE REFER TO SAMPLE CONFIG FILE COMMENTS --]")
 		return nil, fmt.Errorf("Config validate Error")
 	}
+	cfg.path = cfgPath
 
 	return cfg, nil
 }
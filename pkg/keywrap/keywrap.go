@@ -0,0 +1,160 @@
+// Package keywrap unwraps the subscriber keys (K, OP, OPc) which are stored encrypted in the UDR.
+// The encryptionKey of the stored key selects the KEK, and its encryptionAlgorithm selects how
+// the key is wrapped (TS 29.505 PermanentKey, Op and Opc).
+package keywrap
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+)
+
+// The values of encryptionAlgorithm
+const (
+	AlgorithmNone       int32 = 0
+	AlgorithmAesKeyWrap int32 = 1 // RFC 3394
+	AlgorithmAesGcm     int32 = 2 // nonce(12) || cipher text || tag(16)
+)
+
+const gcmNonceLen = 12
+
+// Unwrapper decrypts a wrapped subscriber key
+type Unwrapper interface {
+	Unwrap(encryptionKey, encryptionAlgorithm int32, wrapped []byte) ([]byte, error)
+}
+
+// kekUnwrapper unwraps with the KEKs held in memory, and hands the keys of unknown KEKs to the
+// KMS plugin if there is one
+type kekUnwrapper struct {
+	keks   map[int32][]byte
+	plugin Unwrapper
+}
+
+// New creates the Unwrapper of the KEKs (encryptionKey as key) and the optional KMS plugin
+func New(keks map[int32][]byte, plugin Unwrapper) Unwrapper {
+	return &kekUnwrapper{
+		keks:   keks,
+		plugin: plugin,
+	}
+}
+
+func (u *kekUnwrapper) Unwrap(encryptionKey, encryptionAlgorithm int32, wrapped []byte) ([]byte, error) {
+	if encryptionAlgorithm == AlgorithmNone {
+		return wrapped, nil
+	}
+
+	kek, ok := u.keks[encryptionKey]
+	if !ok {
+		if u.plugin != nil {
+			return u.plugin.Unwrap(encryptionKey, encryptionAlgorithm, wrapped)
+		}
+		return nil, fmt.Errorf("KEK of encryptionKey(%d) not found", encryptionKey)
+	}
+
+	switch encryptionAlgorithm {
+	case AlgorithmAesKeyWrap:
+		return UnwrapAesKeyWrap(kek, wrapped)
+	case AlgorithmAesGcm:
+		return UnwrapAesGcm(kek, wrapped)
+	default:
+		return nil, fmt.Errorf("encryptionAlgorithm(%d) is not supported", encryptionAlgorithm)
+	}
+}
+
+// default initial value of RFC 3394 2.2.3.1
+var aesKeyWrapIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// WrapAesKeyWrap wraps the key with the KEK by the AES key wrap algorithm (RFC 3394 2.2.1)
+func WrapAesKeyWrap(kek, key []byte) ([]byte, error) {
+	if len(key) < 16 || len(key)%8 != 0 {
+		return nil, fmt.Errorf("key length(%d) should be a multiple of 8 and at least 16", len(key))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	a := make([]byte, 8)
+	copy(a, aesKeyWrapIV)
+	r := make([]byte, len(key))
+	copy(r, key)
+	b := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 0; i < n; i++ {
+			copy(b[:8], a)
+			copy(b[8:], r[i*8:(i+1)*8])
+			block.Encrypt(b, b)
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(b[:8])^t)
+			copy(r[i*8:(i+1)*8], b[8:])
+		}
+	}
+	return append(a, r...), nil
+}
+
+// UnwrapAesKeyWrap unwraps the key with the KEK by the AES key unwrap algorithm (RFC 3394 2.2.2)
+func UnwrapAesKeyWrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, fmt.Errorf("wrapped key length(%d) should be a multiple of 8 and at least 24", len(wrapped))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, len(wrapped)-8)
+	copy(r, wrapped[8:])
+	b := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n - 1; i >= 0; i-- {
+			t := uint64(n*j + i + 1)
+			binary.BigEndian.PutUint64(b[:8], binary.BigEndian.Uint64(a)^t)
+			copy(b[8:], r[i*8:(i+1)*8])
+			block.Decrypt(b, b)
+			copy(a, b[:8])
+			copy(r[i*8:(i+1)*8], b[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(a, aesKeyWrapIV) != 1 {
+		return nil, fmt.Errorf("AES key unwrap integrity check failed")
+	}
+	return r, nil
+}
+
+// WrapAesGcm encrypts the key with the KEK by AES-GCM, and returns nonce || cipher text || tag
+func WrapAesGcm(kek, key, nonce []byte) ([]byte, error) {
+	if len(nonce) != gcmNonceLen {
+		return nil, fmt.Errorf("nonce length(%d) should be %d", len(nonce), gcmNonceLen)
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(append([]byte{}, nonce...), nonce, key, nil), nil
+}
+
+// UnwrapAesGcm decrypts the nonce || cipher text || tag produced by WrapAesGcm
+func UnwrapAesGcm(kek, wrapped []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcmNonceLen+aead.Overhead() {
+		return nil, fmt.Errorf("wrapped key length(%d) is too short", len(wrapped))
+	}
+	return aead.Open(nil, wrapped[:gcmNonceLen], wrapped[gcmNonceLen:], nil)
+}
@@ -0,0 +1,62 @@
+package keywrap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestAesKeyWrap(t *testing.T) {
+	// RFC 3394 4.1 Wrap 128 bits of Key Data with a 128-bit KEK
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F")
+	key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF")
+	expected, _ := hex.DecodeString("1FA68B0A8112B447AEF34BD8FB5A7B829D3E862371D2CFE5")
+
+	wrapped, err := WrapAesKeyWrap(kek, key)
+	if err != nil {
+		t.Fatalf("WrapAesKeyWrap err[%+v]", err)
+	}
+	if !bytes.Equal(wrapped, expected) {
+		t.Errorf("wrapped[%x], expected[%x]", wrapped, expected)
+	}
+
+	unwrapped, err := New(map[int32][]byte{1: kek}, nil).Unwrap(1, AlgorithmAesKeyWrap, expected)
+	if err != nil {
+		t.Fatalf("Unwrap err[%+v]", err)
+	}
+	if !bytes.Equal(unwrapped, key) {
+		t.Errorf("unwrapped[%x], expected[%x]", unwrapped, key)
+	}
+
+	expected[0] ^= 0x01
+	if _, err = UnwrapAesKeyWrap(kek, expected); err == nil {
+		t.Errorf("UnwrapAesKeyWrap of a modified key should fail")
+	}
+}
+
+func TestAesGcm(t *testing.T) {
+	kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	key, _ := hex.DecodeString("8baf473f2f8fd09487cccbd7097c6862")
+	nonce, _ := hex.DecodeString("000000000000000000000001")
+
+	wrapped, err := WrapAesGcm(kek, key, nonce)
+	if err != nil {
+		t.Fatalf("WrapAesGcm err[%+v]", err)
+	}
+	unwrapper := New(map[int32][]byte{7: kek}, nil)
+	unwrapped, err := unwrapper.Unwrap(7, AlgorithmAesGcm, wrapped)
+	if err != nil {
+		t.Fatalf("Unwrap err[%+v]", err)
+	}
+	if !bytes.Equal(unwrapped, key) {
+		t.Errorf("unwrapped[%x], expected[%x]", unwrapped, key)
+	}
+
+	if _, err = unwrapper.Unwrap(8, AlgorithmAesGcm, wrapped); err == nil {
+		t.Errorf("Unwrap with an unknown KEK should fail")
+	}
+	wrapped[len(wrapped)-1] ^= 0x01
+	if _, err = unwrapper.Unwrap(7, AlgorithmAesGcm, wrapped); err == nil {
+		t.Errorf("Unwrap of a modified key should fail")
+	}
+}
@@ -0,0 +1,73 @@
+package keywrap
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+const defaultPluginTimeout = 5 * time.Second
+
+// PluginRequest is written to the stdin of the KMS plugin
+type PluginRequest struct {
+	EncryptionKey       int32  `json:"encryptionKey"`
+	EncryptionAlgorithm int32  `json:"encryptionAlgorithm"`
+	Value               string `json:"value"` // hex encoded wrapped key
+}
+
+// PluginResponse is read from the stdout of the KMS plugin
+type PluginResponse struct {
+	Value string `json:"value"` // hex encoded unwrapped key
+}
+
+// pluginUnwrapper runs an external command, so the KEKs can stay in a KMS
+type pluginUnwrapper struct {
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+// NewPlugin creates the Unwrapper running the KMS plugin command for each key
+func NewPlugin(command string, args []string, timeout time.Duration) Unwrapper {
+	if timeout <= 0 {
+		timeout = defaultPluginTimeout
+	}
+	return &pluginUnwrapper{
+		command: command,
+		args:    args,
+		timeout: timeout,
+	}
+}
+
+func (p *pluginUnwrapper) Unwrap(encryptionKey, encryptionAlgorithm int32, wrapped []byte) ([]byte, error) {
+	req, err := json.Marshal(PluginRequest{
+		EncryptionKey:       encryptionKey,
+		EncryptionAlgorithm: encryptionAlgorithm,
+		Value:               hex.EncodeToString(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...)
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("KMS plugin error: %+v, stderr: %s", err, stderr.String())
+	}
+
+	var rsp PluginResponse
+	if err = json.Unmarshal(stdout.Bytes(), &rsp); err != nil {
+		return nil, fmt.Errorf("KMS plugin response error: %+v", err)
+	}
+	return hex.DecodeString(rsp.Value)
+}
@@ -2,18 +2,26 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"runtime/debug"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/free5gc/openapi"
 	udm_context "github.com/free5gc/udm/internal/context"
 	"github.com/free5gc/udm/internal/logger"
+	"github.com/free5gc/udm/internal/metrics"
 	"github.com/free5gc/udm/internal/sbi"
 	"github.com/free5gc/udm/internal/sbi/consumer"
 	"github.com/free5gc/udm/internal/sbi/processor"
+	"github.com/free5gc/udm/internal/tracing"
+	"github.com/free5gc/udm/internal/util"
 	"github.com/free5gc/udm/pkg/app"
 	"github.com/free5gc/udm/pkg/factory"
 )
@@ -28,9 +36,18 @@ type UdmApp struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
-	sbiServer *sbi.Server
-	consumer  *consumer.Consumer
-	processor *processor.Processor
+	sbiServer     *sbi.Server
+	metricsServer *http.Server
+	consumer      *consumer.Consumer
+	processor     *processor.Processor
+
+	// flushes the spans and stops the tracing exporter
+	shutdownTracing func(context.Context) error
+
+	// serializes the config reloads
+	reloadMu sync.Mutex
+	// cancels the re-registration started by the previous reload
+	reregisterCancel context.CancelFunc
 }
 
 func NewApp(ctx context.Context, cfg *factory.Config, tlsKeyLogPath string) (*UdmApp, error) {
@@ -42,12 +59,35 @@ func NewApp(ctx context.Context, cfg *factory.Config, tlsKeyLogPath string) (*Ud
 	udm.SetLogLevel(cfg.GetLogLevel())
 	udm.SetReportCaller(cfg.GetLogReportCaller())
 	udm_context.Init()
+	// the client certificate is set on the transport before it is wrapped
+	if sbiTls := cfg.GetSbiTls(); sbiTls != nil && sbiTls.Client != nil {
+		err := util.PresentClientCertificate(openapi.GetHttpsClient(), sbiTls.Client.Pem, sbiTls.Client.Key)
+		if err != nil {
+			return udm, err
+		}
+	}
+	metrics.InstrumentUdrClients(openapi.GetHttpClient(), openapi.GetHttpsClient())
+	tracing.InstrumentClients(openapi.GetHttpClient(), openapi.GetHttpsClient())
+	consumer.InstrumentSbiHeaders(openapi.GetHttpClient(), openapi.GetHttpsClient())
+	if scpUri := udm_context.GetSelf().ScpUri; scpUri != "" {
+		if err := consumer.RouteThroughScp(scpUri, openapi.GetHttpClient(), openapi.GetHttpsClient()); err != nil {
+			return udm, err
+		}
+		logger.InitLog.Infof("Requests are sent through the SCP [%s]", scpUri)
+	}
+
+	shutdownTracing, err := tracing.Init(cfg.GetTracing(), udm_context.GetSelf().NfId)
+	if err != nil {
+		return udm, err
+	}
+	udm.shutdownTracing = shutdownTracing
 
 	consumer, err := consumer.NewConsumer(udm)
 	if err != nil {
 		return udm, err
 	}
 	udm.consumer = consumer
+	consumer.InstrumentUdrFallback(openapi.GetHttpClient(), openapi.GetHttpsClient())
 
 	processor, err_p := processor.NewProcessor(udm)
 	if err_p != nil {
@@ -106,19 +146,112 @@ func (a *UdmApp) SetReportCaller(reportCaller bool) {
 	logger.Log.SetReportCaller(reportCaller)
 }
 
+// ReloadConfig reads the config file again, and applies the SUCI profiles, the logger settings, the
+// NRF URI and the service name list to the running UDM. The NF profile is registered again if the
+// NRF URI or the service name list has changed. An invalid config is rejected and the running UDM
+// is not affected.
+func (a *UdmApp) ReloadConfig() error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	logger.CfgLog.Infof("Reloading config...")
+	cfg, err := factory.ReadConfig(a.cfg.GetConfigPath())
+	if err != nil {
+		return err
+	}
+	if cfg.Configuration.DisableNrf != a.udmCtx.NrfDisabled {
+		return fmt.Errorf("disableNrf cannot be changed without restart")
+	}
+	suciProfiles, err := udm_context.NewSuciProfiles(cfg.Configuration.SuciProfiles)
+	if err != nil {
+		return fmt.Errorf("SuciProfile error: %+v", err)
+	}
+
+	a.cfg.RLock()
+	reregister := a.cfg.Configuration.NrfUri != cfg.Configuration.NrfUri ||
+		!slices.Equal(a.cfg.Configuration.ServiceNameList, cfg.Configuration.ServiceNameList)
+	a.cfg.RUnlock()
+
+	a.cfg.Reload(cfg)
+	a.SetLogEnable(cfg.GetLogEnable())
+	a.SetLogLevel(cfg.GetLogLevel())
+	a.SetReportCaller(cfg.GetLogReportCaller())
+	a.udmCtx.Reload(cfg.Configuration.NrfUri, suciProfiles, cfg.Configuration.ServiceNameList, a.cfg.GetVersion())
+	logger.CfgLog.Infof("Config reloaded: %d SuciProfile(s)", len(suciProfiles))
+
+	if reregister && !a.udmCtx.NrfDisabled {
+		a.reregister()
+	}
+	return nil
+}
+
+// reregister registers the NF profile to the NRF again in background, retrying until it succeeds
+func (a *UdmApp) reregister() {
+	if a.reregisterCancel != nil {
+		a.reregisterCancel()
+	}
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.reregisterCancel = cancel
+
+	a.wg.Add(1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				// Print stack for panic to log. Fatalf() will let program exit.
+				logger.MainLog.Fatalf("panic: %v\n%s", p, string(debug.Stack()))
+			}
+			a.wg.Done()
+		}()
+
+		_, nfId, err := a.Consumer().RegisterNFInstance(ctx)
+		if err != nil {
+			logger.MainLog.Errorf("Register NF instance after reload Error[%+v]", err)
+			return
+		}
+		if nfId != "" {
+			a.udmCtx.NfId = nfId
+		}
+		logger.MainLog.Infof("NF profile is registered again after reload")
+	}()
+}
+
 func (a *UdmApp) Start() {
 	logger.InitLog.Infoln("Server started")
 
 	a.wg.Add(1)
 	go a.listenShutdownEvent()
 
-	if err := a.sbiServer.Run(context.Background(), &a.wg); err != nil {
+	if addr := a.cfg.GetMetricsBindingAddr(); addr != "" {
+		a.metricsServer = metrics.NewServer(addr)
+		a.wg.Add(1)
+		go a.runMetricsServer()
+	}
+
+	traceCtx, span := tracing.Start(context.Background(), "Start")
+	if err := a.sbiServer.Run(traceCtx, &a.wg); err != nil {
 		logger.MainLog.Fatalf("Run SBI server failed: %+v", err)
 	}
+	span.End()
 
 	a.WaitRoutineStopped()
 }
 
+func (a *UdmApp) runMetricsServer() {
+	defer func() {
+		if p := recover(); p != nil {
+			// Print stack for panic to log. Fatalf() will let program exit.
+			logger.MainLog.Fatalf("panic: %v\n%s", p, string(debug.Stack()))
+		}
+		a.wg.Done()
+	}()
+
+	logger.MainLog.Infof("Start metrics server (listen on %s)", a.metricsServer.Addr)
+	if err := a.metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.MainLog.Errorf("Metrics server error: %v", err)
+	}
+	logger.MainLog.Infof("Metrics server (listen on %s) stopped", a.metricsServer.Addr)
+}
+
 func (a *UdmApp) listenShutdownEvent() {
 	defer func() {
 		if p := recover(); p != nil {
@@ -142,20 +275,61 @@ func (a *UdmApp) Terminate() {
 	a.cancel()
 }
 
+// terminateProcedure shuts the UDM down gracefully: the UDM turns not ready and is marked
+// UNDISCOVERABLE in the NRF, serves the in-flight requests for the drain period, sends the pending
+// notifications, deregisters from the NRF and then stops the SBI server
 func (a *UdmApp) terminateProcedure() {
 	logger.MainLog.Infof("Terminating UDM...")
-	a.CallServerStop()
+	shutdown := a.cfg.GetShutdown()
+	if a.sbiServer != nil {
+		a.sbiServer.Drain()
+	}
+
+	if !a.udmCtx.NrfDisabled {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdown.NrfTimeout)
+		if err := a.Consumer().SendNFStatusUndiscoverable(ctx); err != nil {
+			logger.MainLog.Errorf("Update NF status to UNDISCOVERABLE Error[%+v]", err)
+		}
+		cancel()
+	}
+
+	logger.MainLog.Infof("Draining requests for %s", shutdown.DrainPeriod)
+	time.Sleep(shutdown.DrainPeriod)
+
+	if !a.processor.WaitNotifications(shutdown.NotificationTimeout) {
+		logger.MainLog.Warnf("Pending notifications are not sent in %s", shutdown.NotificationTimeout)
+	}
 
 	// deregister with NRF
-	problemDetails, err := a.Consumer().SendDeregisterNFInstance()
-	if problemDetails != nil {
-		logger.MainLog.Errorf("Deregister NF instance Failed Problem[%+v]", problemDetails)
-	} else if err != nil {
-		logger.MainLog.Errorf("Deregister NF instance Error[%+v]", err)
-	} else {
-		logger.MainLog.Infof("Deregister from NRF successfully")
+	if !a.udmCtx.NrfDisabled {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdown.NrfTimeout)
+		problemDetails, err := a.Consumer().SendDeregisterNFInstance(ctx)
+		cancel()
+		if problemDetails != nil {
+			logger.MainLog.Errorf("Deregister NF instance Failed Problem[%+v]", problemDetails)
+		} else if err != nil {
+			logger.MainLog.Errorf("Deregister NF instance Error[%+v]", err)
+		} else {
+			logger.MainLog.Infof("Deregister from NRF successfully")
+		}
 	}
+
+	a.CallServerStop()
 	logger.MainLog.Infof("UDM SBI Server terminated")
+
+	if a.metricsServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdown.ServerTimeout)
+		if err := a.metricsServer.Shutdown(ctx); err != nil {
+			logger.MainLog.Errorf("Could not close metrics server: %+v", err)
+		}
+		cancel()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdown.ServerTimeout)
+	if err := a.shutdownTracing(ctx); err != nil {
+		logger.MainLog.Errorf("Could not flush the spans: %+v", err)
+	}
+	cancel()
 }
 
 func (a *UdmApp) WaitRoutineStopped() {
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/free5gc/openapi"
+	udm_context "github.com/free5gc/udm/internal/context"
+	"github.com/free5gc/udm/internal/sbi/consumer"
+	"github.com/free5gc/udm/pkg/factory"
+	"github.com/free5gc/udm/pkg/suci"
+)
+
+// the config of the reload tests, with the NRF URI, the services and the protection scheme of the
+// SUCI profile to reload
+const reloadTestConfig = `info:
+  version: 1.0.3
+configuration:
+  sbi:
+    scheme: http
+    registerIPv4: 127.0.0.3
+    bindingIPv4: 127.0.0.3
+    port: 8000
+  serviceNameList: [%s]
+  nrfUri: %s
+  SuciProfile:
+    - ProtectionScheme: %s
+      RoutingIndicator: "0"
+      PrivateKey: c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d
+      PublicKey: 5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650
+logger:
+  enable: true
+  level: info
+`
+
+// closeRecorder is the deconcealer of the profile replaced by a reload
+type closeRecorder struct {
+	closed bool
+}
+
+func (d *closeRecorder) SharedKey([]byte) ([]byte, error) { return nil, fmt.Errorf("not implemented") }
+func (d *closeRecorder) Close() error                     { d.closed = true; return nil }
+
+func TestReloadConfig(t *testing.T) {
+	defer gock.Off() // Flush pending mocks after test execution
+
+	gock.InterceptClient(openapi.GetHttpClient())
+	defer gock.RestoreClient(openapi.GetHttpClient())
+
+	cfgPath := filepath.Join(t.TempDir(), "udmcfg.yaml")
+	writeConfig := func(serviceNames, nrfUri, protectionScheme string) {
+		require.NoError(t, os.WriteFile(cfgPath,
+			[]byte(fmt.Sprintf(reloadTestConfig, serviceNames, nrfUri, protectionScheme)), 0o600))
+	}
+	writeConfig("nudm-sdm", "http://127.0.0.10:8000", "1")
+	cfg, err := factory.ReadConfig(cfgPath)
+	require.NoError(t, err)
+
+	replaced := &closeRecorder{}
+	udm := &UdmApp{
+		cfg: cfg,
+		udmCtx: &udm_context.UDMContext{
+			NfId:         "1",
+			NrfUri:       "http://127.0.0.10:8000",
+			SuciProfiles: map[int]suci.SuciProfile{1: {HNPublicKeyID: 1, Deconcealer: replaced}},
+		},
+	}
+	udm.ctx, udm.cancel = context.WithCancel(context.Background())
+	defer udm.cancel()
+	udm.consumer, err = consumer.NewConsumer(udm)
+	require.NoError(t, err)
+
+	// a profile set with an unsupported scheme is rejected as a whole
+	writeConfig("nudm-sdm", "http://127.0.0.11:8000", "3")
+	require.Error(t, udm.ReloadConfig())
+	require.Equal(t, "http://127.0.0.10:8000", udm.udmCtx.GetNrfUri())
+	require.Equal(t, "http://127.0.0.10:8000", cfg.Configuration.NrfUri)
+	require.Same(t, replaced, udm.udmCtx.GetSuciProfiles()[1].Deconcealer)
+	require.False(t, replaced.closed)
+
+	// the profiles are replaced without registering again if the NF profile is unchanged
+	writeConfig("nudm-sdm", "http://127.0.0.10:8000", "1")
+	require.NoError(t, udm.ReloadConfig())
+	udm.wg.Wait()
+	require.True(t, replaced.closed)
+	require.NotSame(t, replaced, udm.udmCtx.GetSuciProfiles()[1].Deconcealer)
+
+	// the UDM registers to the new NRF
+	gock.New("http://127.0.0.11:8000").
+		Put("/nnrf-nfm/v1/nf-instances/1").
+		Reply(200).
+		JSON(map[string]string{})
+	writeConfig("nudm-sdm", "http://127.0.0.11:8000", "1")
+	require.NoError(t, udm.ReloadConfig())
+	udm.wg.Wait()
+	require.Equal(t, "http://127.0.0.11:8000", udm.udmCtx.GetNrfUri())
+	require.True(t, gock.IsDone())
+
+	// the UDM registers the new services
+	gock.New("http://127.0.0.11:8000").
+		Put("/nnrf-nfm/v1/nf-instances/1").
+		BodyString(`"serviceName":"nudm-uecm"`).
+		Reply(200).
+		JSON(map[string]string{})
+	writeConfig("nudm-sdm, nudm-uecm", "http://127.0.0.11:8000", "1")
+	require.NoError(t, udm.ReloadConfig())
+	udm.wg.Wait()
+	require.Len(t, udm.udmCtx.GetNfServices(), 2)
+	require.True(t, gock.IsDone())
+}
@@ -0,0 +1,173 @@
+package suci
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// FromSupi conceals the SUPI (imsi-<mcc><mnc><msin> or nai-<username>@<realm>) into a SUCI as the UE
+// does (TS 33.501 6.12.2). The IMSI does not carry the length of its MNC, so mncLen (2 or 3) is given
+// by the caller, e.g. from the PLMN ID of the home network; it is ignored for a NAI. Profile A and B
+// use a fresh ephemeral key pair; the ephemeral public key of Profile B is compressed if hnPublicKey
+// is compressed, otherwise uncompressed.
+func FromSupi(supi string, mncLen int, scheme, hnPublicKey string, hnKeyID int,
+	routingIndicator string,
+) (string, error) {
+	if !isRoutingIndicator(routingIndicator) {
+		return "", fmt.Errorf("Invalid routing indicator [%s]", routingIndicator)
+	}
+
+	var supiType, homeNetworkID string
+	var plainText []byte
+	var nullSchemeOutput string
+	switch {
+	case strings.HasPrefix(supi, PrefixIMSI):
+		imsi := strings.TrimPrefix(supi, PrefixIMSI)
+		if mncLen != 2 && mncLen != 3 {
+			return "", fmt.Errorf("Invalid MNC length [%d]", mncLen)
+		}
+		if len(imsi) <= 3+mncLen || strings.Trim(imsi, "0123456789") != "" {
+			return "", fmt.Errorf("Invalid IMSI [%s]", imsi)
+		}
+		supiType = SupiTypeIMSI
+		homeNetworkID = imsi[:3] + "-" + imsi[3:3+mncLen]
+		nullSchemeOutput = imsi[3+mncLen:]
+
+		// MSIN in BCD, filled with 0xf when the number of digits is odd
+		msin := nullSchemeOutput
+		if len(msin)%2 == 1 {
+			msin += "f"
+		}
+		bcd, err := hex.DecodeString(msin)
+		if err != nil {
+			return "", err
+		}
+		plainText = swapNibbles(bcd)
+	case strings.HasPrefix(supi, PrefixNAI):
+		username, realm, found := strings.Cut(strings.TrimPrefix(supi, PrefixNAI), "@")
+		if !found || username == "" || realm == "" {
+			return "", fmt.Errorf("Invalid NAI [%s]", supi)
+		}
+		supiType = SupiTypeNAI
+		homeNetworkID = realm
+		nullSchemeOutput = username
+		plainText = []byte(username)
+	default:
+		return "", fmt.Errorf("Unknown supiPrefix [%s]", supi)
+	}
+
+	var schemeOutput string
+	switch scheme {
+	case NullScheme:
+		hnKeyID = 0
+		schemeOutput = nullSchemeOutput
+	case ProfileAScheme, ProfileBScheme:
+		if hnKeyID < MinHNPublicKeyID || hnKeyID > MaxHNPublicKeyID {
+			return "", fmt.Errorf("HNPublicKeyID(%d) out of range [%d, %d]",
+				hnKeyID, MinHNPublicKeyID, MaxHNPublicKeyID)
+		}
+		hnPub, err := hex.DecodeString(hnPublicKey)
+		if err != nil {
+			return "", fmt.Errorf("Decode hnPublicKey error: %+v", err)
+		}
+		var output []byte
+		if scheme == ProfileAScheme {
+			output, err = concealProfileA(plainText, hnPub)
+		} else {
+			output, err = concealProfileB(plainText, hnPub)
+		}
+		if err != nil {
+			return "", err
+		}
+		schemeOutput = hex.EncodeToString(output)
+	default:
+		return "", fmt.Errorf("Protect Scheme (%s) is not supported", scheme)
+	}
+
+	return strings.Join([]string{
+		PrefixSUCI, supiType, homeNetworkID, routingIndicator, scheme, strconv.Itoa(hnKeyID), schemeOutput,
+	}, "-"), nil
+}
+
+// concealProfileA returns the scheme output (ephemeral public key || cipher text || MAC tag) of Profile A
+func concealProfileA(plainText, hnPub []byte) ([]byte, error) {
+	if len(hnPub) != curve25519.PointSize {
+		return nil, fmt.Errorf("hnPublicKey length(%d) should be %d", len(hnPub), curve25519.PointSize)
+	}
+
+	ephPriv := make([]byte, curve25519.ScalarSize)
+	if _, err := rand.Read(ephPriv); err != nil {
+		return nil, err
+	}
+	ephPub, err := curve25519.X25519(ephPriv, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	sharedKey, err := curve25519.X25519(ephPriv, hnPub)
+	if err != nil {
+		return nil, err
+	}
+
+	kdfKey := AnsiX963KDF(sharedKey, ephPub, ProfileAEncKeyLen, ProfileAMacKeyLen, ProfileAHashLen)
+	encKey := kdfKey[:ProfileAEncKeyLen]
+	icb := kdfKey[ProfileAEncKeyLen : ProfileAEncKeyLen+ProfileAIcbLen]
+	macKey := kdfKey[len(kdfKey)-ProfileAMacKeyLen:]
+
+	cipherText := Aes128ctr(plainText, encKey, icb)
+	macTag := HmacSha256(cipherText, macKey, ProfileAMacLen)
+	return append(append(ephPub, cipherText...), macTag...), nil
+}
+
+// concealProfileB returns the scheme output (ephemeral public key || cipher text || MAC tag) of Profile B
+func concealProfileB(plainText, hnPub []byte) ([]byte, error) {
+	curve := elliptic.P256()
+	var hnX, hnY *big.Int
+	compressed := false
+	switch {
+	case len(hnPub) == 33 && (hnPub[0] == 0x02 || hnPub[0] == 0x03):
+		compressed = true
+		hnX, hnY = uncompressKey(hnPub, nil)
+		if hnX == nil || hnY == nil {
+			return nil, fmt.Errorf("Key uncompression error")
+		}
+	case len(hnPub) == 65 && hnPub[0] == 0x04:
+		hnX = new(big.Int).SetBytes(hnPub[1:33])
+		hnY = new(big.Int).SetBytes(hnPub[33:])
+	default:
+		return nil, fmt.Errorf("hnPublicKey should be a compressed or uncompressed secp256r1 point")
+	}
+	if err := checkOnCurve(curve, hnX, hnY); err != nil {
+		return nil, err
+	}
+
+	ephPriv, ephX, ephY, err := elliptic.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	//nolint:staticcheck // the SUCI helpers work on the raw points
+	ephPub := elliptic.Marshal(curve, ephX, ephY)
+	ephPubCompressed := CompressKey(append([]byte{}, ephPub...), ephY)
+	if compressed {
+		ephPub = ephPubCompressed
+	}
+
+	sharedX, _ := curve.ScalarMult(hnX, hnY, ephPriv)
+	sharedKey := sharedX.FillBytes(make([]byte, 32))
+
+	// the KDF always takes the compressed ephemeral public key, the same as profileB()
+	kdfKey := AnsiX963KDF(sharedKey, ephPubCompressed, ProfileBEncKeyLen, ProfileBMacKeyLen, ProfileBHashLen)
+	encKey := kdfKey[:ProfileBEncKeyLen]
+	icb := kdfKey[ProfileBEncKeyLen : ProfileBEncKeyLen+ProfileBIcbLen]
+	macKey := kdfKey[len(kdfKey)-ProfileBMacKeyLen:]
+
+	cipherText := Aes128ctr(plainText, encKey, icb)
+	macTag := HmacSha256(cipherText, macKey, ProfileBMacLen)
+	return append(append(ephPub, cipherText...), macTag...), nil
+}
@@ -0,0 +1,143 @@
+package suci
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestFromSupiRoundTrip(t *testing.T) {
+	suciProfiles, err := ProfileMap([]SuciProfile{
+		{
+			HNPublicKeyID:    1,
+			ProtectionScheme: ProfileAScheme,
+			PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+			PublicKey:        "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650",
+		},
+		{
+			HNPublicKeyID:    2,
+			ProtectionScheme: ProfileBScheme,
+			PrivateKey:       "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA",
+			PublicKey:        "0272DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD1",
+		},
+	})
+	if err != nil {
+		t.Fatalf("ProfileMap fail: %+v", err)
+	}
+	uncompressedProfileBKey := "0472DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD1" +
+		"5A7DED52FCBB097A4ED250E036C7B9C8C7004C4EEDC4F068CD7BF8D3F900E3B4"
+
+	testCases := []struct {
+		name        string
+		scheme      string
+		hnPublicKey string
+		hnKeyID     int
+	}{
+		{"null scheme", NullScheme, "", 0},
+		{"profile A", ProfileAScheme, suciProfiles[1].PublicKey, 1},
+		{"profile B compressed", ProfileBScheme, suciProfiles[2].PublicKey, 2},
+		{"profile B uncompressed", ProfileBScheme, uncompressedProfileBKey, 2},
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for _, tc := range testCases {
+		for i := 0; i < 50; i++ {
+			supi := randomSupi(r)
+			routingIndicator := fmt.Sprintf("%d", r.Intn(10000))
+			suci, err := FromSupi(supi, 2, tc.scheme, tc.hnPublicKey, tc.hnKeyID, routingIndicator)
+			if err != nil {
+				t.Fatalf("%s: FromSupi(%s) err[%+v]", tc.name, supi, err)
+			}
+			result, err := ToSupi(suci, suciProfiles)
+			if err != nil {
+				t.Fatalf("%s: ToSupi(%s) err[%+v]", tc.name, suci, err)
+			}
+			if result != supi {
+				t.Errorf("%s: supi[%s], expected[%s], suci[%s]", tc.name, result, supi, suci)
+			}
+		}
+	}
+}
+
+func TestFromSupiFormat(t *testing.T) {
+	testCases := []struct {
+		supi        string
+		mncLen      int
+		scheme      string
+		expectedErr error
+		expected    string
+	}{
+		{
+			supi:     "imsi-2089300007487",
+			mncLen:   2,
+			scheme:   NullScheme,
+			expected: "suci-0-208-93-0-0-0-00007487",
+		},
+		{
+			supi:     "imsi-310410123456789",
+			mncLen:   3,
+			scheme:   NullScheme,
+			expected: "suci-0-310-410-0-0-0-123456789",
+		},
+		{
+			supi:     "imsi-405854123456789",
+			mncLen:   3,
+			scheme:   NullScheme,
+			expected: "suci-0-405-854-0-0-0-123456789",
+		},
+		{
+			supi:     "imsi-310260123456789",
+			mncLen:   2,
+			scheme:   NullScheme,
+			expected: "suci-0-310-26-0-0-0-0123456789",
+		},
+		{
+			supi:     "nai-alice@corp-net.example.com",
+			scheme:   NullScheme,
+			expected: "suci-1-corp-net.example.com-0-0-0-alice",
+		},
+		{
+			supi:        "imsi-2089300007487",
+			mncLen:      2,
+			scheme:      ProfileAScheme,
+			expectedErr: fmt.Errorf("HNPublicKeyID(0) out of range [1, 255]"),
+		},
+		{
+			supi:        "imsi-2089300007487",
+			mncLen:      0,
+			scheme:      NullScheme,
+			expectedErr: fmt.Errorf("Invalid MNC length [0]"),
+		},
+		{
+			supi:        "nai-alice",
+			scheme:      NullScheme,
+			expectedErr: fmt.Errorf("Invalid NAI [nai-alice]"),
+		},
+	}
+	for i, tc := range testCases {
+		suci, err := FromSupi(tc.supi, tc.mncLen, tc.scheme, "", 0, "0")
+		if err != nil {
+			if tc.expectedErr == nil || err.Error() != tc.expectedErr.Error() {
+				t.Errorf("TC%d fail: err[%s], expected[%v]\n", i, err, tc.expectedErr)
+			}
+		} else if tc.expectedErr != nil {
+			t.Errorf("TC%d fail: expected err[%s]\n", i, tc.expectedErr)
+		} else if suci != tc.expected {
+			t.Errorf("TC%d fail: suci[%s], expected[%s]\n", i, suci, tc.expected)
+		}
+	}
+}
+
+func randomSupi(r *rand.Rand) string {
+	const usernameChars = "abcdefghijklmnopqrstuvwxyz0123456789.-_"
+	if r.Intn(2) == 0 {
+		// MCC 208, MNC 93 and a 9 or 10 digit MSIN
+		msin := fmt.Sprintf("%010d", r.Int63n(10000000000))
+		return "imsi-20893" + msin[:9+r.Intn(2)]
+	}
+	username := make([]byte, 1+r.Intn(32))
+	for i := range username {
+		username[i] = usernameChars[r.Intn(len(usernameChars))]
+	}
+	return "nai-" + string(username) + "@example.com"
+}
@@ -0,0 +1,92 @@
+package suci
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+const (
+	BackendSoftware = "software"
+	BackendPkcs11   = "pkcs11"
+)
+
+// Deconcealer performs the ECDH step of the ECIES scheme (TS 33.501 C.3.3) with the home network
+// private key. The rest of the de-concealment is done in software, so the private key never needs
+// to leave the backend.
+type Deconcealer interface {
+	// SharedKey returns the shared secret of the home network private key and the ephemeral public
+	// key of the UE: the X25519 output for Profile A, and the x-coordinate for Profile B, where the
+	// ephemeral public key is always an uncompressed point.
+	SharedKey(ephemeralPublicKey []byte) ([]byte, error)
+	// Close releases the resources of the backend, e.g. the PKCS#11 session, once the profile is
+	// replaced. SharedKey fails after Close.
+	Close() error
+}
+
+// Pkcs11Config locates the home network private key in a PKCS#11 token
+type Pkcs11Config struct {
+	// Module is the path of the PKCS#11 library, e.g. /usr/lib/softhsm/libsofthsm2.so
+	Module     string `yaml:"Module,omitempty"`
+	TokenLabel string `yaml:"TokenLabel,omitempty"`
+	Pin        string `yaml:"Pin,omitempty"`
+	KeyLabel   string `yaml:"KeyLabel,omitempty"`
+}
+
+// NewDeconcealer creates the de-concealment backend configured in the profile
+func NewDeconcealer(profile SuciProfile) (Deconcealer, error) {
+	switch profile.Backend {
+	case "", BackendSoftware:
+		return newSoftwareDeconcealer(profile.ProtectionScheme, profile.PrivateKey)
+	case BackendPkcs11:
+		if profile.Pkcs11 == nil {
+			return nil, fmt.Errorf("Pkcs11 is required for the %s backend", BackendPkcs11)
+		}
+		return newPkcs11Deconcealer(profile.ProtectionScheme, profile.Pkcs11)
+	default:
+		return nil, fmt.Errorf("SUCI backend (%s) is not supported", profile.Backend)
+	}
+}
+
+// softwareDeconcealer holds the home network private key in memory
+type softwareDeconcealer struct {
+	scheme     string
+	privateKey []byte
+}
+
+func newSoftwareDeconcealer(scheme, privateKey string) (*softwareDeconcealer, error) {
+	priv, err := hex.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("Decode PrivateKey error: %+v", err)
+	}
+	if scheme != ProfileAScheme && scheme != ProfileBScheme {
+		return nil, fmt.Errorf("Protect Scheme (%s) is not supported", scheme)
+	}
+	return &softwareDeconcealer{
+		scheme:     scheme,
+		privateKey: priv,
+	}, nil
+}
+
+// Close does nothing, the private key is released with the profile
+func (d *softwareDeconcealer) Close() error {
+	return nil
+}
+
+func (d *softwareDeconcealer) SharedKey(ephemeralPublicKey []byte) ([]byte, error) {
+	if d.scheme == ProfileAScheme {
+		return curve25519.X25519(d.privateKey, ephemeralPublicKey)
+	}
+
+	if len(ephemeralPublicKey) != 65 || ephemeralPublicKey[0] != 0x04 {
+		return nil, fmt.Errorf("ephemeral public key should be an uncompressed point")
+	}
+	x := new(big.Int).SetBytes(ephemeralPublicKey[1:33])
+	y := new(big.Int).SetBytes(ephemeralPublicKey[33:])
+	sharedX, _ := elliptic.P256().ScalarMult(x, y, d.privateKey)
+	// the shared secret is the fixed-length x-coordinate (SEC 1 2.3.5)
+	return sharedX.FillBytes(make([]byte, 32)), nil
+}
@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package suci
+
+import "fmt"
+
+func newPkcs11Deconcealer(scheme string, cfg *Pkcs11Config) (Deconcealer, error) {
+	return nil, fmt.Errorf("the %s backend requires a build with cgo", BackendPkcs11)
+}
@@ -0,0 +1,167 @@
+//go:build cgo
+
+package suci
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+
+	"github.com/free5gc/udm/internal/logger"
+)
+
+// pkcs11Deconcealer derives the shared secret inside a PKCS#11 token with CKM_ECDH1_DERIVE, so the
+// home network private key can be kept non-exportable (e.g. SoftHSM for local tests)
+type pkcs11Deconcealer struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	key     pkcs11.ObjectHandle
+	// a PKCS#11 session must not be used concurrently
+	mu     sync.Mutex
+	closed bool
+}
+
+func newPkcs11Deconcealer(scheme string, cfg *Pkcs11Config) (Deconcealer, error) {
+	if scheme != ProfileAScheme && scheme != ProfileBScheme {
+		return nil, fmt.Errorf("Protect Scheme (%s) is not supported", scheme)
+	}
+
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("load PKCS#11 module [%s] failed", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil && !isPkcs11Error(err, pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		return nil, fmt.Errorf("PKCS#11 Initialize error: %+v", err)
+	}
+
+	slot, err := findPkcs11Slot(ctx, cfg.TokenLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 OpenSession error: %+v", err)
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, cfg.Pin); err != nil &&
+		!isPkcs11Error(err, pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		closeErr := ctx.CloseSession(session)
+		return nil, errors.Join(fmt.Errorf("PKCS#11 Login error: %+v", err), closeErr)
+	}
+
+	key, err := findPkcs11PrivateKey(ctx, session, cfg.KeyLabel)
+	if err != nil {
+		closeErr := ctx.CloseSession(session)
+		return nil, errors.Join(err, closeErr)
+	}
+
+	return &pkcs11Deconcealer{
+		ctx:     ctx,
+		session: session,
+		key:     key,
+	}, nil
+}
+
+func (d *pkcs11Deconcealer) SharedKey(ephemeralPublicKey []byte) ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, fmt.Errorf("PKCS#11 session is closed")
+	}
+
+	mechanism := []*pkcs11.Mechanism{
+		pkcs11.NewMechanism(pkcs11.CKM_ECDH1_DERIVE,
+			pkcs11.NewECDH1DeriveParams(pkcs11.CKD_NULL, nil, ephemeralPublicKey)),
+	}
+	// the derived secret is a session object which is destroyed after its value is read
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+	}
+
+	secret, err := d.ctx.DeriveKey(d.session, mechanism, d.key, template)
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 DeriveKey error: %+v", err)
+	}
+	defer func() {
+		if destroyErr := d.ctx.DestroyObject(d.session, secret); destroyErr != nil {
+			logger.SuciLog.Warnf("PKCS#11 DestroyObject error: %+v", destroyErr)
+		}
+	}()
+
+	attrs, err := d.ctx.GetAttributeValue(d.session, secret, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("PKCS#11 GetAttributeValue error: %+v", err)
+	}
+	return attrs[0].Value, nil
+}
+
+// Close closes the session once the in-flight derivation is done, and unloads the module. The
+// module is not finalized, as the deconcealers of the other profiles may still use it.
+func (d *pkcs11Deconcealer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil
+	}
+	d.closed = true
+	err := d.ctx.CloseSession(d.session)
+	d.ctx.Destroy()
+	if err != nil {
+		return fmt.Errorf("PKCS#11 CloseSession error: %+v", err)
+	}
+	return nil
+}
+
+func findPkcs11Slot(ctx *pkcs11.Ctx, tokenLabel string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("PKCS#11 GetSlotList error: %+v", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+		if info.Label == tokenLabel {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("PKCS#11 token [%s] not found", tokenLabel)
+}
+
+func findPkcs11PrivateKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, keyLabel string) (
+	pkcs11.ObjectHandle, error,
+) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+	}
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return 0, fmt.Errorf("PKCS#11 FindObjectsInit error: %+v", err)
+	}
+	objects, _, err := ctx.FindObjects(session, 1)
+	if finalErr := ctx.FindObjectsFinal(session); finalErr != nil && err == nil {
+		err = finalErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("PKCS#11 FindObjects error: %+v", err)
+	}
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("PKCS#11 private key [%s] not found", keyLabel)
+	}
+	return objects[0], nil
+}
+
+func isPkcs11Error(err error, code uint) bool {
+	var pkcs11Err pkcs11.Error
+	return errors.As(err, &pkcs11Err) && uint(pkcs11Err) == code
+}
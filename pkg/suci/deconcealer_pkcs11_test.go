@@ -0,0 +1,109 @@
+//go:build cgo
+
+package suci
+
+import (
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/miekg/pkcs11"
+)
+
+// TestPkcs11Deconcealer runs against an initialized SoftHSM token, e.g.
+//
+//	softhsm2-util --init-token --free --label udm --pin 1234 --so-pin 1234
+//	SOFTHSM2_MODULE=/usr/lib/softhsm/libsofthsm2.so SOFTHSM2_TOKEN_LABEL=udm SOFTHSM2_PIN=1234 go test ./pkg/suci
+func TestPkcs11Deconcealer(t *testing.T) {
+	cfg := &Pkcs11Config{
+		Module:     os.Getenv("SOFTHSM2_MODULE"),
+		TokenLabel: os.Getenv("SOFTHSM2_TOKEN_LABEL"),
+		Pin:        os.Getenv("SOFTHSM2_PIN"),
+		KeyLabel:   "udm-suci-profile-b",
+	}
+	if cfg.Module == "" {
+		t.Skip("SOFTHSM2_MODULE is not set")
+	}
+
+	// import the Profile B home network private key of TS 33.501 Annex C.4 as a non-extractable key
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		t.Fatalf("load PKCS#11 module [%s] failed", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil && !isPkcs11Error(err, pkcs11.CKR_CRYPTOKI_ALREADY_INITIALIZED) {
+		t.Fatalf("Initialize err[%+v]", err)
+	}
+	slot, err := findPkcs11Slot(ctx, cfg.TokenLabel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		t.Fatalf("OpenSession err[%+v]", err)
+	}
+	if err = ctx.Login(session, pkcs11.CKU_USER, cfg.Pin); err != nil {
+		t.Fatalf("Login err[%+v]", err)
+	}
+	priv, err := hex.DecodeString("F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// DER encoded OID of secp256r1 (prime256v1)
+	ecParams, err := hex.DecodeString("06082a8648ce3d030107")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ctx.CreateObject(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, ecParams),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, priv),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.KeyLabel),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_DERIVE, true),
+	})
+	if err != nil {
+		t.Fatalf("CreateObject err[%+v]", err)
+	}
+	defer func() {
+		if err = ctx.DestroyObject(session, key); err != nil {
+			t.Errorf("DestroyObject err[%+v]", err)
+		}
+	}()
+
+	deconcealer, err := NewDeconcealer(SuciProfile{
+		ProtectionScheme: ProfileBScheme,
+		Backend:          BackendPkcs11,
+		Pkcs11:           cfg,
+	})
+	if err != nil {
+		t.Fatalf("NewDeconcealer err[%+v]", err)
+	}
+	suciProfiles := map[int]SuciProfile{
+		2: {
+			HNPublicKeyID:    2,
+			ProtectionScheme: ProfileBScheme,
+			Backend:          BackendPkcs11,
+			Deconcealer:      deconcealer,
+		},
+	}
+	supi, err := ToSupi("suci-0-208-93-0-2-2-039aab8376597021e855679a9778ea0b67396e68c66d"+
+		"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d", suciProfiles)
+	if err != nil {
+		t.Fatalf("ToSupi err[%+v]", err)
+	}
+	if supi != "imsi-20893001002086" {
+		t.Errorf("supi[%s], expected[imsi-20893001002086]", supi)
+	}
+
+	// the session is closed with the replaced profile
+	if err = deconcealer.Close(); err != nil {
+		t.Fatalf("Close err[%+v]", err)
+	}
+	if _, err = deconcealer.SharedKey(make([]byte, 65)); err == nil {
+		t.Errorf("SharedKey succeeded after Close")
+	}
+}
@@ -0,0 +1,45 @@
+package suci
+
+import (
+	"testing"
+)
+
+func TestNewDeconcealer(t *testing.T) {
+	profile := SuciProfile{
+		ProtectionScheme: ProfileBScheme,
+		PrivateKey:       "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA",
+		PublicKey:        "0272DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD1",
+	}
+	deconcealer, err := NewDeconcealer(profile)
+	if err != nil {
+		t.Fatalf("NewDeconcealer err[%+v]", err)
+	}
+	profile.Deconcealer = deconcealer
+	// the private key is not used once the backend is created
+	profile.PrivateKey = ""
+
+	suciProfiles, err := ProfileMap([]SuciProfile{profile})
+	if err != nil {
+		t.Fatalf("ProfileMap err[%+v]", err)
+	}
+	supi, err := ToSupi("suci-0-208-93-0-1-1-039aab8376597021e855679a9778ea0b67396e68c66d"+
+		"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d", suciProfiles)
+	if err == nil {
+		t.Errorf("ToSupi with mismatched scheme should fail, got supi[%s]", supi)
+	}
+	supi, err = ToSupi("suci-0-208-93-0-2-1-039aab8376597021e855679a9778ea0b67396e68c66d"+
+		"f32c0f41e9acca2da9b9d146a33fc2716ac7dae96aa30a4d", suciProfiles)
+	if err != nil {
+		t.Fatalf("ToSupi err[%+v]", err)
+	}
+	if supi != "imsi-20893001002086" {
+		t.Errorf("supi[%s], expected[imsi-20893001002086]", supi)
+	}
+
+	if _, err = NewDeconcealer(SuciProfile{ProtectionScheme: ProfileAScheme, Backend: BackendPkcs11}); err == nil {
+		t.Errorf("NewDeconcealer without Pkcs11 should fail")
+	}
+	if _, err = NewDeconcealer(SuciProfile{ProtectionScheme: ProfileAScheme, Backend: "kms"}); err == nil {
+		t.Errorf("NewDeconcealer with unknown backend should fail")
+	}
+}
@@ -0,0 +1,92 @@
+package suci
+
+import (
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// GenerateKeyPair generates a home network key pair for the protection scheme (Profile A or B).
+// The keys are hex encoded, and the public key of Profile B is compressed.
+func GenerateKeyPair(scheme string) (string, string, error) {
+	var priv []byte
+	switch scheme {
+	case ProfileAScheme:
+		priv = make([]byte, curve25519.ScalarSize)
+		if _, err := rand.Read(priv); err != nil {
+			return "", "", err
+		}
+	case ProfileBScheme:
+		var err error
+		if priv, _, _, err = elliptic.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return "", "", err
+		}
+	default:
+		return "", "", fmt.Errorf("Protect Scheme (%s) is not supported", scheme)
+	}
+
+	privateKey := hex.EncodeToString(priv)
+	publicKey, err := PublicKey(scheme, privateKey, true)
+	if err != nil {
+		return "", "", err
+	}
+	return privateKey, publicKey, nil
+}
+
+// PublicKey derives the hex encoded home network public key from the private key. The compressed
+// flag only applies to Profile B.
+func PublicKey(scheme, privateKey string, compressed bool) (string, error) {
+	priv, err := hex.DecodeString(privateKey)
+	if err != nil {
+		return "", fmt.Errorf("Decode PrivateKey error: %+v", err)
+	}
+
+	switch scheme {
+	case ProfileAScheme:
+		if len(priv) != curve25519.ScalarSize {
+			return "", fmt.Errorf("PrivateKey length(%d) should be %d", len(priv), curve25519.ScalarSize)
+		}
+		pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(pub), nil
+	case ProfileBScheme:
+		curve := elliptic.P256()
+		if len(priv) != 32 || new(big.Int).SetBytes(priv).Cmp(curve.Params().N) >= 0 {
+			return "", fmt.Errorf("PrivateKey is not a valid secp256r1 private key")
+		}
+		x, y := curve.ScalarBaseMult(priv)
+		//nolint:staticcheck // the SUCI helpers work on the raw points
+		pub := elliptic.Marshal(curve, x, y)
+		if compressed {
+			pub = CompressKey(pub, y)
+		}
+		return hex.EncodeToString(pub), nil
+	default:
+		return "", fmt.Errorf("Protect Scheme (%s) is not supported", scheme)
+	}
+}
+
+// ValidateKeyPair checks that the PublicKey of the profile is derived from its PrivateKey
+func ValidateKeyPair(profile SuciProfile) error {
+	if profile.ProtectionScheme == NullScheme {
+		return nil
+	}
+
+	// Profile B public keys are configured either compressed(66) or uncompressed(130)
+	compressed := len(profile.PublicKey) != 130
+	publicKey, err := PublicKey(profile.ProtectionScheme, profile.PrivateKey, compressed)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(publicKey, profile.PublicKey) {
+		return fmt.Errorf("PublicKey does not match PrivateKey, expected %s", publicKey)
+	}
+	return nil
+}
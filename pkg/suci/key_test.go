@@ -0,0 +1,76 @@
+package suci
+
+import (
+	"testing"
+)
+
+func TestValidateKeyPair(t *testing.T) {
+	testCases := []struct {
+		profile SuciProfile
+		valid   bool
+	}{
+		{
+			profile: SuciProfile{
+				ProtectionScheme: "1",
+				PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+				PublicKey:        "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650",
+			},
+			valid: true,
+		},
+		{
+			profile: SuciProfile{
+				ProtectionScheme: "2",
+				PrivateKey:       "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA",
+				PublicKey: "0472DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD" +
+					"15A7DED52FCBB097A4ED250E036C7B9C8C7004C4EEDC4F068CD7BF8D3F900E3B4",
+			},
+			valid: true,
+		},
+		{
+			profile: SuciProfile{
+				ProtectionScheme: "2",
+				PrivateKey:       "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA",
+				PublicKey:        "0272DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD1",
+			},
+			valid: true,
+		},
+		{
+			profile: SuciProfile{
+				ProtectionScheme: "2",
+				PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+				PublicKey:        "0272DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD1",
+			},
+			valid: false,
+		},
+		{
+			profile: SuciProfile{
+				ProtectionScheme: "1",
+				PrivateKey:       "F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA",
+				PublicKey:        "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650",
+			},
+			valid: false,
+		},
+	}
+	for i, tc := range testCases {
+		if err := ValidateKeyPair(tc.profile); (err == nil) != tc.valid {
+			t.Errorf("TC%d fail: err[%v], expected valid[%t]\n", i, err, tc.valid)
+		}
+	}
+}
+
+func TestGenerateKeyPair(t *testing.T) {
+	for _, scheme := range []string{ProfileAScheme, ProfileBScheme} {
+		privateKey, publicKey, err := GenerateKeyPair(scheme)
+		if err != nil {
+			t.Fatalf("scheme %s: GenerateKeyPair err[%+v]", scheme, err)
+		}
+		profile := SuciProfile{
+			ProtectionScheme: scheme,
+			PrivateKey:       privateKey,
+			PublicKey:        publicKey,
+		}
+		if err = ValidateKeyPair(profile); err != nil {
+			t.Errorf("scheme %s: ValidateKeyPair err[%+v]", scheme, err)
+		}
+	}
+}
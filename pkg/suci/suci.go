@@ -9,23 +9,63 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"math/big"
 	"math/bits"
+	"regexp"
 	"strconv"
 	"strings"
 
-	"golang.org/x/crypto/curve25519"
-
 	"github.com/free5gc/udm/internal/logger"
 )
 
 type SuciProfile struct {
+	// HNPublicKeyID is the home network public key identifier (1-255) carried in the SUCI.
+	// If it is not set, the position of the profile in the configuration (starting from 1) is used.
+	HNPublicKeyID int `yaml:"HNPublicKeyID,omitempty"`
+	// RoutingIndicator restricts the profile to the SUCIs with this routing indicator if it is set
+	RoutingIndicator string `yaml:"RoutingIndicator,omitempty"`
 	ProtectionScheme string `yaml:"ProtectionScheme,omitempty"`
 	PrivateKey       string `yaml:"PrivateKey,omitempty"`
 	PublicKey        string `yaml:"PublicKey,omitempty"`
+	// Backend is the de-concealment backend: software (default, uses PrivateKey) or pkcs11
+	Backend string        `yaml:"Backend,omitempty"`
+	Pkcs11  *Pkcs11Config `yaml:"Pkcs11,omitempty"`
+	// Deconcealer is created from Backend by NewDeconcealer. If it is nil, PrivateKey is used.
+	Deconcealer Deconcealer `yaml:"-"`
+}
+
+const (
+	MinHNPublicKeyID = 1
+	MaxHNPublicKeyID = 255
+)
+
+var (
+	ErrUnknownHNPublicKeyID     = errors.New("unknown home network public key identifier")
+	ErrRoutingIndicatorMismatch = errors.New("routing indicator mismatch")
+)
+
+// ProfileMap indexes the SUCI profiles by their home network public key identifier. It fails if
+// an identifier is out of range or used by more than one profile.
+func ProfileMap(suciProfiles []SuciProfile) (map[int]SuciProfile, error) {
+	profiles := make(map[int]SuciProfile, len(suciProfiles))
+	for i, profile := range suciProfiles {
+		if profile.HNPublicKeyID == 0 {
+			profile.HNPublicKeyID = i + 1
+		}
+		if profile.HNPublicKeyID < MinHNPublicKeyID || profile.HNPublicKeyID > MaxHNPublicKeyID {
+			return nil, fmt.Errorf("HNPublicKeyID(%d) out of range [%d, %d]",
+				profile.HNPublicKeyID, MinHNPublicKeyID, MaxHNPublicKeyID)
+		}
+		if _, ok := profiles[profile.HNPublicKeyID]; ok {
+			return nil, fmt.Errorf("duplicate HNPublicKeyID(%d)", profile.HNPublicKeyID)
+		}
+		profiles[profile.HNPublicKeyID] = profile
+	}
+	return profiles, nil
 }
 
 // profile A.
@@ -155,13 +195,16 @@ func calcSchemeResult(decryptPlainText []byte, supiType string) string {
 		if schemeResult[len(schemeResult)-1] == 'f' {
 			schemeResult = schemeResult[:len(schemeResult)-1]
 		}
+	} else if supiType == SupiTypeNAI {
+		// username of the NAI (TS 33.501 6.12.2)
+		schemeResult = string(decryptPlainText)
 	} else {
 		schemeResult = hex.EncodeToString(decryptPlainText)
 	}
 	return schemeResult
 }
 
-func profileA(input, supiType, privateKey string) (string, error) {
+func profileA(input, supiType string, deconcealer Deconcealer) (string, error) {
 	logger.SuciLog.Infoln("SuciToSupi Profile A")
 	s, hexDecodeErr := hex.DecodeString(input)
 	if hexDecodeErr != nil {
@@ -182,19 +225,10 @@ func profileA(input, supiType, privateKey string) (string, error) {
 	decryptCipherText := s[ProfileAPubKeyLen : len(s)-ProfileAMacLen]
 	// fmt.Printf("dePub: %x\ndeCiph: %x\ndeMac: %x\n", decryptPublicKey, decryptCipherText, decryptMac)
 
-	// test data from TS33.501 Annex C.4
-	// aHNPriv, _ := hex.DecodeString("c53c2208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d")
-	var aHNPriv []byte
-	if aHNPrivTmp, err := hex.DecodeString(privateKey); err != nil {
-		log.Printf("Decode error: %+v", err)
-	} else {
-		aHNPriv = aHNPrivTmp
-	}
-	var decryptSharedKey []byte
-	if decryptSharedKeyTmp, err := curve25519.X25519(aHNPriv, decryptPublicKey); err != nil {
-		log.Printf("X25519 error: %+v", err)
-	} else {
-		decryptSharedKey = decryptSharedKeyTmp
+	decryptSharedKey, err := deconcealer.SharedKey(decryptPublicKey)
+	if err != nil {
+		logger.SuciLog.Errorf("ECDH error: %+v", err)
+		return "", err
 	}
 	// fmt.Printf("deShared: %x\n", decryptSharedKey)
 
@@ -232,7 +266,7 @@ func checkOnCurve(curve elliptic.Curve, x, y *big.Int) error {
 	return nil
 }
 
-func profileB(input, supiType, privateKey string) (string, error) {
+func profileB(input, supiType string, deconcealer Deconcealer) (string, error) {
 	logger.SuciLog.Infoln("SuciToSupi Profile B")
 	s, hexDecodeErr := hex.DecodeString(input)
 	if hexDecodeErr != nil {
@@ -263,21 +297,12 @@ func profileB(input, supiType, privateKey string) (string, error) {
 	decryptCipherText := s[ProfileBPubKeyLen : len(s)-ProfileBMacLen]
 	// fmt.Printf("dePub: %x\ndeCiph: %x\ndeMac: %x\n", decryptPublicKey, decryptCipherText, decryptMac)
 
-	// test data from TS33.501 Annex C.4
-	// bHNPriv, _ := hex.DecodeString("F1AB1074477EBCC7F554EA1C5FC368B1616730155E0041AC447D6301975FECDA")
-	var bHNPriv []byte
-	if bHNPrivTmp, err := hex.DecodeString(privateKey); err != nil {
-		log.Printf("Decode error: %+v", err)
-	} else {
-		bHNPriv = bHNPrivTmp
-	}
-
 	var xUncompressed, yUncompressed *big.Int
 	if uncompressed {
 		xUncompressed = new(big.Int).SetBytes(decryptPublicKey[1:(ProfileBPubKeyLen/2 + 1)])
 		yUncompressed = new(big.Int).SetBytes(decryptPublicKey[(ProfileBPubKeyLen/2 + 1):])
 	} else {
-		xUncompressed, yUncompressed = uncompressKey(decryptPublicKey, bHNPriv)
+		xUncompressed, yUncompressed = uncompressKey(decryptPublicKey, nil)
 		if xUncompressed == nil || yUncompressed == nil {
 			logger.SuciLog.Errorln("Uncompressed key has invalid point")
 			return "", fmt.Errorf("Key uncompression error\n")
@@ -290,15 +315,19 @@ func profileB(input, supiType, privateKey string) (string, error) {
 	}
 
 	// x-coordinate is the shared key
-	decryptSharedKey, _ := elliptic.P256().ScalarMult(xUncompressed, yUncompressed, bHNPriv)
-	// fmt.Printf("deShared: %x\n", decryptSharedKey.Bytes())
+	//nolint:staticcheck // the SUCI helpers work on the raw points
+	sharedKey, err := deconcealer.SharedKey(elliptic.Marshal(elliptic.P256(), xUncompressed, yUncompressed))
+	if err != nil {
+		logger.SuciLog.Errorf("ECDH error: %+v", err)
+		return "", err
+	}
 
 	decryptPublicKeyForKDF := decryptPublicKey
 	if uncompressed {
 		decryptPublicKeyForKDF = CompressKey(decryptPublicKey, yUncompressed)
 	}
 
-	kdfKey := AnsiX963KDF(decryptSharedKey.Bytes(), decryptPublicKeyForKDF, ProfileBEncKeyLen, ProfileBMacKeyLen,
+	kdfKey := AnsiX963KDF(sharedKey, decryptPublicKeyForKDF, ProfileBEncKeyLen, ProfileBMacKeyLen,
 		ProfileBHashLen)
 	// fmt.Printf("kdfKey: %x\n", kdfKey)
 	decryptEncKey := kdfKey[:ProfileBEncKeyLen]
@@ -321,7 +350,6 @@ func profileB(input, supiType, privateKey string) (string, error) {
 }
 
 // suci-0(SUPI type: IMSI)-mcc-mnc-routingIndicator-protectionScheme-homeNetworkPublicKeyID-schemeOutput.
-// TODO:
 // suci-1(SUPI type: NAI)-homeNetworkID-routingIndicator-protectionScheme-homeNetworkPublicKeyID-schemeOutput.
 const (
 	PrefixPlace = iota
@@ -337,71 +365,170 @@ const (
 
 const (
 	PrefixIMSI     = "imsi-"
+	PrefixNAI      = "nai-"
 	PrefixSUCI     = "suci"
 	SupiTypeIMSI   = "0"
+	SupiTypeNAI    = "1"
 	NullScheme     = "0"
 	ProfileAScheme = "1"
 	ProfileBScheme = "2"
 )
 
-func ToSupi(suci string, suciProfiles []SuciProfile) (string, error) {
+// The home network identifier of a NAI-format SUCI is a realm which may contain "-", so the SUCI
+// is matched from the right. The scheme output of the null-scheme is the username itself.
+var (
+	naiSuciNullSchemeRegexp = regexp.MustCompile(`^suci-1-(.+?)-([0-9]{1,4})-0-0-(.+)$`)
+	naiSuciRegexp           = regexp.MustCompile(`^suci-1-(.+)-([0-9]{1,4})-([a-fA-F1-9])-([0-9]{1,3})-([a-fA-F0-9]+)$`)
+)
+
+// suciInfo is the SUCI split into its fields (TS 23.003 2.2B)
+type suciInfo struct {
+	supiType string
+	// mcc+mnc for the IMSI-based SUCI, realm for the NAI-based SUCI
+	homeNetworkID    string
+	routingIndicator string
+	scheme           string
+	hnPublicKeyID    string
+	schemeOutput     string
+}
+
+func parseSuci(suci string) (*suciInfo, error) {
 	suciPart := strings.Split(suci, "-")
-	logger.SuciLog.Infof("suciPart: %+v", suciPart)
+	if len(suciPart) < MaxPlace-1 {
+		return nil, fmt.Errorf("Suci with wrong format\n")
+	}
 
-	suciPrefix := suciPart[0]
+	switch suciPart[SupiTypePlace] {
+	case SupiTypeIMSI:
+		if len(suciPart) != MaxPlace {
+			return nil, fmt.Errorf("Suci with wrong format\n")
+		}
+		logger.SuciLog.Infof("SUPI type is IMSI\n")
+		return &suciInfo{
+			supiType:         SupiTypeIMSI,
+			homeNetworkID:    suciPart[MccPlace] + suciPart[MncPlace],
+			routingIndicator: suciPart[RoutingIndicatorPlace],
+			scheme:           suciPart[SchemePlace],
+			hnPublicKeyID:    suciPart[HNPublicKeyIDPlace],
+			schemeOutput:     suciPart[SchemeOuputPlace],
+		}, nil
+	case SupiTypeNAI:
+		logger.SuciLog.Infof("SUPI type is NAI\n")
+		if m := naiSuciNullSchemeRegexp.FindStringSubmatch(suci); m != nil {
+			return &suciInfo{
+				supiType:         SupiTypeNAI,
+				homeNetworkID:    m[1],
+				routingIndicator: m[2],
+				scheme:           NullScheme,
+				hnPublicKeyID:    "0",
+				schemeOutput:     m[3],
+			}, nil
+		}
+		if m := naiSuciRegexp.FindStringSubmatch(suci); m != nil {
+			return &suciInfo{
+				supiType:         SupiTypeNAI,
+				homeNetworkID:    m[1],
+				routingIndicator: m[2],
+				scheme:           m[3],
+				hnPublicKeyID:    m[4],
+				schemeOutput:     m[5],
+			}, nil
+		}
+		return nil, fmt.Errorf("Suci with wrong format\n")
+	default:
+		return nil, fmt.Errorf("SUPI type (%s) is not supported", suciPart[SupiTypePlace])
+	}
+}
+
+// toSupi builds the SUPI from the home network identifier and the (de-concealed) MSIN or username
+func (s *suciInfo) toSupi(schemeResult string) string {
+	if s.supiType == SupiTypeNAI {
+		return PrefixNAI + schemeResult + "@" + s.homeNetworkID
+	}
+	return PrefixIMSI + s.homeNetworkID + schemeResult
+}
+
+// ToSupi de-conceals the SUCI with the profile of its home network public key identifier
+func ToSupi(suci string, suciProfiles map[int]SuciProfile) (string, error) {
+	suciPrefix, _, _ := strings.Cut(suci, "-")
 	if suciPrefix == "imsi" || suciPrefix == "nai" {
 		logger.SuciLog.Infof("Got supi\n")
 		return suci, nil
-	} else if suciPrefix == PrefixSUCI {
-		if len(suciPart) < 6 {
-			return "", fmt.Errorf("Suci with wrong format\n")
-		}
-	} else {
+	} else if suciPrefix != PrefixSUCI {
 		return "", fmt.Errorf("Unknown suciPrefix [%s]", suciPrefix)
 	}
 
-	logger.SuciLog.Infof("scheme %s\n", suciPart[SchemePlace])
-	scheme := suciPart[SchemePlace]
-	mccMnc := suciPart[MccPlace] + suciPart[MncPlace]
-
-	supiPrefix := PrefixIMSI
-	if suciPrefix == PrefixSUCI && suciPart[SupiTypePlace] == SupiTypeIMSI {
-		logger.SuciLog.Infof("SUPI type is IMSI\n")
+	info, err := parseSuci(suci)
+	if err != nil {
+		return "", err
 	}
+	logger.SuciLog.Infof("suci: %+v", *info)
+
+	logger.SuciLog.Infof("scheme %s\n", info.scheme)
+	scheme := info.scheme
 
 	if scheme == NullScheme { // NULL scheme
-		return supiPrefix + mccMnc + suciPart[len(suciPart)-1], nil
+		return info.toSupi(info.schemeOutput), nil
 	}
 
-	// (HNPublicKeyID-1) is the index of "suciProfiles" slices
-	keyIndex, err := strconv.Atoi(suciPart[HNPublicKeyIDPlace])
+	keyID, err := strconv.Atoi(info.hnPublicKeyID)
 	if err != nil {
 		return "", fmt.Errorf("Parse HNPublicKeyID error: %+v", err)
 	}
-	if keyIndex > len(suciProfiles) {
-		return "", fmt.Errorf("keyIndex(%d) out of range(%d)", keyIndex, len(suciProfiles))
+	profile, ok := suciProfiles[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %d", ErrUnknownHNPublicKeyID, keyID)
 	}
 
-	protectScheme := suciProfiles[keyIndex-1].ProtectionScheme
-	privateKey := suciProfiles[keyIndex-1].PrivateKey
+	// TS 23.003 2.2B: routing indicator consists of 1 to 4 decimal digits
+	routingIndicator := info.routingIndicator
+	if !isRoutingIndicator(routingIndicator) {
+		return "", fmt.Errorf("Invalid routing indicator [%s]", routingIndicator)
+	}
+	if profile.RoutingIndicator != "" && profile.RoutingIndicator != routingIndicator {
+		return "", fmt.Errorf("%w: [%s:%s]", ErrRoutingIndicatorMismatch, routingIndicator, profile.RoutingIndicator)
+	}
 
+	protectScheme := profile.ProtectionScheme
 	if scheme != protectScheme {
 		return "", fmt.Errorf("Protect Scheme mismatch [%s:%s]", scheme, protectScheme)
 	}
 
+	deconcealer := profile.Deconcealer
+	if deconcealer == nil {
+		if profile.Backend == BackendPkcs11 {
+			return "", fmt.Errorf("SUCI backend (%s) of HNPublicKeyID(%d) is not available", profile.Backend, keyID)
+		}
+		if deconcealer, err = newSoftwareDeconcealer(protectScheme, profile.PrivateKey); err != nil {
+			return "", err
+		}
+	}
+
 	if scheme == ProfileAScheme {
-		if profileAResult, err := profileA(suciPart[len(suciPart)-1], suciPart[SupiTypePlace], privateKey); err != nil {
+		if profileAResult, err := profileA(info.schemeOutput, info.supiType, deconcealer); err != nil {
 			return "", err
 		} else {
-			return supiPrefix + mccMnc + profileAResult, nil
+			return info.toSupi(profileAResult), nil
 		}
 	} else if scheme == ProfileBScheme {
-		if profileBResult, err := profileB(suciPart[len(suciPart)-1], suciPart[SupiTypePlace], privateKey); err != nil {
+		if profileBResult, err := profileB(info.schemeOutput, info.supiType, deconcealer); err != nil {
 			return "", err
 		} else {
-			return supiPrefix + mccMnc + profileBResult, nil
+			return info.toSupi(profileBResult), nil
 		}
 	} else {
 		return "", fmt.Errorf("Protect Scheme (%s) is not supported", scheme)
 	}
 }
+
+func isRoutingIndicator(routingIndicator string) bool {
+	if len(routingIndicator) < 1 || len(routingIndicator) > 4 {
+		return false
+	}
+	for _, c := range routingIndicator {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func TestToSupi(t *testing.T) {
-	suciProfiles := []SuciProfile{
+	suciProfiles, err := ProfileMap([]SuciProfile{
 		{
 			ProtectionScheme: "1", // Protect Scheme: Profile A
 			PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
@@ -18,6 +18,16 @@ func TestToSupi(t *testing.T) {
 			PublicKey: "0472DA71976234CE833A6907425867B82E074D44EF907DFB4B3E21C1C2256EBCD" +
 				"15A7DED52FCBB097A4ED250E036C7B9C8C7004C4EEDC4F068CD7BF8D3F900E3B4",
 		},
+		{
+			HNPublicKeyID:    255,
+			RoutingIndicator: "0001",
+			ProtectionScheme: "1", // Protect Scheme: Profile A
+			PrivateKey:       "c53c22208b61860b06c62e5406a7b330c2b577aa5558981510d128247d38bd1d",
+			PublicKey:        "5a8d38864820197c3394b92613b20b91633cbd897119273bf8e4a6f4eec0a650",
+		},
+	})
+	if err != nil {
+		t.Fatalf("ProfileMap fail: %+v", err)
 	}
 	testCases := []struct {
 		suci         string
@@ -47,15 +57,118 @@ func TestToSupi(t *testing.T) {
 			expectedSupi: "",
 			expectedErr:  fmt.Errorf("crypto/elliptic: attempted operation on invalid point"),
 		},
+		{
+			suci: "suci-0-208-93-0001-1-255-b2e92f836055a255837debf850b528997ce0201cb82a" +
+				"dfe4be1f587d07d8457dcb02352410cddd9e730ef3fa87",
+			expectedSupi: "imsi-20893001002086",
+			expectedErr:  nil,
+		},
+		{
+			suci: "suci-0-208-93-0-1-255-b2e92f836055a255837debf850b528997ce0201cb82a" +
+				"dfe4be1f587d07d8457dcb02352410cddd9e730ef3fa87",
+			expectedSupi: "",
+			expectedErr:  fmt.Errorf("routing indicator mismatch: [0:0001]"),
+		},
+		{
+			suci: "suci-0-208-93-0-1-0-b2e92f836055a255837debf850b528997ce0201cb82a" +
+				"dfe4be1f587d07d8457dcb02352410cddd9e730ef3fa87",
+			expectedSupi: "",
+			expectedErr:  fmt.Errorf("unknown home network public key identifier: 0"),
+		},
+		{
+			suci: "suci-0-208-93-0-1-5-b2e92f836055a255837debf850b528997ce0201cb82a" +
+				"dfe4be1f587d07d8457dcb02352410cddd9e730ef3fa87",
+			expectedSupi: "",
+			expectedErr:  fmt.Errorf("unknown home network public key identifier: 5"),
+		},
+		// NAI-based SUCIs, null scheme or concealed here with the home network keys of the IMSI test vectors
+		// (TS 33.501 Annex C.4); the spec has no NAI test vectors
+		{
+			suci:         "suci-1-example.com-0-0-0-alice.smith",
+			expectedSupi: "nai-alice.smith@example.com",
+			expectedErr:  nil,
+		},
+		{
+			suci:         "suci-1-corp-net.example.com-12-0-0-alice-smith",
+			expectedSupi: "nai-alice-smith@corp-net.example.com",
+			expectedErr:  nil,
+		},
+		{
+			suci: "suci-1-example.com-0-1-1-32dd747b3c01d76c344606fd04b868000b9ae47b377af4765e573d0879990006" +
+				"febe297ad0ee57867b74a589497d43898f85e7",
+			expectedSupi: "nai-alice.smith@example.com",
+			expectedErr:  nil,
+		},
+		{
+			suci: "suci-1-corp-net.example.com-0-2-2-039aab8376597021e855679a9778ea0b67396e68c66df32c0f41e9acca2da9b9d1" +
+				"27ce7621e27ebe1b3f183ef0b5501b4aca99d1",
+			expectedSupi: "nai-alice.smith@corp-net.example.com",
+			expectedErr:  nil,
+		},
+		{
+			suci: "suci-1-example.com-0-1-1-32dd747b3c01d76c344606fd04b868000b9ae47b377af4765e573d0879990006" +
+				"febe297ad0ee57867b74a589497d43898f85e8",
+			expectedSupi: "",
+			expectedErr:  fmt.Errorf("decryption MAC failed\n"),
+		},
+		{
+			suci:         "suci-2-example.com-0-0-0-alice.smith",
+			expectedSupi: "",
+			expectedErr:  fmt.Errorf("SUPI type (2) is not supported"),
+		},
 	}
 	for i, tc := range testCases {
 		supi, err := ToSupi(tc.suci, suciProfiles)
 		if err != nil {
-			if err.Error() != tc.expectedErr.Error() {
+			if tc.expectedErr == nil || err.Error() != tc.expectedErr.Error() {
 				t.Errorf("TC%d fail: err[%s], expected[%s]\n", i, err, tc.expectedErr)
 			}
+		} else if tc.expectedErr != nil {
+			t.Errorf("TC%d fail: expected err[%s]\n", i, tc.expectedErr)
 		} else if supi != tc.expectedSupi {
 			t.Errorf("TC%d fail: supi[%s], expected[%s]\n", i, supi, tc.expectedSupi)
 		}
 	}
 }
+
+func TestProfileMap(t *testing.T) {
+	testCases := []struct {
+		profiles    []SuciProfile
+		expectedIDs []int
+		expectedErr error
+	}{
+		{
+			profiles:    []SuciProfile{{ProtectionScheme: "1"}, {ProtectionScheme: "2"}},
+			expectedIDs: []int{1, 2},
+		},
+		{
+			profiles:    []SuciProfile{{HNPublicKeyID: 5}, {HNPublicKeyID: 255}},
+			expectedIDs: []int{5, 255},
+		},
+		{
+			profiles:    []SuciProfile{{HNPublicKeyID: 2}, {}},
+			expectedErr: fmt.Errorf("duplicate HNPublicKeyID(2)"),
+		},
+		{
+			profiles:    []SuciProfile{{HNPublicKeyID: 256}},
+			expectedErr: fmt.Errorf("HNPublicKeyID(256) out of range [1, 255]"),
+		},
+	}
+	for i, tc := range testCases {
+		profiles, err := ProfileMap(tc.profiles)
+		if err != nil {
+			if tc.expectedErr == nil || err.Error() != tc.expectedErr.Error() {
+				t.Errorf("TC%d fail: err[%s], expected[%v]\n", i, err, tc.expectedErr)
+			}
+			continue
+		}
+		if tc.expectedErr != nil {
+			t.Errorf("TC%d fail: expected err[%s]\n", i, tc.expectedErr)
+		}
+		for _, id := range tc.expectedIDs {
+			if _, ok := profiles[id]; !ok {
+				t.Errorf("TC%d fail: HNPublicKeyID[%d] not found\n", i, id)
+			}
+		}
+	}
+}